@@ -0,0 +1,332 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// BBox is a bounding box, selecting the positions between two latitudes and two
+// longitudes, in decimal degrees, bounds included. The box crosses the
+// antimeridian if MinLon is greater than MaxLon, so that 170 to -170 selects
+// the 20 degrees of longitude around it, rather than the 340 degrees between.
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// Valid reports whether both corners of the box are valid positions, see
+// Point.Valid, with MinLat not greater than MaxLat.
+func (b BBox) Valid() bool {
+	return Point{Lat: b.MinLat, Lon: b.MinLon}.Valid() &&
+		Point{Lat: b.MaxLat, Lon: b.MaxLon}.Valid() &&
+		b.MinLat <= b.MaxLat
+}
+
+// Contains reports whether the position is within the box.
+func (b BBox) Contains(pt Point) bool {
+	if pt.Lat < b.MinLat || pt.Lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon > b.MaxLon {
+		return pt.Lon >= b.MinLon || pt.Lon <= b.MaxLon // Across the antimeridian.
+	}
+
+	return pt.Lon >= b.MinLon && pt.Lon <= b.MaxLon
+}
+
+// Polygon is a polygon, as a GeoJSON Polygon describes it: a list of linear
+// rings, the exterior ring first and holes following. Each ring is a closed
+// list of positions, with the first position repeated last, and four
+// positions at least.
+//
+// An edge spanning more than 180 degrees of longitude is taken to cross the
+// antimeridian, the short way round, as MongoDB takes it too, so that polygons
+// around the antimeridian need not be split in two.
+type Polygon [][]Point
+
+// Valid reports whether the polygon has at least one ring, and whether every
+// ring is closed, with four valid positions at least, none repeated in a row.
+// Polygons should also be simple, as MongoDB expects them: edges should only
+// meet the edges before and after them in their ring, at a common position,
+// and holes should be within the exterior ring, and outside each other.
+func (p Polygon) Valid() bool {
+	if len(p) == 0 {
+		return false
+	}
+	for _, ring := range p {
+		if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+			return false
+		}
+		for i, pt := range ring {
+			if !pt.Valid() || (i > 0 && pt == ring[i-1]) {
+				return false
+			}
+		}
+	}
+	if !p.simple() {
+		return false
+	}
+
+	// Rings do not cross, so a single position tells whether a hole is within
+	// another ring.
+	for i, hole := range p[1:] {
+		if !p[:1].Contains(hole[0]) {
+			return false
+		}
+		for j, other := range p[1:] {
+			if i != j && (Polygon{other}).Contains(hole[0]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// simple reports whether the edges of the polygon neither cross nor touch,
+// apart from consecutive edges of a ring meeting at their common position
+// without folding back over each other, see Polygon.Valid.
+func (p Polygon) simple() bool {
+	unwrap := p.unwrap()
+	rings := make([][]Point, len(p))
+	for i, ring := range p {
+		rings[i] = make([]Point, len(ring))
+		for j, pt := range ring {
+			rings[i][j] = Point{Lat: pt.Lat, Lon: unwrap(pt.Lon)}
+		}
+	}
+
+	// Edge j of a ring runs from position j to position j+1, and the last edge
+	// of a ring is followed by the first one.
+	for i, ring := range rings {
+		n := len(ring) - 1
+		for j := range n {
+			a, b, c := ring[j], ring[j+1], ring[(j+1)%n+1]
+			if orientation(a, b, c) == 0 && (b.Lon-a.Lon)*(c.Lon-b.Lon)+(b.Lat-a.Lat)*(c.Lat-b.Lat) < 0 {
+				return false // Folding back.
+			}
+			for k := j + 2; k < n; k++ {
+				if (j > 0 || k < n-1) && intersect(a, b, ring[k], ring[k+1]) {
+					return false
+				}
+			}
+			for _, other := range rings[i+1:] {
+				for k := 1; k < len(other); k++ {
+					if intersect(a, b, other[k-1], other[k]) {
+						return false
+					}
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// orientation returns a positive number if the positions a, b and c turn
+// counterclockwise, in longitude and latitude, a negative number if they turn
+// clockwise, and zero if they are on a line.
+func orientation(a, b, c Point) float64 {
+	return (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+}
+
+// onEdge reports whether the position c, on a line with the positions a and b,
+// is between them.
+func onEdge(a, b, c Point) bool {
+	return min(a.Lon, b.Lon) <= c.Lon && c.Lon <= max(a.Lon, b.Lon) &&
+		min(a.Lat, b.Lat) <= c.Lat && c.Lat <= max(a.Lat, b.Lat)
+}
+
+// intersect reports whether the edge from a to b and the edge from c to d
+// cross or touch.
+func intersect(a, b, c, d Point) bool {
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if o1*o2 < 0 && o3*o4 < 0 {
+		return true
+	}
+
+	return (o1 == 0 && onEdge(a, b, c)) || (o2 == 0 && onEdge(a, b, d)) ||
+		(o3 == 0 && onEdge(c, d, a)) || (o4 == 0 && onEdge(c, d, b))
+}
+
+// crossesAntimeridian reports whether any edge of the polygon crosses the
+// antimeridian, see Polygon.
+func (p Polygon) crossesAntimeridian() bool {
+	for _, ring := range p {
+		for i := 1; i < len(ring); i++ {
+			if math.Abs(ring[i].Lon-ring[i-1].Lon) > 180 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// unwrap returns a function mapping longitudes so that the polygon does not
+// cross the antimeridian: longitudes west of the prime meridian are moved
+// east by 360 degrees if the polygon crosses it, and left alone otherwise.
+func (p Polygon) unwrap() func(lon float64) float64 {
+	if !p.crossesAntimeridian() {
+		return func(lon float64) float64 { return lon }
+	}
+
+	return func(lon float64) float64 {
+		if lon < 0 {
+			return lon + 360
+		}
+		return lon
+	}
+}
+
+// Contains reports whether the position is within the polygon: within the
+// exterior ring, and outside its holes. Edges are taken as straight lines in
+// longitude and latitude, as GeoJSON describes them.
+func (p Polygon) Contains(pt Point) bool {
+	unwrap := p.unwrap()
+	x := unwrap(pt.Lon)
+
+	// Cast a ray eastwards from the position, counting the edges it crosses,
+	// holes included: the position is within the polygon if the count is odd.
+	var in bool
+	for _, ring := range p {
+		for i := 1; i < len(ring); i++ {
+			a, b := ring[i-1], ring[i]
+			if (a.Lat > pt.Lat) == (b.Lat > pt.Lat) {
+				continue
+			}
+
+			ax, bx := unwrap(a.Lon), unwrap(b.Lon)
+			if x < ax+(bx-ax)*(pt.Lat-a.Lat)/(b.Lat-a.Lat) {
+				in = !in
+			}
+		}
+	}
+
+	return in
+}
+
+// Bounds returns the smallest box holding the exterior ring of the polygon,
+// crossing the antimeridian if the polygon does.
+func (p Polygon) Bounds() BBox {
+	unwrap := p.unwrap()
+	b := BBox{MinLon: math.Inf(1), MinLat: 90, MaxLon: math.Inf(-1), MaxLat: -90}
+	for _, pt := range p[0] {
+		lon := unwrap(pt.Lon)
+		b.MinLon, b.MaxLon = min(b.MinLon, lon), max(b.MaxLon, lon)
+		b.MinLat, b.MaxLat = min(b.MinLat, pt.Lat), max(b.MaxLat, pt.Lat)
+	}
+
+	// Move longitudes past the antimeridian back west.
+	if b.MinLon > 180 {
+		b.MinLon -= 360
+	}
+	if b.MaxLon > 180 {
+		b.MaxLon -= 360
+	}
+
+	return b
+}
+
+// Area is a region of the surface of the Earth, selected either by a bounding
+// box, or by polygons, as a GeoJSON Polygon or MultiPolygon describes them,
+// see Service.ListPortsWithin.
+type Area struct {
+	BBox     *BBox     // Set for a bounding box.
+	Polygons []Polygon // Set for polygons, the area covered by any of them.
+}
+
+// Contains reports whether the position is within the area.
+func (a Area) Contains(pt Point) bool {
+	if a.BBox != nil {
+		return a.BBox.Contains(pt)
+	}
+	for _, p := range a.Polygons {
+		if p.Contains(pt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AreaLister can list the Port records in storage located within an Area, a
+// page at a time.
+type AreaLister interface {
+	// ListPortsWithin returns up to limit Port records located within the
+	// area, leaving retired records and records without a Port.Location out,
+	// sorted by identifier. Records start right after the identifier provided,
+	// or from the first record if it is empty. It returns an Error with code
+	// ErrCodeInvalid if the storage system rejects the area.
+	ListPortsWithin(ctx context.Context, a Area, after string, limit int) ([]Port, error)
+}
+
+// ErrInvalidArea is the error returned when an Area sets both a bounding box
+// and polygons, or neither.
+var ErrInvalidArea = &Error{Code: ErrCodeInvalid, Msg: "area should be either a bounding box or polygons"}
+
+// ErrInvalidBBox is the error returned when a bounding box is not valid, see
+// BBox.Valid.
+var ErrInvalidBBox = &Error{Code: ErrCodeInvalid, Msg: "bounding box should hold valid positions, south to north"}
+
+// ErrInvalidPolygon is the error returned when a polygon is not valid, see
+// Polygon.Valid.
+var ErrInvalidPolygon = &Error{Code: ErrCodeInvalid, Msg: "polygon rings should be closed and simple, with four valid positions at least"}
+
+// ListPortsWithin retrieves a page of the Port records in storage located
+// within the area, leaving retired records and records without a
+// Port.Location out. Records are sorted and paged through as with ListPorts.
+//
+// Polygon edges are taken as straight lines in longitude and latitude by
+// Polygon.Contains, while MongoDB takes them as great-circle arcs. The two
+// agree closely as long as edges are short, as they are along the outline of a
+// region, but storage systems may disagree on records near long edges.
+//
+// It returns an error if the area, the cursor or the size are not valid, if
+// the storage system cannot find records by location, see AreaLister, if it
+// fails, or if the context is cancelled before the operation is completed.
+// Errors with code ErrCodeInvalid from the storage system, e.g. for polygons
+// not valid on a sphere, are returned as they are.
+func (s *Service) ListPortsWithin(ctx context.Context, a Area, cursor string, size int) (*Page, error) {
+	lister, ok := s.Ports.(AreaLister)
+	if !ok {
+		return nil, ErrGeoUnsupported
+	}
+
+	switch {
+	case (a.BBox == nil) == (len(a.Polygons) == 0):
+		return nil, ErrInvalidArea
+	case a.BBox != nil && !a.BBox.Valid():
+		return nil, ErrInvalidBBox
+	}
+	for _, p := range a.Polygons {
+		if !p.Valid() {
+			return nil, ErrInvalidPolygon
+		}
+	}
+
+	size, err := pageSize(size)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for one more record, to tell whether there is a next page.
+	ps, err := lister.ListPortsWithin(ctx, a, after, size+1)
+	if err != nil {
+		if errors.Is(err, &Error{Code: ErrCodeInvalid}) {
+			return nil, err
+		}
+
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not list ports", Cause: err}
+	}
+
+	return newPage(ps, size), nil
+}
@@ -0,0 +1,213 @@
+package ports_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mock"
+)
+
+// ring returns a closed ring out of positions given as longitude and latitude
+// pairs, as in GeoJSON.
+func ring(lonLat ...float64) []ports.Point {
+	var r []ports.Point
+	for i := 0; i+1 < len(lonLat); i += 2 {
+		r = append(r, ports.Point{Lat: lonLat[i+1], Lon: lonLat[i]})
+	}
+	return append(r, r[0])
+}
+
+func TestBBoxContains(t *testing.T) {
+	tests := []struct {
+		bbox ports.BBox
+		at   ports.Point
+		want bool
+	}{
+		{bbox: ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}, at: ports.Point{Lat: 59.91, Lon: 10.75}, want: true},
+		{bbox: ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}, at: ports.Point{Lat: 57, Lon: 4}, want: true},
+		{bbox: ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}, at: ports.Point{Lat: 25.2, Lon: 55.27}, want: false},
+		{bbox: ports.BBox{MinLon: 170, MinLat: -25, MaxLon: -165, MaxLat: -10}, at: ports.Point{Lat: -18.14, Lon: 178.44}, want: true},
+		{bbox: ports.BBox{MinLon: 170, MinLat: -25, MaxLon: -165, MaxLat: -10}, at: ports.Point{Lat: -13.83, Lon: -171.76}, want: true},
+		{bbox: ports.BBox{MinLon: 170, MinLat: -25, MaxLon: -165, MaxLat: -10}, at: ports.Point{Lat: -15, Lon: 0}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.bbox.Contains(tt.at); got != tt.want {
+			t.Errorf("Contains(%+v): %+v: have %t, want %t", tt.at, tt.bbox, got, tt.want)
+		}
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// A square around Oslo, with a hole around the city itself.
+	oslofjord := ports.Polygon{
+		ring(9, 58, 12, 58, 12, 61, 9, 61),
+		ring(10.5, 59.8, 11, 59.8, 11, 60, 10.5, 60),
+	}
+	// A triangle across the antimeridian, around Fiji and Samoa.
+	pacific := ports.Polygon{ring(175, -20, -170, -20, -170, -10)}
+
+	tests := []struct {
+		name    string
+		polygon ports.Polygon
+		at      ports.Point
+		want    bool
+	}{
+		{name: "Drammen", polygon: oslofjord, at: ports.Point{Lat: 59.73, Lon: 10.23}, want: true},
+		{name: "Oslo, in the hole", polygon: oslofjord, at: ports.Point{Lat: 59.91, Lon: 10.75}, want: false},
+		{name: "Bergen", polygon: oslofjord, at: ports.Point{Lat: 60.39, Lon: 5.32}, want: false},
+		{name: "Suva", polygon: pacific, at: ports.Point{Lat: -18.14, Lon: 178.44}, want: true},
+		{name: "Apia", polygon: pacific, at: ports.Point{Lat: -13.83, Lon: -171.76}, want: true},
+		{name: "Nuku'alofa", polygon: pacific, at: ports.Point{Lat: -21.14, Lon: -175.2}, want: false},
+		{name: "prime meridian", polygon: pacific, at: ports.Point{Lat: -15, Lon: 0}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.polygon.Contains(tt.at); got != tt.want {
+			t.Errorf("Contains(): %s: have %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := pacific.Bounds(), (ports.BBox{MinLon: 175, MinLat: -20, MaxLon: -170, MaxLat: -10}); got != want {
+		t.Errorf("Bounds(): have %+v, want %+v", got, want)
+	}
+	if got, want := oslofjord.Bounds(), (ports.BBox{MinLon: 9, MinLat: 58, MaxLon: 12, MaxLat: 61}); got != want {
+		t.Errorf("Bounds(): have %+v, want %+v", got, want)
+	}
+}
+
+func TestPolygonValid(t *testing.T) {
+	square := ring(0, 0, 10, 0, 10, 10, 0, 10)
+	tests := []struct {
+		name    string
+		polygon ports.Polygon
+		want    bool
+	}{
+		{name: "square", polygon: ports.Polygon{square}, want: true},
+		{name: "across the antimeridian", polygon: ports.Polygon{ring(175, -20, -170, -20, -170, -10)}, want: true},
+		{name: "holes", polygon: ports.Polygon{square, ring(1, 1, 2, 1, 2, 2), ring(5, 5, 6, 5, 6, 6)}, want: true},
+		{name: "no rings", polygon: ports.Polygon{}, want: false},
+		{name: "open ring", polygon: ports.Polygon{square[:4]}, want: false},
+		{name: "invalid position", polygon: ports.Polygon{ring(0, 0, 1, 0, 1, 91)}, want: false},
+		{name: "repeated position", polygon: ports.Polygon{ring(0, 0, 1, 0, 1, 0, 1, 1)}, want: false},
+		{name: "crossing edges", polygon: ports.Polygon{ring(0, 0, 1, 1, 1, 0, 0, 1)}, want: false},
+		{name: "touching edges", polygon: ports.Polygon{ring(0, 0, 2, 0, 1, 1, 2, 2, 0, 2, 1, 1)}, want: false},
+		{name: "folding back", polygon: ports.Polygon{ring(0, 0, 2, 0, 1, 0, 1, 1)}, want: false},
+		{name: "on a line", polygon: ports.Polygon{ring(0, 0, 1, 0, 2, 0)}, want: false},
+		{name: "hole outside", polygon: ports.Polygon{square, ring(20, 20, 30, 20, 30, 30)}, want: false},
+		{name: "hole crossing", polygon: ports.Polygon{square, ring(5, 5, 15, 5, 15, 6)}, want: false},
+		{name: "hole within a hole", polygon: ports.Polygon{square, ring(1, 1, 5, 1, 5, 5, 1, 5), ring(2, 2, 3, 2, 3, 3)}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.polygon.Valid(); got != tt.want {
+			t.Errorf("Valid(): %s: have %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServiceListPortsWithin(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}},
+		{ID: "NODRM", Name: "Drammen", Coords: []float64{10.23, 59.73}},
+		{ID: "NOBGO", Name: "Bergen", Coords: []float64{5.32, 60.39}},
+		{ID: "NOHFT", Name: "Hammerfest", Coords: []float64{23.68, 70.66}, Retired: true},
+		{ID: "NOXXX", Name: "Nowhere"},
+		{ID: "FJSUV", Name: "Suva", Coords: []float64{178.44, -18.14}},
+		{ID: "WSAPW", Name: "Apia", Coords: []float64{-171.76, -13.83}},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	s := &ports.Service{Ports: db}
+
+	ids := func(page *ports.Page) []string {
+		var ids []string
+		for _, p := range page.Ports {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Log("Listing ports within a box around Norway, expecting located current ports only, a page at a time")
+	area := ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}}
+	page, err := s.ListPortsWithin(context.TODO(), area, "", 2)
+	if err != nil {
+		t.Fatalf("ListPortsWithin(): %v", err)
+	}
+	if got, want := ids(page), []string{"NOBGO", "NODRM"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPortsWithin(): have %v, want %v", got, want)
+	}
+	page, err = s.ListPortsWithin(context.TODO(), area, page.Next, 2)
+	if err != nil {
+		t.Fatalf("ListPortsWithin(): %v", err)
+	}
+	if got, want := ids(page), []string{"NOOSL"}; !reflect.DeepEqual(got, want) || page.Next != "" {
+		t.Errorf("ListPortsWithin(): have %v, next %q, want %v on the last page", got, page.Next, want)
+	}
+
+	t.Log("Listing ports within polygons, one of them across the antimeridian")
+	area = ports.Area{Polygons: []ports.Polygon{
+		{ring(175, -20, -170, -20, -170, -10)},
+		{ring(9, 58, 12, 58, 12, 61, 9, 61), ring(10.5, 59.8, 11, 59.8, 11, 60, 10.5, 60)},
+	}}
+	page, err = s.ListPortsWithin(context.TODO(), area, "", 0)
+	if err != nil {
+		t.Fatalf("ListPortsWithin(): %v", err)
+	}
+	if got, want := ids(page), []string{"FJSUV", "NODRM", "WSAPW"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPortsWithin(): have %v, want %v", got, want)
+	}
+
+	for i, tt := range []struct {
+		area ports.Area
+		want error
+	}{
+		{area: ports.Area{}, want: ports.ErrInvalidArea},
+		{area: ports.Area{BBox: &ports.BBox{}, Polygons: []ports.Polygon{{ring(0, 0, 1, 0, 1, 1)}}}, want: ports.ErrInvalidArea},
+		{area: ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 72, MaxLon: 32, MaxLat: 57}}, want: ports.ErrInvalidBBox},
+		{area: ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 190, MaxLat: 72}}, want: ports.ErrInvalidBBox},
+		{area: ports.Area{Polygons: []ports.Polygon{{}}}, want: ports.ErrInvalidPolygon},
+		{area: ports.Area{Polygons: []ports.Polygon{{ring(0, 0, 1, 1)}}}, want: ports.ErrInvalidPolygon},
+		{area: ports.Area{Polygons: []ports.Polygon{{ring(0, 0, 1, 0, 1, 1)[:3]}}}, want: ports.ErrInvalidPolygon},
+		{area: ports.Area{Polygons: []ports.Polygon{{ring(0, 0, 1, 0, 1, 91)}}}, want: ports.ErrInvalidPolygon},
+		{area: ports.Area{Polygons: []ports.Polygon{{ring(0, 0, 1, 1, 1, 0, 0, 1)}}}, want: ports.ErrInvalidPolygon},
+	} {
+		if _, err := s.ListPortsWithin(context.TODO(), tt.area, "", 0); !errors.Is(err, tt.want) {
+			t.Errorf("ListPortsWithin(): area %d: have %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func TestServiceListPortsWithinStorageErrors(t *testing.T) {
+	area := ports.Area{Polygons: []ports.Polygon{{ring(0, 0, 1, 0, 1, 1)}}}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "rejected area", err: &ports.Error{Code: ports.ErrCodeInvalid, Msg: "loop is not valid"}, want: ports.ErrCodeInvalid},
+		{name: "failure", err: errors.New("connection reset"), want: ports.ErrCodeInternal},
+	} {
+		s := &ports.Service{Ports: &mock.AreaListFinder{
+			ListPortsWithinFn: func(context.Context, ports.Area, string, int) ([]ports.Port, error) {
+				return nil, tt.err
+			},
+		}}
+		if _, err := s.ListPortsWithin(context.TODO(), area, "", 10); !errors.Is(err, &ports.Error{Code: tt.want}) {
+			t.Errorf("ListPortsWithin(): %s: have %v, want error with code %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestServiceListPortsWithinUnsupported(t *testing.T) {
+	s := &ports.Service{Ports: &mock.InsertFinder{}}
+
+	area := ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}}
+	if _, err := s.ListPortsWithin(context.TODO(), area, "", 10); !errors.Is(err, ports.ErrGeoUnsupported) {
+		t.Errorf("ListPortsWithin(): have %v, want %v", err, ports.ErrGeoUnsupported)
+	}
+}
@@ -0,0 +1,72 @@
+package ports
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// Checksum is a checksum of a dataset of Port records, so that datasets can be
+// compared without comparing each record, e.g. an input file with the storage
+// it has been imported into, or replicas with each other. It adds up the
+// fingerprints of the records, see Hash, so that it does not depend on the
+// order the records are added in. The zero value is the checksum of an empty
+// dataset.
+//
+// Each record should be added once, so that a dataset has a single Port record
+// per identifier, as in storage.
+type Checksum struct {
+	Ports int       // Number of Port records added.
+	Sum   [2]uint64 // Fingerprints of the records added, as two 64-bit halves added up separately.
+}
+
+// Add adds a Port record to the checksum.
+func (c *Checksum) Add(p Port) {
+	fp := fingerprint(p)
+	c.Ports++
+	c.Sum[0] += binary.BigEndian.Uint64(fp[:8])
+	c.Sum[1] += binary.BigEndian.Uint64(fp[8:])
+}
+
+// String returns the sum of the checksum in hexadecimal form.
+func (c Checksum) String() string {
+	b := binary.BigEndian.AppendUint64(nil, c.Sum[0])
+	b = binary.BigEndian.AppendUint64(b, c.Sum[1])
+
+	return hex.EncodeToString(b)
+}
+
+// Scanner can read every Port record in storage.
+type Scanner interface {
+	// ScanPorts calls fn with each Port record in storage, including retired
+	// records, in no particular order. It stops early and returns the error
+	// returned by fn, if any.
+	ScanPorts(ctx context.Context, fn func(p Port) error) error
+}
+
+// ErrScanUnsupported is the error returned when the storage system cannot read
+// every Port record, see Scanner.
+var ErrScanUnsupported = &Error{Code: ErrCodeInternal, Msg: "storage cannot scan ports"}
+
+// Checksum computes the Checksum of the Port records in storage, not counting
+// retired records. It returns an error if the storage system cannot read every
+// record, see Scanner, if it fails, or if the context is cancelled before the
+// operation is completed.
+func (s *Service) Checksum(ctx context.Context) (Checksum, error) {
+	scanner, ok := s.Ports.(Scanner)
+	if !ok {
+		return Checksum{}, ErrScanUnsupported
+	}
+
+	var c Checksum
+	if err := scanner.ScanPorts(ctx, func(p Port) error {
+		if !p.Retired {
+			c.Add(p)
+		}
+		return nil
+	}); err != nil {
+		return Checksum{}, &Error{Code: ErrCodeInternal, Msg: "could not scan ports", Cause: err}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,85 @@
+package ports_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mock"
+)
+
+func TestChecksum(t *testing.T) {
+	ps := []ports.Port{
+		{ID: "AEAJM", Name: "Ajman", Code: "52000", UNLocs: []string{"AEAJM"}},
+		{ID: "AEAUH", Name: "Abu Dhabi", Code: "52001", UNLocs: []string{"AEAUH"}},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005", UNLocs: []string{"AEDXB"}},
+	}
+
+	var forward, backward ports.Checksum
+	for i := range ps {
+		forward.Add(ps[i])
+		backward.Add(ps[len(ps)-1-i])
+	}
+	if got, want := backward, forward; got != want {
+		t.Errorf("Checksum: have %s for reverse order, want %s", got, want)
+	}
+	if got, want := forward.Ports, 3; got != want {
+		t.Errorf("Checksum: have %d ports, want %d", got, want)
+	}
+	if got, want := len(forward.String()), 32; got != want {
+		t.Errorf("Checksum.String(): have %d characters, want %d", got, want)
+	}
+
+	var changed ports.Checksum
+	for _, p := range ps {
+		if p.ID == "AEDXB" {
+			p.Name = "Dubai Port"
+		}
+		changed.Add(p)
+	}
+	if changed == forward {
+		t.Errorf("Checksum: have the same checksum %s after changing a port", changed)
+	}
+
+	var empty ports.Checksum
+	if got, want := empty.String(), "00000000000000000000000000000000"; got != want {
+		t.Errorf("Checksum.String(): have %s for an empty dataset, want %s", got, want)
+	}
+}
+
+func TestServiceChecksum(t *testing.T) {
+	db := inmem.Open()
+	ps := []ports.Port{
+		{ID: "AEAJM", Name: "Ajman", Code: "52000"},
+		{ID: "AEAUH", Name: "Abu Dhabi", Code: "52001"},
+	}
+	var want ports.Checksum
+	for _, p := range ps {
+		want.Add(p)
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	t.Log("Inserting a retired port, expecting it to be left out of the checksum")
+	if err := db.InsertPort(context.TODO(), ports.Port{ID: "AEDXB", Name: "Dubai", Code: "52005", Retired: true}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+
+	service := &ports.Service{Ports: db}
+	got, err := service.Checksum(context.TODO())
+	if err != nil {
+		t.Fatalf("Checksum(): %v", err)
+	}
+	if got != want {
+		t.Errorf("Checksum(): have %d ports with checksum %s, want %d ports with checksum %s", got.Ports, got, want.Ports, want)
+	}
+
+	t.Log("Computing the checksum of storage that cannot scan ports, expecting an error")
+	service = &ports.Service{Ports: &mock.InsertFinder{}}
+	if _, err := service.Checksum(context.TODO()); !errors.Is(err, ports.ErrScanUnsupported) {
+		t.Errorf("Checksum(): have %v, want %v", err, ports.ErrScanUnsupported)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointPrefixSize is the number of bytes from the beginning of each input
+// file hashed into a checkpoint, to detect files modified in place.
+const checkpointPrefixSize = 1 << 20
+
+// checkpointInterval is how often an import in progress saves its checkpoint.
+const checkpointInterval = 5 * time.Second
+
+// checkpoint records how far an import has progressed through its input files,
+// so that an interrupted import can be resumed instead of starting over.
+//
+// Files identify the input files, a checkpoint is only valid for the exact
+// files it was created for, in the same order. File is the index of the input
+// file that Offset and Line refer to. Offset is the input offset right after the
+// last record known to be stored, and Records is the position of that record in
+// the input, counting the records of all files. All records up to and including
+// it have been stored. Line is the line number of that record, for line-based
+// formats.
+type checkpoint struct {
+	Files   []fileStamp `json:"files"`
+	File    int         `json:"file"`
+	Offset  int64       `json:"offset"`
+	Records int         `json:"records"`
+	Line    int         `json:"line,omitempty"`
+}
+
+// fileStamp identifies an input file. Size, ModTime and PrefixHash tell apart
+// files modified since the checkpoint was created.
+type fileStamp struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	PrefixHash string    `json:"prefix_hash"`
+}
+
+// newCheckpoint creates a checkpoint for the beginning of the input files
+// provided. Each file is read from its start to compute the checkpoint, and is
+// rewound afterwards.
+func newCheckpoint(files []inputFile) (checkpoint, error) {
+	var cp checkpoint
+	for _, in := range files {
+		stamp, err := newFileStamp(in)
+		if err != nil {
+			return checkpoint{}, fmt.Errorf("%s: %w", in.path, err)
+		}
+		cp.Files = append(cp.Files, stamp)
+	}
+
+	return cp, nil
+}
+
+// newFileStamp creates a fileStamp for the input file provided, and rewinds it.
+func newFileStamp(in inputFile) (fileStamp, error) {
+	fi, err := in.f.Stat()
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("stat: %w", err)
+	}
+
+	if _, err := in.f.Seek(0, io.SeekStart); err != nil {
+		return fileStamp{}, fmt.Errorf("seek: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(h, in.f, checkpointPrefixSize); err != nil && !errors.Is(err, io.EOF) {
+		return fileStamp{}, fmt.Errorf("hashing: %w", err)
+	}
+	if _, err := in.f.Seek(0, io.SeekStart); err != nil {
+		return fileStamp{}, fmt.Errorf("seek: %w", err)
+	}
+
+	return fileStamp{
+		Path:       in.path,
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime().UTC(),
+		PrefixHash: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// sameFiles reports whether both checkpoints were created for the same files.
+func (cp checkpoint) sameFiles(other checkpoint) bool {
+	if len(cp.Files) != len(other.Files) {
+		return false
+	}
+	for i, f := range cp.Files {
+		o := other.Files[i]
+		if f.Path != o.Path || f.Size != o.Size || !f.ModTime.Equal(o.ModTime) || f.PrefixHash != o.PrefixHash {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadCheckpoint reads a checkpoint from the path provided. It returns false if
+// no checkpoint exists at that path.
+func loadCheckpoint(path string) (checkpoint, bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{}, false, nil
+	} else if err != nil {
+		return checkpoint{}, false, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return checkpoint{}, false, fmt.Errorf("decoding checkpoint %s: %w", path, err)
+	}
+
+	return cp, true, nil
+}
+
+// save writes the checkpoint to the path provided. The checkpoint is written to
+// a temporary file first and then renamed, so that a crash never leaves a
+// partially written checkpoint behind.
+func (cp checkpoint) save(path string) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating checkpoint: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // No-op after a successful rename.
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("renaming checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// watermark tracks the last record of the input up to which every record has
+// been processed. Records complete out of order when stored concurrently, so
+// completions ahead of the watermark are held until the gap before them fills.
+// It is safe for concurrent use by multiple goroutines.
+type watermark struct {
+	mu    sync.Mutex
+	last  job         // The last record up to which all are done.
+	ahead map[int]job // Completed records past the watermark, by position.
+}
+
+// newWatermark creates a watermark starting at the checkpoint provided.
+func newWatermark(cp checkpoint) *watermark {
+	return &watermark{
+		last:  job{Seq: cp.Records, File: cp.File, Line: cp.Line, End: cp.Offset},
+		ahead: make(map[int]job),
+	}
+}
+
+// complete marks the record of the job provided as processed.
+func (w *watermark) complete(j job) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ahead[j.Seq] = job{Seq: j.Seq, File: j.File, Line: j.Line, End: j.End}
+	for {
+		next, ok := w.ahead[w.last.Seq+1]
+		if !ok {
+			return
+		}
+
+		delete(w.ahead, next.Seq)
+		w.last = next
+	}
+}
+
+// checkpoint returns a copy of cp advanced to the current watermark.
+func (w *watermark) checkpoint(cp checkpoint) checkpoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cp.Records, cp.File, cp.Line, cp.Offset = w.last.Seq, w.last.File, w.last.Line, w.last.End
+	return cp
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+	w := newWatermark(checkpoint{Records: 10, Offset: 100})
+
+	w.complete(job{Seq: 12, End: 120})
+	w.complete(job{Seq: 13, End: 130})
+	if got := w.checkpoint(checkpoint{}); got.Records != 10 || got.Offset != 100 {
+		t.Fatalf("checkpoint(): have record %d at offset %d, want record 10 at offset 100", got.Records, got.Offset)
+	}
+
+	w.complete(job{Seq: 11, End: 110})
+	if got := w.checkpoint(checkpoint{}); got.Records != 13 || got.Offset != 130 {
+		t.Fatalf("checkpoint(): have record %d at offset %d, want record 13 at offset 130", got.Records, got.Offset)
+	}
+}
+
+func TestDecodeResume(t *testing.T) {
+	m := Main{Logger: log.New(io.Discard, "", 0)}
+
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "ports.json"))
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	var all []job
+	if err := m.decode(context.TODO(), f, f.Name(), checkpoint{}, func(j job) error {
+		all = append(all, j)
+		return nil
+	}); err != nil {
+		t.Fatalf("decode(): %v", err)
+	}
+
+	// Resume right after the 42nd record.
+	start := checkpoint{Records: all[41].Seq, Offset: all[41].End}
+	if _, err := f.Seek(start.Offset, io.SeekStart); err != nil {
+		t.Fatalf("Seek(): %v", err)
+	}
+
+	var resumed []job
+	if err := m.decode(context.TODO(), f, f.Name(), start, func(j job) error {
+		resumed = append(resumed, j)
+		return nil
+	}); err != nil {
+		t.Fatalf("decode(): resuming at offset %d: %v", start.Offset, err)
+	}
+
+	if got, want := resumed, all[42:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("decode(): resumed with %d records, want the last %d records of %d", len(got), len(want), len(all))
+	}
+}
+
+func TestCheckpointSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "ports.json"))
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	cp, err := newCheckpoint([]inputFile{{path: f.Name(), f: f}})
+	if err != nil {
+		t.Fatalf("newCheckpoint(): %v", err)
+	}
+	cp.Records, cp.Offset = 42, 4242
+
+	if err := cp.save(path); err != nil {
+		t.Fatalf("save(): %v", err)
+	}
+
+	saved, ok, err := loadCheckpoint(path)
+	if err != nil || !ok {
+		t.Fatalf("loadCheckpoint(): have %t, %v, want a checkpoint", ok, err)
+	}
+	if !saved.sameFiles(cp) || saved.Records != cp.Records || saved.Offset != cp.Offset {
+		t.Errorf("loadCheckpoint(): checkpoint mismatch\nhave: %+v\nwant: %+v\n", saved, cp)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes at the beginning of compressed input.
+var (
+	magicGzip = []byte{0x1f, 0x8b}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// zstdMaxWindow is the largest zstd window size accepted, which bounds the
+// memory used for decompression. Files compressed with the default settings of
+// the zstd command-line tool use windows of 8MB or less.
+const zstdMaxWindow = 32 << 20
+
+// openInput prepares file f for decoding, starting at the offset provided. It
+// sniffs the magic bytes at the beginning of the file, and transparently wraps
+// it in a streaming decompressor if it is compressed with gzip or zstd. Offsets
+// refer to the uncompressed input, so compressed input is decompressed and
+// discarded up to the offset, while uncompressed input is simply seeked to it.
+// The file should be positioned at its beginning, and it can only be a pipe,
+// such as standard input, if the offset is zero. Bytes read from the file, as
+// stored, are counted in read, if not nil, which may be loaded concurrently,
+// see progress.
+//
+// It returns the input, and a function that should be used to release any
+// resources held by the decompressor once the input is no longer needed. The
+// file itself is not closed.
+func openInput(f *os.File, offset int64, read *atomic.Int64) (io.Reader, func(), error) {
+	if read == nil {
+		read = new(atomic.Int64)
+	}
+	br := bufio.NewReader(countingReader{r: f, n: read})
+	magic, err := br.Peek(len(magicZstd))
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	var (
+		r       io.Reader
+		closeFn = func() {}
+	)
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		r, closeFn = gr, func() { _ = gr.Close() }
+	case bytes.HasPrefix(magic, magicZstd):
+		zr, err := zstd.NewReader(br,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening zstd stream: %w", err)
+		}
+		r, closeFn = zr, zr.Close
+	default:
+		if offset > 0 {
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return nil, nil, fmt.Errorf("seeking file: %w", err)
+			}
+			read.Store(offset)
+			return countingReader{r: f, n: read}, closeFn, nil
+		}
+
+		return br, closeFn, nil
+	}
+
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+			closeFn()
+			return nil, nil, fmt.Errorf("skipping to offset %d: %w", offset, err)
+		}
+	}
+
+	return r, closeFn, nil
+}
+
+// countingReader counts the bytes read from r in n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
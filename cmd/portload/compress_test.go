@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestOpenInput(t *testing.T) {
+	plain, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ports.json"))
+	if err != nil {
+		t.Fatalf("ReadFile(): %v", err)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(plain); err != nil {
+		t.Fatalf("gzip.Write(): %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip.Close(): %v", err)
+	}
+
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd.NewWriter(): %v", err)
+	}
+	zst := zw.EncodeAll(plain, nil)
+
+	const offset = 4242
+	for name, content := range map[string][]byte{
+		"ports.json":     plain,
+		"ports.json.gz":  gz.Bytes(),
+		"ports.json.zst": zst,
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open(): %v", err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+
+		var read atomic.Int64
+		in, closeFn, err := openInput(f, offset, &read)
+		if err != nil {
+			t.Fatalf("openInput(%s): %v", name, err)
+		}
+		got, err := io.ReadAll(in)
+		closeFn()
+		if err != nil {
+			t.Fatalf("ReadAll(%s): %v", name, err)
+		}
+
+		if !bytes.Equal(got, plain[offset:]) {
+			t.Errorf("openInput(%s): have %d bytes past offset %d, want %d", name, len(got), offset, len(plain)-offset)
+		}
+		if got, want := read.Load(), int64(len(content)); got != want {
+			t.Errorf("openInput(%s): have %d bytes read from the file, want %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/christgf/ports"
+)
+
+// diff describes what importing the input would change in storage, port by
+// port. It is safe for concurrent use by multiple goroutines.
+//
+// Only the latest change of each port is held in memory, by hash of the port
+// ID, so that memory use stays modest for files with millions of records. The
+// details of the ports added or modified are spilled to a temporary file, and
+// read back once every port has been compared, see write.
+type diff struct {
+	Added     int
+	Modified  int
+	Unchanged int
+	Invalid   int
+
+	mu      sync.Mutex
+	finder  ports.Finder
+	rules   *ports.RuleSet
+	changes map[uint64]latestChange // Latest change, by hash of the port ID.
+	spill   *os.File                // Details of the ports added or modified, one portDiff per line.
+	size    int64                   // Size of spill.
+}
+
+// latestChange is the latest change of a port, along with the position of its
+// details in the spill file of a diff, if added or modified.
+type latestChange struct {
+	change ports.Change
+	offset int64
+}
+
+// portDiff describes what importing the input would change for a single port.
+type portDiff struct {
+	ID     string      `json:"id"`
+	Change string      `json:"change"`           // Either added or modified.
+	Port   *inputPort  `json:"port,omitempty"`   // The port added.
+	Fields []fieldDiff `json:"fields,omitempty"` // The fields modified.
+}
+
+// fieldDiff is a port field that would be modified by the import.
+type fieldDiff struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// newDiff creates an empty diff, comparing ports with the ones in storage, once
+// validated against the rules provided. It returns an error if the spill file
+// cannot be created. The diff should be closed once written.
+func newDiff(finder ports.Finder, rules *ports.RuleSet) (*diff, error) {
+	spill, err := os.CreateTemp("", "portload-diff-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("creating spill file: %w", err)
+	}
+
+	return &diff{
+		finder:  finder,
+		rules:   rules,
+		changes: make(map[uint64]latestChange),
+		spill:   spill,
+	}, nil
+}
+
+// Close removes the spill file of the diff.
+func (d *diff) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_ = d.spill.Close()
+	return os.Remove(d.spill.Name())
+}
+
+// hashPortID returns the 64-bit hash of a port ID, as the diff tracks it.
+func hashPortID(portID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(portID))
+	return h.Sum64()
+}
+
+// compare implements storeFunc, comparing ports with the ones in storage instead
+// of storing them. Ports that would be rejected are reported with an
+// ErrCodeInvalid ports.Error, as ports.Service.StorePorts would. The result
+// for each port replaces any earlier result for the same port ID, so that the
+// last occurrence in the input wins, provided ports with the same ID are
+// compared in input order.
+func (d *diff) compare(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+	results := make([]ports.Result, len(ps))
+	for i, p := range ps {
+		results[i].ID = p.ID
+		if _, err := d.rules.Validate(p); err != nil {
+			results[i].Err = &ports.Error{Code: ports.ErrCodeInvalid, Msg: err.Error(), Cause: err}
+			continue
+		}
+
+		stored, err := d.finder.FindPort(ctx, p.ID)
+		if err != nil && !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+			return nil, fmt.Errorf("finding port %q: %w", p.ID, err)
+		}
+
+		pd, change := &portDiff{ID: p.ID}, ports.ChangeUnchanged
+		switch {
+		case stored == nil:
+			in := newInputPort(p)
+			pd.Change, pd.Port, change = "added", &in, ports.ChangeCreated
+		default:
+			if pd.Fields = diffPorts(*stored, p); len(pd.Fields) > 0 {
+				pd.Change, change = "modified", ports.ChangeUpdated
+			}
+		}
+
+		if err := d.record(pd, change); err != nil {
+			return nil, err
+		}
+		results[i].Change = change
+	}
+
+	return results, nil
+}
+
+// record replaces any earlier result for the port with the one provided,
+// spilling its details if the port would be added or modified.
+func (d *diff) record(pd *portDiff, change ports.Change) error {
+	var line []byte
+	if change != ports.ChangeUnchanged {
+		var err error
+		if line, err = json.Marshal(pd); err != nil {
+			return fmt.Errorf("encoding port %q: %w", pd.ID, err)
+		}
+		line = append(line, '\n')
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	latest := latestChange{change: change, offset: d.size}
+	if len(line) > 0 {
+		if _, err := d.spill.Write(line); err != nil {
+			return fmt.Errorf("writing spill file: %w", err)
+		}
+		d.size += int64(len(line))
+	}
+	d.changes[hashPortID(pd.ID)] = latest
+
+	return nil
+}
+
+// invalid counts a record that would be rejected.
+func (d *diff) invalid() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Invalid++
+}
+
+// spilled is the position of the details of a port in the spill file.
+type spilled struct {
+	id     string
+	offset int64
+	size   int
+}
+
+// latestSpilled reads the spill file back, and returns the position of the
+// latest details of each port added or modified, sorted by port ID. Only the
+// IDs of those ports are held in memory.
+func (d *diff) latestSpilled() ([]spilled, error) {
+	var (
+		latest []spilled
+		offset int64
+	)
+	r := bufio.NewReader(io.NewSectionReader(d.spill, 0, d.size))
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading spill file: %w", err)
+		}
+
+		var pd struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(line, &pd); err != nil {
+			return nil, fmt.Errorf("decoding spill file: %w", err)
+		}
+		if lc := d.changes[hashPortID(pd.ID)]; lc.change != ports.ChangeUnchanged && lc.offset == offset {
+			latest = append(latest, spilled{id: pd.ID, offset: offset, size: len(line)})
+		}
+		offset += int64(len(line))
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].id < latest[j].id })
+
+	return latest, nil
+}
+
+// write the diff to w, in the format provided, once every port has been
+// compared. The ports added or modified are listed sorted by ID, read back from
+// the spill file one at a time.
+func (d *diff) write(w io.Writer, format string) error {
+	if format != reportText && format != reportJSON {
+		return fmt.Errorf("unsupported report format %q, use %q or %q", format, reportText, reportJSON)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Added, d.Modified, d.Unchanged = 0, 0, 0
+	for _, lc := range d.changes {
+		switch lc.change {
+		case ports.ChangeCreated:
+			d.Added++
+		case ports.ChangeUpdated:
+			d.Modified++
+		case ports.ChangeUnchanged:
+			d.Unchanged++
+		}
+	}
+
+	latest, err := d.latestSpilled()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if format == reportJSON {
+		_, _ = fmt.Fprintf(bw, "{\n  \"added\": %d,\n  \"modified\": %d,\n  \"unchanged\": %d,\n  \"invalid\": %d,\n  \"ports\": [",
+			d.Added, d.Modified, d.Unchanged, d.Invalid)
+	} else {
+		tw := tabwriter.NewWriter(bw, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Added:\t%d\n", d.Added)
+		_, _ = fmt.Fprintf(tw, "Modified:\t%d\n", d.Modified)
+		_, _ = fmt.Fprintf(tw, "Unchanged:\t%d\n", d.Unchanged)
+		_, _ = fmt.Fprintf(tw, "Invalid:\t%d\n", d.Invalid)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var buf []byte
+	for i, sp := range latest {
+		buf = slices.Grow(buf[:0], sp.size)[:sp.size]
+		if _, err := d.spill.ReadAt(buf, sp.offset); err != nil {
+			return fmt.Errorf("reading spill file: %w", err)
+		}
+		var pd portDiff
+		if err := json.Unmarshal(buf, &pd); err != nil {
+			return fmt.Errorf("decoding spill file: %w", err)
+		}
+
+		if format == reportJSON {
+			b, err := json.MarshalIndent(pd, "    ", "  ")
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				_, _ = bw.WriteString(",")
+			}
+			_, _ = fmt.Fprintf(bw, "\n    %s", b)
+			continue
+		}
+
+		if pd.Change == "added" {
+			_, _ = fmt.Fprintf(bw, "\n+ %s\n", pd.ID)
+			continue
+		}
+		_, _ = fmt.Fprintf(bw, "\n~ %s\n", pd.ID)
+		for _, fd := range pd.Fields {
+			_, _ = fmt.Fprintf(bw, "    %s: %s -> %s\n", fd.Field, formatValue(fd.Before), formatValue(fd.After))
+		}
+	}
+	if format == reportJSON {
+		if len(latest) > 0 {
+			_, _ = bw.WriteString("\n  ")
+		}
+		_, _ = bw.WriteString("]\n}\n")
+	}
+
+	return bw.Flush()
+}
+
+// diffPorts compares two ports field by field, and returns the fields that
+// differ, named as in the input file. Nil and empty lists are considered the
+// same, as in ports.Hash.
+func diffPorts(before, after ports.Port) []fieldDiff {
+	fields := []struct {
+		name          string
+		before, after any
+	}{
+		{"name", before.Name, after.Name},
+		{"code", before.Code, after.Code},
+		{"city", before.City, after.City},
+		{"province", before.Province, after.Province},
+		{"country", before.Country, after.Country},
+		{"alias", emptyNil(before.Alias), emptyNil(after.Alias)},
+		{"regions", emptyNil(before.Regions), emptyNil(after.Regions)},
+		{"timezone", before.Timezone, after.Timezone},
+		{"unlocs", emptyNil(before.UNLocs), emptyNil(after.UNLocs)},
+		{"coordinates", emptyNil(before.Coords), emptyNil(after.Coords)},
+		{"retired", before.Retired, after.Retired},
+	}
+
+	var diffs []fieldDiff
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.before, f.after) {
+			diffs = append(diffs, fieldDiff{Field: f.name, Before: f.before, After: f.after})
+		}
+	}
+
+	return diffs
+}
+
+// emptyNil returns nil for empty lists, and the list provided otherwise.
+func emptyNil[T any](list []T) []T {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list
+}
+
+// formatValue formats a field value for the text diff, as JSON.
+func formatValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// Diff executes the diff command of Main. It decodes the input files as
+// described in Main.Run, compares each port with the one in the storage system
+// selected by Main.Conf.Store, or served by the ports HTTP API at
+// Main.Conf.Target, and writes what importing the files would change to
+// Main.Stdout, in Main.Conf.ReportFormat. Records are validated against the
+// rules loaded by loadRules, and nothing is written to storage. Ports are
+// transformed as they would be by an import, see transform, and looked up
+// concurrently by Main.Conf.Workers workers, see pipeline, and the last
+// occurrence of a port in the input is the one compared.
+func (m Main) Diff(ctx context.Context) error {
+	if f := m.Conf.ReportFormat; f != reportText && f != reportJSON {
+		return fmt.Errorf("unsupported report format %q, use %q or %q", f, reportText, reportJSON)
+	}
+
+	files, closeFiles, err := m.openFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles()
+
+	chain, err := m.loadTransforms()
+	if err != nil {
+		return err
+	}
+
+	var finder ports.Finder
+	if m.Conf.Target != "" {
+		client, err := m.openTarget()
+		if err != nil {
+			return err
+		}
+		finder = remoteFinder{client}
+	} else {
+		store, closeFn, err := m.openStore(ctx, false)
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+		finder = store
+	}
+
+	rules, err := m.loadRules()
+	if err != nil {
+		return err
+	}
+
+	d, err := newDiff(finder, rules)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	pl := newPipeline(ctx, m.Conf.Workers, m.Conf.BatchSize, d.compare, func(j job, err error) error {
+		if err != nil {
+			d.invalid()
+		}
+
+		return nil
+	})
+
+	err = m.decodeFiles(ctx, files, checkpoint{}, chain.apply(pl.Submit))
+	if waitErr := pl.Wait(); waitErr != nil {
+		err = waitErr // Storage failures take precedence.
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := d.write(m.Stdout, m.Conf.ReportFormat); err != nil {
+		return fmt.Errorf("writing diff: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestDiff(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "AEAJM", Name: "Ajman", Code: "52000", Alias: []string{}},
+		{ID: "AEAUH", Name: "Abu Dhabi", Code: "52001", UNLocs: []string{"AEAUH"}},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005"},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	d, err := newDiff(db, nil)
+	if err != nil {
+		t.Fatalf("newDiff(): %v", err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+
+	pl := newPipeline(context.TODO(), 4, 2, d.compare, func(j job, err error) error {
+		if err != nil {
+			d.invalid()
+		}
+		return nil
+	})
+	for i, p := range []ports.Port{
+		{ID: "AEAJM", Name: "Ajman", Code: "52000"},
+		{ID: "AEAUH", Name: "Abu Dhabi", Code: "52002", UNLocs: []string{"AEAUH"}},
+		{ID: "AEDXB", Name: "Dubai City", Code: "52005"},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005"}, // Back to the stored version.
+		{ID: "AEFJR", Name: "Al Fujayrah", Code: "52051"},
+		{ID: "AEKLF", Name: "Khor al Fakkan"},
+	} {
+		if err := pl.Submit(job{Seq: i + 1, Port: p}); err != nil {
+			t.Fatalf("Submit(): %v", err)
+		}
+	}
+	if err := pl.Wait(); err != nil {
+		t.Fatalf("Wait(): %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.write(&buf, reportJSON); err != nil {
+		t.Fatalf("write(): %v", err)
+	}
+
+	var got struct {
+		Added, Modified, Unchanged, Invalid int
+		Ports                               []struct {
+			ID, Change string
+			Fields     []fieldDiff
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+
+	if got.Added != 1 || got.Modified != 1 || got.Unchanged != 2 || got.Invalid != 1 {
+		t.Errorf("write(): have %d added, %d modified, %d unchanged, %d invalid, want 1, 1, 2, 1", got.Added, got.Modified, got.Unchanged, got.Invalid)
+	}
+	if len(got.Ports) != 2 || got.Ports[0].ID != "AEAUH" || got.Ports[1].ID != "AEFJR" || got.Ports[1].Change != "added" {
+		t.Fatalf("write(): have ports %+v, want AEAUH modified and AEFJR added", got.Ports)
+	}
+	if got, want := got.Ports[0].Fields, []fieldDiff{{Field: "code", Before: "52001", After: "52002"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("write(): have fields %+v, want %+v", got, want)
+	}
+
+	buf.Reset()
+	if err := d.write(&buf, reportText); err != nil {
+		t.Fatalf("write(): %v", err)
+	}
+	for _, want := range []string{"Added:      1\n", "+ AEFJR\n", "~ AEAUH\n    code: \"52001\" -> \"52002\"\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("write(): have text\n%s\nwant it to contain %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/christgf/ports"
+)
+
+// stdinPath is the input file path standing for standard input.
+const stdinPath = "-"
+
+// inputFile is an input file, opened for reading.
+type inputFile struct {
+	path string
+	f    *os.File
+	read *atomic.Int64 // Bytes read from f for decoding, see openInput.
+}
+
+// openFiles opens the files at Main.Conf.FilePaths for reading, in order, so
+// that missing files are reported before anything is imported. Standard input
+// is used for stdinPath, which can only appear once. It returns the files, and a
+// function that should be used to close them once they are no longer needed.
+func (m Main) openFiles() ([]inputFile, func(), error) {
+	var files []inputFile
+	closeFn := func() {
+		for _, in := range files {
+			if in.f == os.Stdin {
+				continue
+			}
+			if err := in.f.Close(); err != nil {
+				m.Logger.Printf("closing file %s: %v", in.path, err)
+			}
+		}
+	}
+
+	stdin := false
+	for _, path := range m.Conf.FilePaths {
+		if path == stdinPath {
+			if stdin {
+				closeFn()
+				return nil, nil, errors.New("standard input can only be read once")
+			}
+			stdin = true
+			files = append(files, inputFile{path: path, f: os.Stdin, read: new(atomic.Int64)})
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			closeFn()
+			return nil, nil, fmt.Errorf("opening file: %w", err)
+		}
+		files = append(files, inputFile{path: path, f: f, read: new(atomic.Int64)})
+	}
+
+	if len(files) == 0 {
+		return nil, nil, errors.New("no input files")
+	}
+
+	return files, closeFn, nil
+}
+
+// pathsFlag is a flag.Value for file paths, collecting the value of each
+// occurrence of the flag, in order.
+type pathsFlag []string
+
+// String implements flag.Value.
+func (p *pathsFlag) String() string {
+	return strings.Join(*p, " ")
+}
+
+// Set implements flag.Value.
+func (p *pathsFlag) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// sources keeps track of the input file supplying the version of each port
+// stored last, when importing more than one file. It is safe for concurrent use
+// by multiple goroutines.
+//
+// Port IDs are tracked as 64-bit hashes, as in report, so that memory use stays
+// modest for files with millions of records. Only the IDs of ports changed by
+// more than one file are kept, since those are the ones summary names.
+type sources struct {
+	mu      sync.Mutex
+	paths   []string          // Paths of the input files, in order.
+	latest  map[uint64]source // Latest version stored, by hash of the port ID.
+	changed map[uint64]string // IDs of the ports changed by more than one file, by hash.
+}
+
+// source describes where the latest version of a port stored comes from.
+type source struct {
+	file     int // Index of the input file.
+	versions int // Number of input files a different version has been stored from.
+}
+
+// newSources creates sources for the input files provided.
+func newSources(files []inputFile) *sources {
+	s := &sources{latest: make(map[uint64]source), changed: make(map[uint64]string)}
+	for _, in := range files {
+		s.paths = append(s.paths, in.path)
+	}
+
+	return s
+}
+
+// stored records the version of the port stored by the job provided. Versions
+// of the same port must be recorded in input order. Versions left unchanged in
+// storage are credited to the file that supplied them first.
+func (s *sources) stored(j job) {
+	if len(s.paths) < 2 {
+		return // Nothing to tell apart.
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(j.Port.ID))
+	key := h.Sum64()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.latest[key]
+	if ok && (src.file == j.File || j.Change == ports.ChangeUnchanged) {
+		return
+	}
+
+	src.file = j.File
+	src.versions++
+	s.latest[key] = src
+	if src.versions > 1 {
+		s.changed[key] = j.Port.ID
+	}
+}
+
+// summary returns a line for each input file, with the number of ports whose
+// latest version comes from it, followed by a line for each port changed by
+// more than one file, naming the file that supplied its latest version. Ports
+// are sorted by ID.
+func (s *sources) summary() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.paths) < 2 {
+		return nil
+	}
+
+	latest := make([]int, len(s.paths))
+	for _, src := range s.latest {
+		latest[src.file]++
+	}
+
+	keys := make([]uint64, 0, len(s.changed))
+	for key := range s.changed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return s.changed[keys[i]] < s.changed[keys[j]] })
+
+	lines := make([]string, 0, len(s.paths)+len(keys))
+	for i, path := range s.paths {
+		lines = append(lines, fmt.Sprintf("File %s: latest version of %d ports", path, latest[i]))
+	}
+	for _, key := range keys {
+		id, src := s.changed[key], s.latest[key]
+		lines = append(lines, fmt.Sprintf("Port %q: latest version from %s, stored from %d files", id, s.paths[src.file], src.versions))
+	}
+
+	return lines
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestDecodeFiles(t *testing.T) {
+	dir := t.TempDir()
+	var files []inputFile
+	for i, content := range []string{
+		`{"id": "AEAJM", "name": "Ajman"}` + "\n" + `{"id": "AEAUH", "name": "Abu Dhabi"}` + "\n",
+		`{"AEAJM": {"name": "Ajman, again"}, "AEDXB": {"name": "Dubai"}}`,
+		`{"id": "AEAUH", "name": "Abu Dhabi, again"}` + "\n",
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("ports-%d", i))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open(): %v", err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+		files = append(files, inputFile{path: path, f: f})
+	}
+
+	m := Main{Logger: log.New(io.Discard, "", 0)}
+	var all []job
+	if err := m.decodeFiles(context.TODO(), files, checkpoint{}, func(j job) error {
+		all = append(all, j)
+		return nil
+	}); err != nil {
+		t.Fatalf("decodeFiles(): %v", err)
+	}
+
+	type record struct {
+		Seq, File int
+		ID        string
+	}
+	var got []record
+	for _, j := range all {
+		got = append(got, record{j.Seq, j.File, j.Port.ID})
+	}
+	want := []record{{1, 0, "AEAJM"}, {2, 0, "AEAUH"}, {3, 1, "AEAJM"}, {4, 1, "AEDXB"}, {5, 2, "AEAUH"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeFiles():\nhave: %v\nwant: %v", got, want)
+	}
+
+	// Resume right after the first record of the second file.
+	t.Log("Resuming in the second file")
+	for _, in := range files {
+		if _, err := in.f.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("Seek(): %v", err)
+		}
+	}
+	start := checkpoint{File: all[2].File, Records: all[2].Seq, Offset: all[2].End}
+	var resumed []record
+	if err := m.decodeFiles(context.TODO(), files, start, func(j job) error {
+		resumed = append(resumed, record{j.Seq, j.File, j.Port.ID})
+		return nil
+	}); err != nil {
+		t.Fatalf("decodeFiles(): %v", err)
+	}
+	if !reflect.DeepEqual(resumed, want[3:]) {
+		t.Errorf("decodeFiles(): resumed\nhave: %v\nwant: %v", resumed, want[3:])
+	}
+}
+
+func TestSources(t *testing.T) {
+	src := newSources([]inputFile{{path: "base.json"}, {path: "delta-1.json"}, {path: "delta-2.json"}})
+	for _, j := range []job{
+		{File: 0, Port: ports.Port{ID: "AEAJM"}},
+		{File: 0, Port: ports.Port{ID: "AEAUH"}},
+		{File: 0, Port: ports.Port{ID: "AEDXB"}},
+		{File: 1, Port: ports.Port{ID: "AEAUH"}},
+		{File: 1, Port: ports.Port{ID: "AEAUH"}},
+		{File: 2, Port: ports.Port{ID: "AEAUH"}},
+		{File: 2, Port: ports.Port{ID: "AEAJM"}},
+		{File: 2, Port: ports.Port{ID: "AEDXB"}, Change: ports.ChangeUnchanged},
+	} {
+		src.stored(j)
+	}
+
+	want := []string{
+		"File base.json: latest version of 1 ports",
+		"File delta-1.json: latest version of 0 ports",
+		"File delta-2.json: latest version of 2 ports",
+		`Port "AEAJM": latest version from delta-2.json, stored from 2 files`,
+		`Port "AEAUH": latest version from delta-2.json, stored from 3 files`,
+	}
+	if got := src.summary(); !reflect.DeepEqual(got, want) {
+		t.Errorf("summary():\nhave: %q\nwant: %q", got, want)
+	}
+}
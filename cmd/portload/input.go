@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/christgf/ports"
+)
+
+// Formats supported by Config.Format.
+const (
+	formatAuto     = "auto"     // Detect the format from the file name or contents.
+	formatJSON     = "json"     // One JSON object, with port IDs as object fields.
+	formatNDJSON   = "ndjson"   // Newline-delimited JSON, one port per line.
+	formatUNLOCODE = "unlocode" // UN/LOCODE code list, in CSV format.
+)
+
+// inputPort is the representation of a port record in the input file, as a
+// JSON object. The port identifier is not part of the record, it is the name of
+// the field holding it.
+type inputPort struct {
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	City        string    `json:"city"`
+	Province    string    `json:"province"`
+	Country     string    `json:"country"`
+	Alias       []string  `json:"alias"`
+	Regions     []string  `json:"regions"`
+	Timezone    string    `json:"timezone"`
+	UNLocs      []string  `json:"unlocs"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// port returns the ports.Port described by the record, with the identifier
+// provided.
+func (in inputPort) port(portID string) ports.Port {
+	return ports.Port{
+		ID:       portID,
+		Name:     in.Name,
+		Code:     in.Code,
+		City:     in.City,
+		Province: in.Province,
+		Country:  in.Country,
+		Alias:    in.Alias,
+		Regions:  in.Regions,
+		Timezone: in.Timezone,
+		UNLocs:   in.UNLocs,
+		Coords:   in.Coordinates,
+	}
+}
+
+// newInputPort returns the representation of a ports.Port as a record of the
+// input file, without its identifier.
+func newInputPort(p ports.Port) inputPort {
+	return inputPort{
+		Name:        p.Name,
+		Code:        p.Code,
+		City:        p.City,
+		Province:    p.Province,
+		Country:     p.Country,
+		Alias:       p.Alias,
+		Regions:     p.Regions,
+		Timezone:    p.Timezone,
+		UNLocs:      p.UNLocs,
+		Coordinates: p.Coords,
+	}
+}
+
+// unmarshalRecord decodes a single record of the input file into v. In strict
+// mode, fields unknown to v are not allowed. Values of the wrong type are left
+// out of v, and reported with an error, see json.Unmarshal.
+func unmarshalRecord(raw []byte, v any, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	return decoder.Decode(v)
+}
+
+// unmarshalPort decodes a single record of the input file into a ports.Port,
+// with the identifier provided. In strict mode, records with fields unknown to
+// inputPort, or with values of the wrong type, are rejected with an error.
+// Otherwise unknown fields are ignored, and values of the wrong type are left
+// out of the port, which is returned along with the error describing them.
+func unmarshalPort(portID string, raw []byte, strict bool) (ports.Port, error) {
+	var in inputPort
+	if err := unmarshalRecord(raw, &in, strict); err != nil {
+		if strict {
+			return ports.Port{}, err
+		}
+
+		return in.port(portID), err
+	}
+
+	return in.port(portID), nil
+}
+
+// recordReader reads the records of an input file, one at a time.
+type recordReader interface {
+	// Read returns the next record of the input, or io.EOF once there are no
+	// more records. Records that can be told apart from the rest of the input,
+	// but not decoded into a port, are returned with job.Err set. Errors that
+	// prevent reading any further are returned as errors.
+	Read() (job, error)
+}
+
+// decode reads ports information from r, as described in Main.Run, and passes
+// each port decoded to fn, along with its position in the input. It returns
+// early if the context is cancelled, if fn returns an error, or if the rest of
+// the input cannot be read.
+//
+// The format of the input is Main.Conf.Format, or detected from the name of the
+// file at path and the beginning of the input, see detectFormat. Records that
+// cannot be decoded into a port are passed to fn with job.Err set to an
+// ErrCodeInvalid ports.Error when Main.Conf.Strict is set, or when the format
+// allows skipping them. Otherwise the offending values are left out.
+//
+// When resuming from a checkpoint, r should be positioned at the checkpoint
+// offset, right after a record, and records are numbered following the
+// checkpoint.
+func (m Main) decode(ctx context.Context, r io.Reader, path string, start checkpoint, fn func(j job) error) error {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn() // Stop any readers working ahead.
+
+	br := bufio.NewReaderSize(r, sniffSize)
+	format := m.Conf.Format
+	if format == "" || format == formatAuto {
+		format = detectFormat(path, br)
+	}
+
+	var rr recordReader
+	switch format {
+	case formatJSON:
+		rr = newObjectReader(br, start, m.Conf.Strict, m.Logger)
+	case formatNDJSON:
+		rr = newLineReader(ctx, br, start, m.Conf.Strict, m.Conf.Workers, m.Logger)
+	case formatUNLOCODE:
+		rr = newUNLOCODEReader(br, start, m.Logger)
+	default:
+		return fmt.Errorf("unsupported format %q, use %q, %q, %q or %q", format, formatAuto, formatJSON, formatNDJSON, formatUNLOCODE)
+	}
+
+	for {
+		// Check for context cancellation, abort if context is cancelled.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		j, err := rr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(j); err != nil {
+			return err
+		}
+	}
+}
+
+// sniffSize is the size of the input buffer used by decode, and the maximum
+// number of bytes examined by detectFormat.
+const sniffSize = 64 << 10
+
+// detectFormat guesses the format of the input, first from the extension of
+// the file at path, ignoring any compression extensions, and then from the
+// beginning of the input itself: input starting with a line that holds a
+// complete JSON object with an "id" field is newline-delimited JSON. CSV files
+// are taken for the UN/LOCODE code list. The input is examined without being
+// consumed.
+func detectFormat(path string, br *bufio.Reader) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".zst")
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	case ".json":
+		return formatJSON
+	case ".csv":
+		return formatUNLOCODE
+	}
+
+	head, _ := br.Peek(sniffSize)
+	line, _, _ := bytes.Cut(bytes.TrimLeft(head, " \t\r\n"), []byte("\n"))
+	var v struct {
+		ID *string `json:"id"`
+	}
+	if err := json.Unmarshal(line, &v); err == nil && v.ID != nil {
+		return formatNDJSON
+	}
+
+	return formatJSON
+}
+
+// objectReader reads records from one big JSON object, with port identifiers
+// as object fields, as described in Main.Run.
+type objectReader struct {
+	decoder *json.Decoder
+	base    int64 // Offset of the decoder input in the file.
+	seq     int   // Position of the last record read.
+	strict  bool
+	logger  *log.Logger
+	err     error // Set if the input cannot be read any further.
+	opened  bool  // Set once the opening token has been read.
+}
+
+// newObjectReader creates an objectReader for r. When resuming from a
+// checkpoint, r should be positioned right after a record in the middle of the
+// JSON object.
+func newObjectReader(r *bufio.Reader, start checkpoint, strict bool, logger *log.Logger) *objectReader {
+	or := &objectReader{seq: start.Records, strict: strict, logger: logger}
+	if start.Offset > 0 {
+		// Skip the separator following the last record stored, and make the
+		// rest of the input look like a JSON object of its own again.
+		n, err := skipSeparator(r)
+		if err != nil {
+			or.err = fmt.Errorf("resuming at offset %d: %w", start.Offset, err)
+		}
+
+		or.base = start.Offset + n - 1
+		or.decoder = json.NewDecoder(io.MultiReader(strings.NewReader("{"), r))
+		return or
+	}
+
+	or.decoder = json.NewDecoder(r)
+	return or
+}
+
+// Read implements recordReader. In strict mode, records that are valid JSON
+// but do not match inputPort are returned with job.Err set, otherwise the
+// offending values are left out of the port and logged. Input that is not valid
+// JSON cannot be read any further.
+func (or *objectReader) Read() (job, error) {
+	if or.err != nil {
+		return job{}, or.err
+	}
+
+	j, err := or.read()
+	if err != nil {
+		or.err = err
+	}
+
+	return j, err
+}
+
+// read the next record from the JSON object.
+func (or *objectReader) read() (job, error) {
+	if !or.opened {
+		// Read first, opening token, `[` or `{`
+		if _, err := or.decoder.Token(); err != nil {
+			return job{}, fmt.Errorf("decoding opening token: %w", err)
+		}
+		or.opened = true
+	}
+
+	if !or.decoder.More() {
+		// Read last, closing token.
+		if _, err := or.decoder.Token(); err != nil {
+			return job{}, fmt.Errorf("decoding closing token: %w", err)
+		}
+
+		return job{}, io.EOF
+	}
+
+	or.seq++
+	portID, err := or.decoder.Token() // Decode the unique identifier for the port.
+	if err != nil {
+		return job{}, fmt.Errorf("decoding port ID: %w", err)
+	}
+
+	id, _ := portID.(string)
+	offset := or.base + or.decoder.InputOffset()
+
+	// Decode the rest of the information. Each record gets a value of its own,
+	// since it is handed over to another goroutine.
+	var raw json.RawMessage
+	if err := or.decoder.Decode(&raw); err != nil {
+		return job{}, fmt.Errorf("decoding port %q at offset %d: %w", id, offset, err)
+	}
+
+	j := job{Seq: or.seq, Offset: offset, End: or.base + or.decoder.InputOffset(), Raw: raw}
+	j.Port, err = unmarshalPort(id, raw, or.strict)
+	if err != nil {
+		err = fmt.Errorf("port %q at offset %d: %w", id, offset, err)
+		if !or.strict {
+			or.logger.Printf("%d: Ignoring unexpected values, %v", or.seq, err)
+		} else {
+			j.Port.ID = id
+			j.Err = &ports.Error{Code: ports.ErrCodeInvalid, Msg: err.Error(), Cause: err}
+		}
+	}
+
+	return j, nil
+}
+
+// skipSeparator consumes whitespace and at most one comma from r, stopping
+// before any other character. It returns the number of bytes consumed.
+func skipSeparator(r *bufio.Reader) (int64, error) {
+	var n int64
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			n++
+		case ',':
+			return n + 1, nil
+		default:
+			return n, r.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestUnmarshalPort(t *testing.T) {
+	raw := []byte(`{
+		"name": "Ajman",
+		"city": "Ajman",
+		"country": "United Arab Emirates",
+		"alias": [],
+		"regions": [],
+		"coordinates": [55.5136433, 25.4052165],
+		"province": "Ajman",
+		"timezone": "Asia/Dubai",
+		"unlocs": ["AEAJM"],
+		"code": "52000"
+	}`)
+
+	p, err := unmarshalPort("AEAJM", raw, true)
+	if err != nil {
+		t.Fatalf("unmarshalPort(): %v", err)
+	}
+
+	want := ports.Port{
+		ID:       "AEAJM",
+		Name:     "Ajman",
+		Code:     "52000",
+		City:     "Ajman",
+		Province: "Ajman",
+		Country:  "United Arab Emirates",
+		Alias:    []string{},
+		Regions:  []string{},
+		Timezone: "Asia/Dubai",
+		UNLocs:   []string{"AEAJM"},
+		Coords:   []float64{55.5136433, 25.4052165},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("unmarshalPort(): port mismatch\nhave: %+v\nwant: %+v\n", p, want)
+	}
+}
+
+func TestUnmarshalPortStrict(t *testing.T) {
+	tests := []struct {
+		raw    string
+		strict bool
+		want   ports.Port
+		err    bool
+	}{
+		{
+			raw:    `{"name": "Ajman", "code": "52000", "population": 504846}`,
+			strict: false,
+			want:   ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000"},
+		},
+		{
+			raw:    `{"name": "Ajman", "code": "52000", "population": 504846}`,
+			strict: true,
+			err:    true,
+		},
+		{
+			raw:    `{"name": "Ajman", "code": 52000}`,
+			strict: false,
+			want:   ports.Port{ID: "AEAJM", Name: "Ajman"},
+			err:    true,
+		},
+		{
+			raw:    `{"name": "Ajman", "code": 52000}`,
+			strict: true,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := unmarshalPort("AEAJM", []byte(tt.raw), tt.strict)
+		if gotErr := err != nil; gotErr != tt.err {
+			t.Errorf("unmarshalPort(%s, %t): have error %v, want error %t", tt.raw, tt.strict, err, tt.err)
+		}
+		if tt.strict {
+			continue
+		}
+
+		if !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("unmarshalPort(%s, %t): port mismatch\nhave: %+v\nwant: %+v\n", tt.raw, tt.strict, p, tt.want)
+		}
+	}
+}
@@ -1,40 +1,93 @@
 // Package main is a command-line utility for importing port records from a
-// JSON file into a database.
+// JSON file into a database, for previewing what an import would change, for
+// verifying that the database matches the file once imported, and for checking
+// the records in the database against the current validation rules.
 package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mongo"
 )
 
 func main() {
 	ctx, cancelFn := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancelFn()
 
-	if err := run(ctx); err != nil {
+	if err := run(ctx, os.Args[1:]); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
 		cancelFn()
-		os.Exit(1)
+		if errors.Is(err, errRejected) {
+			os.Exit(exitRejected)
+		}
+		if errors.Is(err, errMismatch) {
+			os.Exit(exitMismatch)
+		}
+		os.Exit(exitAborted)
 	}
 }
 
-func run(ctx context.Context) error {
-	var m Main
-	{
-		flag.StringVar(&m.FilePath, "f", "testdata/ports.json", "Path to JSON file")
+// Exit codes, telling a clean import apart from an import that has completed
+// with rejected records, and from an import that has been aborted, along with
+// storage failing verification. Exit code 2 is used by package flag for invalid
+// arguments.
+const (
+	exitAborted  = 1
+	exitRejected = 3
+	exitMismatch = 4
+)
+
+// Commands supported, named by the first command-line argument. Imports are run
+// when no command is named.
+const (
+	cmdImport = "import"
+	cmdDiff   = "diff"
+	cmdVerify = "verify"
+	cmdRescan = "rescan"
+)
+
+func run(ctx context.Context, args []string) error {
+	cmd := cmdImport
+	if len(args) > 0 && (args[0] == cmdImport || args[0] == cmdDiff || args[0] == cmdVerify || args[0] == cmdRescan) {
+		cmd, args = args[0], args[1:]
 	}
-	flag.Parse()
 
-	m.Logger = log.New(os.Stdout, "main ", log.LstdFlags)
+	conf := ParseFlags(cmd, args)
+
+	// Keep standard output for the summary alone, if it is to be parsed.
+	logOut := io.Writer(os.Stdout)
+	if conf.SummaryJSON {
+		logOut = os.Stderr
+	}
+	m := Main{
+		Conf:   conf,
+		Logger: log.New(logOut, "main ", log.LstdFlags),
+		Stdout: os.Stdout,
+	}
 
-	if err := m.Run(ctx); err != nil {
+	runFn := m.Run
+	switch cmd {
+	case cmdDiff:
+		runFn = m.Diff
+	case cmdVerify:
+		runFn = m.Verify
+	case cmdRescan:
+		runFn = m.Rescan
+	}
+	if err := runFn(ctx); err != nil {
 		return err
 	}
 
@@ -43,14 +96,24 @@ func run(ctx context.Context) error {
 
 // Main represents the program, our command-line file loader.
 type Main struct {
-	FilePath string
-	Logger   *log.Logger
+	Conf   Config
+	Logger *log.Logger
+	Stdout io.Writer // Destination for reports.
+
+	// Store is used in place of the storage system selected by Main.Conf.Store,
+	// if set, e.g. by tests inspecting the ports stored.
+	Store ports.InsertFinder
 }
 
-// Run executes Main. It will attempt to open the file defined by Main.FilePath
-// for reading, decode its contents into ports.Port structs using input
-// streaming, printing ports information to os.Stdout in the process. The file
-// should contain ports information in JSON format.
+// Run executes Main. It will attempt to open the files defined by
+// Main.Conf.FilePaths for reading, decompressing them on the fly if they are
+// compressed with gzip or zstd, decode its contents into ports.Port structs
+// using input streaming, and record each port in the storage system selected by
+// Main.Conf.Store through ports.Service. The file should contain ports
+// information in JSON format.
+//
+// Files are imported one after the other, in the order provided, as if they
+// were one long input. Standard input is read in place of the path "-".
 //
 // The format of the file should be one big JSON object, containing port
 // information described by port identifiers as object fields. Example:
@@ -78,55 +141,622 @@ type Main struct {
 // The JSON decoder used expects the file to be in this exact format, and should
 // fail in any other case. Failures are returned as meaningful errors.
 //
-// The file is closed before the function is returned.
-func (m Main) Run(ctx context.Context) error {
-	f, err := os.Open(m.FilePath)
+// Alternatively, the file may contain newline-delimited JSON, with one port per
+// line, described by a JSON object with its identifier in an "id" field. The
+// format is set by Main.Conf.Format, or detected automatically, see decode.
+//
+// Records that cannot be stored, e.g. because the service considers them
+// invalid, are counted and skipped, and written to Main.Conf.RejectsPath if
+// set. The import is aborted if the number or the rate of rejected records
+// exceeds Main.Conf.MaxErrors or Main.Conf.MaxErrorRate, or if a batch of
+// records cannot be stored at all. If the import completes with rejected
+// records, the error returned wraps errRejected.
+//
+// Records are stored concurrently by Main.Conf.Workers workers, in batches of up
+// to Main.Conf.BatchSize records, see pipeline.
+// When the same port appears more than once in the input, the last occurrence is
+// the one that ends up in storage, so later files take precedence over earlier
+// ones. When more than one file is imported, the summary logged in the end
+// names the file supplying the version stored of each port changed by more than
+// one file, see sources. When the context is cancelled, writes already in
+// progress are allowed to complete before the function returns.
+//
+// If Main.Conf.Target is set, ports are stored through the ports HTTP API
+// served there instead, see openTarget. Ports rejected by the API count as
+// rejected records, while requests failing otherwise are retried, and abort the
+// import if they keep failing.
+//
+// Records are validated against the rules loaded by loadRules, see
+// ports.RuleSet. Records breaking rules of ports.SeverityWarning are stored,
+// but flagged: they are logged unless Main.Conf.Quiet is set, and counted in the
+// summary. When storing through Main.Conf.Target, the rules of the HTTP API
+// apply instead.
+//
+// If Main.Conf.DryRun is set, records are validated but not stored, and a data
+// quality report is written to Main.Stdout instead, see report.
+//
+// If Main.Conf.CheckpointPath is set, progress is saved there periodically and
+// when the import is interrupted, and a later run against the same, unmodified
+// files continues from the last record known to be stored. The checkpoint is
+// removed once the import completes.
+//
+// If Main.Conf.Sync is set, the input is treated as the complete dataset: once
+// the import has completed, within its error budget, ports in storage that were
+// not found in the input are retired, or deleted if Main.Conf.PruneHard is set,
+// see prune.
+//
+// If Main.Conf.Stage is set, the input is loaded into staging storage instead,
+// and swapped in place of the ports in storage at once, once the import has
+// completed within its error budget, see commitStage. Staging storage is
+// discarded if the import fails.
+//
+// If Main.Conf.MaxRPS is set, records are submitted for storage at that rate at
+// most, and if Main.Conf.Adaptive is set as well, the rate is lowered while
+// storage is slow or failing, and raised back once it recovers, see throttle.
+//
+// Ports decoded are cleaned up by the transforms named by Main.Conf.Transforms,
+// or by the configuration file at Main.Conf.ConfigPath, before they are stored,
+// see transform.
+//
+// Progress is logged periodically, unless Main.Conf.Quiet is set, and each
+// record stored is logged as well if Main.Conf.Verbose is set, see progress. A
+// summary is logged once the import is over, or written to Main.Stdout as JSON
+// if Main.Conf.SummaryJSON is set, see summary.
+//
+// The files and any storage connections are closed before the function is
+// returned.
+func (m Main) Run(ctx context.Context) (err error) {
+	if m.Conf.Quiet && m.Conf.Verbose {
+		return errors.New("use either -quiet or -verbose")
+	}
+	if m.Conf.DryRun && m.Conf.SummaryJSON {
+		return errors.New("dry runs write a report instead of a summary, use either -dry-run or -summary-json")
+	}
+
+	var (
+		started   = time.Now()
+		changes   = newChangeCounts()
+		rej       *rejects
+		committed bool // Set once staged ports are swapped in.
+	)
+	chain, err := m.loadTransforms()
+	if err != nil {
+		return err
+	}
+	if m.Conf.SummaryJSON {
+		defer func() {
+			sum := newSummary(m.Conf.FilePaths, started, rej, changes, err)
+			if len(chain.names) > 0 {
+				sum.Transforms = chain.changed()
+			}
+			if sumErr := sum.write(m.Stdout); sumErr != nil {
+				m.Logger.Printf("Error writing summary: %v", sumErr)
+			}
+		}()
+	}
+
+	files, closeFiles, err := m.openFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles()
+
+	rules, err := m.loadRules()
+	if err != nil {
+		return err
+	}
+
+	if m.Conf.DryRun {
+		return m.dryRun(ctx, files, chain, rules)
+	}
+
+	// Store ports through a remote ports service, if requested, or through a
+	// local one otherwise.
+	var (
+		store   ports.InsertFinder // Nil when storing through a remote service.
+		staged  ports.Staged
+		storeFn storeFunc
+	)
+	if m.Conf.Target != "" {
+		client, err := m.openTarget()
+		if err != nil {
+			return err
+		}
+		storeFn = client.StorePorts
+	} else {
+		var closeFn func()
+		if store, closeFn, err = m.openStore(ctx, true); err != nil {
+			return err
+		}
+		defer closeFn()
+
+		// Load the input into staging storage, if requested, discarding it
+		// unless it is swapped in once the import is over.
+		if staged, err = m.stageStore(ctx, store); err != nil {
+			return err
+		}
+		target := store
+		if staged != nil {
+			target = staged
+			defer func() {
+				if committed {
+					return
+				}
+				if err := staged.Abort(context.WithoutCancel(ctx)); err != nil {
+					m.Logger.Printf("Error discarding staged ports: %v", err)
+				}
+			}()
+		}
+
+		// Create a new ports service with resolved dependencies.
+		service := &ports.Service{
+			Ports: target,
+			Rules: rules,
+		}
+		storeFn = service.StorePorts
+	}
+
+	// Resume from where a previous, interrupted import has left off, if any.
+	var start checkpoint
+	if m.Conf.CheckpointPath != "" {
+		if start, err = m.resume(files); err != nil {
+			return err
+		}
+	}
+	retirer, err := m.syncStore(store, start)
 	if err != nil {
-		return fmt.Errorf("opening file: %w", err)
+		return err
+	}
+	mark := newWatermark(start)
+	src := newSources(files)
+	seen, stored := newSeenPorts(), newSeenPorts()
+	prog := newProgress(files, start)
+
+	rej, err = openRejects(m.Conf.RejectsPath, start.Records > 0, m.Conf.MaxErrors, float64(m.Conf.MaxErrorRate))
+	if err != nil {
+		return err
 	}
 	defer func() {
-		if err := f.Close(); err != nil {
-			m.Logger.Printf("closing file: %v", err)
+		if err := rej.Close(); err != nil {
+			m.Logger.Printf("closing rejects file: %v", err)
 		}
 	}()
 
-	decoder := json.NewDecoder(f)
+	thr, err := m.limiter()
+	if err != nil {
+		return err
+	}
+	if thr != nil {
+		storeFn = thr.store(storeFn)
+	}
 
-	// Read first, opening token, `[` or `{`
-	if _, err := decoder.Token(); err != nil {
-		return fmt.Errorf("decoding opening token: %w", err)
+	pl := newPipeline(ctx, m.Conf.Workers, m.Conf.BatchSize, storeFn, func(j job, err error) error {
+		if err != nil {
+			if !m.Conf.Quiet {
+				m.Logger.Printf("%d: Rejected port %q: %v", j.Seq, j.Port.ID, err)
+			}
+			if err := rej.add(j, err); err != nil {
+				return err
+			}
+		} else {
+			rej.ok()
+			src.stored(j)
+			changes.add(j.Change)
+			if len(j.Warnings) > 0 {
+				changes.flag()
+				if !m.Conf.Quiet {
+					m.Logger.Printf("%d: Flagged port %q: %v", j.Seq, j.Port.ID, &ports.ValidationError{Fields: j.Warnings})
+				}
+			}
+			if staged != nil {
+				stored.add(j.Port.ID)
+			}
+			if m.Conf.Verbose {
+				m.Logger.Printf("%d: Port %s: %v", j.Seq, j.Change, j.Port)
+			}
+		}
+
+		mark.complete(j)
+		return nil
+	})
+	stopCheckpoints := m.keepCheckpoint(start, mark)
+	stopProgress := m.keepProgress(prog, rej)
+
+	err = m.decodeFiles(ctx, files, start, chain.apply(func(j job) error {
+		prog.read(j)
+		if retirer != nil {
+			seen.add(j.Port.ID)
+		}
+		if thr != nil {
+			if err := thr.wait(ctx, j); err != nil {
+				return err
+			}
+		}
+		return pl.Submit(j)
+	}))
+	if waitErr := pl.Wait(); waitErr != nil {
+		err = waitErr // Storage failures take precedence.
+	}
+	stopProgress()
+	if cpErr := stopCheckpoints(err == nil); cpErr != nil && err == nil {
+		err = cpErr
+	}
+	if err != nil {
+		return err
+	}
+
+	processed, rejected := rej.counts()
+	elapsed := time.Since(started)
+	m.Logger.Printf("Stored %d records (%s), rejected %d, in %s (%.0f records/s)", processed-rejected, changes, rejected, elapsed.Round(time.Millisecond), float64(processed)/elapsed.Seconds())
+	if len(chain.names) > 0 {
+		m.Logger.Printf("Records changed by transforms: %s", chain)
+	}
+	for _, line := range src.summary() {
+		m.Logger.Print(line)
+	}
+
+	err = rej.outcome()
+	if retirer != nil && (err == nil || errors.Is(err, errRejected)) {
+		if pruneErr := m.prune(ctx, retirer, seen); pruneErr != nil {
+			return pruneErr
+		}
 	}
+	if staged != nil && (err == nil || errors.Is(err, errRejected)) {
+		if commitErr := m.commitStage(ctx, store, staged, stored); commitErr != nil {
+			return commitErr
+		}
+		committed = true
+	}
+
+	return err
+}
+
+// decodeFiles decodes the input files provided one after the other, in order,
+// starting from the checkpoint provided, and passes each port decoded to fn,
+// see decode. Records are numbered across files, and each job carries the index
+// of the file holding its record.
+func (m Main) decodeFiles(ctx context.Context, files []inputFile, start checkpoint, fn func(j job) error) error {
+	seq := start.Records
+	for i := start.File; i < len(files); i++ {
+		from := checkpoint{Records: seq}
+		if i == start.File {
+			from = start
+		}
 
-	var i int
-	var p ports.Port
-	for decoder.More() {
-		// Check for context cancellation, abort if context is cancelled.
-		if err := ctx.Err(); err != nil {
+		in, closeInput, err := openInput(files[i].f, from.Offset, files[i].read)
+		if err != nil {
+			return fmt.Errorf("%s: %w", files[i].path, err)
+		}
+
+		err = m.decode(ctx, in, files[i].path, from, func(j job) error {
+			j.File, seq = i, j.Seq
+			return fn(j)
+		})
+		closeInput()
+		if err != nil {
+			if len(files) > 1 {
+				return fmt.Errorf("%s: %w", files[i].path, err)
+			}
 			return err
 		}
+	}
+
+	return nil
+}
+
+// changeCounts counts the records stored by ports.Change, along with the
+// records stored with warnings. It is safe for concurrent use by multiple
+// goroutines.
+type changeCounts struct {
+	mu      sync.Mutex
+	counts  map[ports.Change]int
+	flagged int
+}
+
+// newChangeCounts creates an empty changeCounts.
+func newChangeCounts() *changeCounts {
+	return &changeCounts{counts: make(map[ports.Change]int)}
+}
+
+// add counts a record stored with the change provided.
+func (c *changeCounts) add(change ports.Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[change]++
+}
+
+// flag counts a record stored with warnings.
+func (c *changeCounts) flag() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.flagged++
+}
+
+// flags returns the number of records stored with warnings.
+func (c *changeCounts) flags() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.flagged
+}
+
+// count returns the number of records stored with the change provided.
+func (c *changeCounts) count(change ports.Change) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts[change]
+}
+
+// String returns the number of records created, updated and left unchanged,
+// and the number of records with unknown changes or with warnings, if any.
+func (c *changeCounts) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := fmt.Sprintf("created %d, updated %d, unchanged %d", c.counts[ports.ChangeCreated], c.counts[ports.ChangeUpdated], c.counts[ports.ChangeUnchanged])
+	if n := c.counts[ports.ChangeUnknown]; n > 0 {
+		s += fmt.Sprintf(", unknown %d", n)
+	}
+	if c.flagged > 0 {
+		s += fmt.Sprintf(", flagged %d", c.flagged)
+	}
+
+	return s
+}
+
+// resume prepares the import of the input files according to the checkpoint
+// stored in Main.Conf.CheckpointPath. It returns the checkpoint to resume from,
+// or a checkpoint for the beginning of the input if there is nothing to resume.
+// It returns an error if the stored checkpoint was created for different files,
+// or for files that have been modified since, and if one of the files is
+// standard input, which cannot be resumed.
+func (m Main) resume(files []inputFile) (checkpoint, error) {
+	for _, in := range files {
+		if in.path == stdinPath {
+			return checkpoint{}, errors.New("checkpoints are not supported when reading from standard input")
+		}
+	}
+
+	cp, err := newCheckpoint(files)
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("creating checkpoint: %w", err)
+	}
+
+	saved, ok, err := loadCheckpoint(m.Conf.CheckpointPath)
+	if err != nil || !ok {
+		return cp, err
+	}
+
+	if !saved.sameFiles(cp) || saved.File >= len(files) {
+		return checkpoint{}, fmt.Errorf("checkpoint %s does not match input files %s, remove it to start over", m.Conf.CheckpointPath, strings.Join(m.Conf.FilePaths, " "))
+	}
+
+	m.Logger.Printf("Resuming after record %d, in %s at offset %d", saved.Records, files[saved.File].path, saved.Offset)
+
+	return saved, nil
+}
+
+// keepCheckpoint periodically saves the progress tracked by mark, as a checkpoint
+// based on cp, in Main.Conf.CheckpointPath. It returns a function that stops
+// saving, and should be called once the import is over. The function removes
+// the checkpoint if the import has completed, otherwise it saves the progress
+// made one last time, so that the import can be resumed later. Checkpoints are
+// not kept if Main.Conf.CheckpointPath is empty.
+func (m Main) keepCheckpoint(cp checkpoint, mark *watermark) func(completed bool) error {
+	if m.Conf.CheckpointPath == "" {
+		return func(bool) error { return nil }
+	}
 
-		i++
-		portID, err := decoder.Token() // Decode the unique identifier for the port.
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := mark.checkpoint(cp).save(m.Conf.CheckpointPath); err != nil {
+					m.Logger.Printf("Error saving checkpoint: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func(completed bool) error {
+		close(stop)
+		<-stopped
+
+		if completed {
+			if err := os.Remove(m.Conf.CheckpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("removing checkpoint: %w", err)
+			}
+
+			return nil
+		}
+
+		cp = mark.checkpoint(cp)
+		m.Logger.Printf("Saving checkpoint after record %d, in %s at offset %d", cp.Records, cp.Files[cp.File].Path, cp.Offset)
+		return cp.save(m.Conf.CheckpointPath)
+	}
+}
+
+// Names of the storage systems supported by Config.Store.
+const (
+	storeInmem = "inmem"
+	storeMongo = "mongo"
+)
+
+// openStore establishes a connection to the storage system selected by
+// Main.Conf.Store and returns it, along with a function that should be used to
+// release any resources held once the storage is no longer needed. Indexes are
+// created if the storage is opened for writing. Main.Store is returned instead,
+// if set.
+func (m Main) openStore(ctx context.Context, write bool) (ports.InsertFinder, func(), error) {
+	if m.Store != nil {
+		return m.Store, func() {}, nil
+	}
+
+	switch m.Conf.Store {
+	case storeInmem:
+		return inmem.Open(), func() {}, nil
+	case storeMongo:
+		mongoDB, err := mongo.Open(m.Conf.MongoDBURI)
 		if err != nil {
-			return fmt.Errorf("decoding port ID: %w", err)
+			return nil, nil, fmt.Errorf("creating MongoDB client: %w", err)
+		}
+
+		closeFn := func() {
+			if err := mongoDB.Close(); err != nil {
+				m.Logger.Printf("Error closing MongoDB client: %v", err)
+			}
 		}
 
-		// Decode the rest of the information.
-		if err := decoder.Decode(&p); err != nil {
-			return fmt.Errorf("decoding port: %v", err)
+		if err := mongoDB.Ping(ctx); err != nil {
+			closeFn()
+			return nil, nil, fmt.Errorf("pinging MongoDB: %w", err)
 		}
 
-		// Assign the port identifier to the ports.Port.
-		p.ID = fmt.Sprintf("%s", portID)
+		if !write {
+			return mongoDB, closeFn, nil
+		}
+
+		if _, err := mongoDB.CreateIndexes(ctx); err != nil {
+			closeFn()
+			return nil, nil, fmt.Errorf("creating MongoDB indexes: %w", err)
+		}
+
+		return mongoDB, closeFn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported store %q, use %q or %q", m.Conf.Store, storeInmem, storeMongo)
+	}
+}
+
+// Config is the application configuration.
+type Config struct {
+	FilePaths  []string // Paths to the files to import, in order, - for standard input.
+	Store      string   // The storage system to import into, inmem or mongo.
+	MongoDBURI string   // The MongoDB connection URI, used when Store is mongo.
+	Target     string   // Base URL of the ports HTTP API to store through, instead of Store.
+	Retries    int      // Maximum number of retries for failing requests to Target.
+	Workers    int      // The number of concurrent storage writers.
+	BatchSize  int      // The maximum number of records per storage write.
+	Format     string   // The format of the input file, auto, json, ndjson or unlocode.
+	Strict     bool     // Reject records with unknown fields or values of the wrong type.
+	Transforms []string // Transforms applied to the ports decoded, in order, see transform.
+	RulesPath  string   // Path to the validation rules file, see ports.ReadRuleSet.
+	ConfigPath string   // Path to the configuration file, see fileConfig.
+
+	RejectsPath  string   // Path to the rejects file, rejects are not written when empty.
+	MaxErrors    int      // Maximum number of rejected records, no limit if negative.
+	MaxErrorRate rateFlag // Maximum rate of rejected records, no limit if zero.
+
+	DryRun       bool   // Validate records and report on data quality, without storing.
+	ReportFormat string // The format of the dry run, diff, verify or rescan report, text or json.
+
+	CheckpointPath string // Path to the checkpoint file, no checkpoints when empty.
+
+	Sync      bool     // Treat the input as the complete dataset, pruning ports missing from it.
+	PruneHard bool     // Delete ports missing from the input, instead of retiring them.
+	MaxPrune  rateFlag // Maximum rate of ports pruned or dropped by staging, out of the ports in storage.
+
+	Stage bool // Load the input into staging storage, and swap it in once complete.
+
+	MaxRPS     float64       // Maximum records written per second, no limit if zero.
+	Adaptive   bool          // Lower the write rate while storage is slow or failing.
+	MaxLatency time.Duration // Batch write latency considered slow in adaptive mode.
+
+	Quiet       bool // Log neither progress nor rejected records.
+	Verbose     bool // Log every record stored, along with progress.
+	SummaryJSON bool // Write the summary to standard output as JSON, logging to standard error.
+}
+
+// ParseFlags parses the command-line arguments of the command provided, and
+// produces application configuration in the form of Config. Only the flags
+// relevant to the command are accepted. Arguments other than flags are taken
+// as paths to files to import, as if each was given with -f.
+//
+// It exists as a separate function so that it can be skipped in end-to-end
+// tests. Tests can provide their own Config.
+func ParseFlags(cmd string, args []string) Config {
+	fs := flag.NewFlagSet("portload "+cmd, flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: portload [%s|%s|%s|%s] [flags]\n\nFlags of %s:\n", cmdImport, cmdDiff, cmdVerify, cmdRescan, fs.Name())
+		fs.PrintDefaults()
+	}
 
-		// Log and proceed.
-		m.Logger.Printf("%d: Port: %v", i, p)
+	var conf Config
+	{
+		fs.Var((*pathsFlag)(&conf.FilePaths), "f", "Path to file to import, - for standard input, repeat for more files (default testdata/ports.json)")
+		fs.StringVar(&conf.Store, "store", getEnvString("PORTS_STORE", storeInmem), "Storage system, inmem or mongo")
+		fs.StringVar(&conf.MongoDBURI, "mongodb-conn-uri", getEnvString("PORTS_MONGODB_CONN_URI", "mongodb://localhost:27017/ports"), "MongoDB connection URI")
+		fs.StringVar(&conf.Target, "target", getEnvString("PORTS_TARGET", ""), "Base URL of the ports HTTP API to load through, instead of storage, e.g. http://localhost:8080")
+		fs.IntVar(&conf.Retries, "retries", 5, "Maximum number of retries for failing HTTP API requests, with -target")
+		fs.IntVar(&conf.Workers, "workers", runtime.GOMAXPROCS(0), "Number of concurrent storage writers")
+		fs.IntVar(&conf.BatchSize, "batch-size", 100, "Maximum number of records per storage write")
+		fs.StringVar(&conf.Format, "format", formatAuto, "Input file format, auto, json, ndjson or unlocode")
+		fs.BoolVar(&conf.Strict, "strict", false, "Reject records with unknown fields or values of the wrong type")
+		fs.Var((*namesFlag)(&conf.Transforms), "transforms", "Comma-separated transforms applied to the ports decoded, in order, e.g. trim,nfc, or all")
+		fs.StringVar(&conf.RulesPath, "rules", getEnvString("PORTS_RULES", ""), "Path to JSON validation rules file, replacing the rules of the configuration file")
+		fs.StringVar(&conf.ConfigPath, "config", "", "Path to JSON configuration file")
+	}
+	switch cmd {
+	case cmdDiff:
+		fs.StringVar(&conf.ReportFormat, "report", reportText, "Diff report format, text or json")
+	case cmdVerify:
+		fs.StringVar(&conf.ReportFormat, "report", reportText, "Verification report format, text or json")
+	case cmdRescan:
+		fs.StringVar(&conf.ReportFormat, "report", reportText, "Rescan report format, text or json")
+	default:
+		fs.StringVar(&conf.RejectsPath, "rejects", "", "Path to file for rejected records, as newline-delimited JSON")
+		fs.IntVar(&conf.MaxErrors, "max-errors", -1, "Abort after this many rejected records, no limit if negative")
+		fs.Var(&conf.MaxErrorRate, "max-error-rate", "Abort if the rate of rejected records exceeds this, e.g. 1%")
+		fs.BoolVar(&conf.DryRun, "dry-run", false, "Validate records and report on data quality, without storing")
+		fs.StringVar(&conf.ReportFormat, "report", reportText, "Dry run report format, text or json")
+		fs.StringVar(&conf.CheckpointPath, "checkpoint", "", "Path to checkpoint file, for resumable imports")
+		fs.BoolVar(&conf.Sync, "sync", false, "Treat the input as the complete dataset, retiring ports missing from it")
+		fs.BoolVar(&conf.PruneHard, "prune-hard", false, "Delete ports missing from the input in sync mode, instead of retiring them")
+		conf.MaxPrune = 0.1
+		fs.Var(&conf.MaxPrune, "max-prune", "Refuse to prune or drop more than this rate of the ports in storage in sync or staged mode")
+		fs.Float64Var(&conf.MaxRPS, "max-rps", 0, "Maximum records written per second, no limit if zero")
+		fs.BoolVar(&conf.Adaptive, "adaptive", false, "Lower the write rate below -max-rps while storage is slow or failing")
+		fs.DurationVar(&conf.MaxLatency, "max-latency", 500*time.Millisecond, "Batch write latency considered slow in adaptive mode")
+		fs.BoolVar(&conf.Quiet, "quiet", false, "Log neither progress nor rejected records")
+		fs.BoolVar(&conf.Verbose, "verbose", false, "Log every record stored, along with progress")
+		fs.BoolVar(&conf.SummaryJSON, "summary-json", false, "Write a JSON summary to standard output once over, logging to standard error")
+		fs.BoolVar(&conf.Stage, "stage", false, "Load the input into staging storage, and swap it in place of the ports in storage once complete")
+	}
+	// Arguments left after the flags are more files to import, e.g. the paths
+	// the shell expands -f delta-*.json to, and may be followed by more flags.
+	for {
+		_ = fs.Parse(args) // Exits on error.
+		if fs.NArg() == 0 {
+			break
+		}
+		conf.FilePaths = append(conf.FilePaths, fs.Arg(0))
+		args = fs.Args()[1:]
 	}
 
-	// Read last, closing token.
-	if _, err := decoder.Token(); err != nil {
-		return fmt.Errorf("decoding closing token: %w", err)
+	if len(conf.FilePaths) == 0 {
+		conf.FilePaths = []string{"testdata/ports.json"}
 	}
 
-	return nil
+	return conf
+}
+
+// getEnvString retrieves the value of the environment variable named by the key.
+// If the variable is present in the environment, its value (which may be empty)
+// is returned, otherwise fallback is returned.
+func getEnvString(key string, fallback string) string {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	return val
 }
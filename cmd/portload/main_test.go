@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestMainRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	if err := os.WriteFile(path, []byte(`{
+	  "AEAJM": {"name": "Ajman", "code": "52000", "coordinates": [55.51, 25.4], "unlocs": ["AEAJM"]},
+	  "AEAUH": {"name": "Abu Dhabi", "code": "52001", "coordinates": [54.37, 24.47], "unlocs": ["AEAUH"]},
+	  "AEDXB": {"code": "52005", "coordinates": [55.27, 25.2], "unlocs": ["AEDXB"]},
+	  "AEFJR": {"name": "Al Fujayrah", "code": "52051", "coordinates": [56.33, 125.12], "unlocs": ["AEFJR"]},
+	  "AEKLF": {"name": "Khor al Fakkan", "code": "52052", "coordinates": [56.35, 25.34], "unlocs": ["AEKLF"]}
+	}`), 0o644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	db := inmem.Open()
+	var stdout bytes.Buffer
+	m := Main{
+		Conf: Config{
+			FilePaths:   []string{path},
+			Store:       storeInmem,
+			Workers:     2,
+			BatchSize:   2,
+			Format:      formatAuto,
+			MaxErrors:   -1,
+			Quiet:       true,
+			SummaryJSON: true,
+		},
+		Logger: log.New(io.Discard, "", 0),
+		Stdout: &stdout,
+		Store:  db,
+	}
+
+	t.Log("Importing 5 records, expecting the one without a name and the one with a latitude out of range rejected")
+	if err := m.Run(context.TODO()); !errors.Is(err, errRejected) {
+		t.Fatalf("Run(): have %v, want %v", err, errRejected)
+	}
+
+	var sum summary
+	if err := json.Unmarshal(stdout.Bytes(), &sum); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	if sum.Records != 5 || sum.Stored != 3 || sum.Created != 3 || sum.Rejected != 2 {
+		t.Errorf("Run(): have %d records, %d stored, %d created, %d rejected, want 5, 3, 3 and 2", sum.Records, sum.Stored, sum.Created, sum.Rejected)
+	}
+
+	if n, err := db.CountPorts(context.TODO()); err != nil || n != 3 {
+		t.Errorf("CountPorts(): have %d, %v, want 3 ports", n, err)
+	}
+	for _, id := range []string{"AEAJM", "AEAUH", "AEKLF"} {
+		if _, err := db.FindPort(context.TODO(), id); err != nil {
+			t.Errorf("FindPort(%q): have %v, want port stored", id, err)
+		}
+	}
+	for _, id := range []string{"AEDXB", "AEFJR"} {
+		if _, err := db.FindPort(context.TODO(), id); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+			t.Errorf("FindPort(%q): have %v, want port not found", id, err)
+		}
+	}
+	if p, err := db.FindPort(context.TODO(), "AEAUH"); err == nil && (p.Name != "Abu Dhabi" || p.Code != "52001") {
+		t.Errorf("FindPort(): have %+v, want port as in the input", p)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	t.Log("Expanding -f delta-*.json, as the shell does, expecting every delta imported")
+	conf := ParseFlags(cmdImport, []string{"-f", "base.json", "-f", "delta-1.json", "delta-2.json", "delta-3.json", "-sync"})
+	if got, want := conf.FilePaths, []string{"base.json", "delta-1.json", "delta-2.json", "delta-3.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFlags(): have files %v, want %v", got, want)
+	}
+	if !conf.Sync {
+		t.Error("ParseFlags(): have -sync unset, want it set after the files")
+	}
+
+	conf = ParseFlags(cmdDiff, nil)
+	if got, want := conf.FilePaths, []string{"testdata/ports.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFlags(): have files %v, want %v", got, want)
+	}
+}
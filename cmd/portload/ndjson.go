@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/christgf/ports"
+)
+
+// Limits for newline-delimited JSON input.
+const (
+	maxLineSize   = 1 << 20 // Lines longer than this are rejected.
+	lineChunkSize = 64      // Number of lines decoded together by a worker.
+)
+
+// errLongLine is the error of lines longer than maxLineSize.
+var errLongLine = fmt.Errorf("longer than %d bytes", maxLineSize)
+
+// ndjsonPort is the representation of a port record in newline-delimited JSON
+// input, an inputPort along with its identifier.
+type ndjsonPort struct {
+	ID string `json:"id"`
+	inputPort
+}
+
+// line is a single line of newline-delimited JSON input.
+type line struct {
+	seq    int   // Position of the record in the input.
+	num    int   // Line number, starting from 1.
+	offset int64 // Input offset of the line.
+	end    int64 // Input offset right after the line, including the newline.
+	raw    []byte
+	long   bool // Set if the line exceeds maxLineSize, raw is then empty.
+}
+
+// lineChunk is a group of consecutive lines, decoded together.
+type lineChunk struct {
+	lines []line
+	out   chan []job // Receives the decoded lines, buffered.
+}
+
+// lineReader reads records from newline-delimited JSON input, one port per
+// line. Blank lines are skipped.
+//
+// Lines are independent of each other, so they are read ahead of time in
+// chunks and decoded concurrently, by a fixed number of workers. Chunks are
+// handed out in input order, and the number of chunks in flight is bounded, so
+// that memory use stays bounded too.
+type lineReader struct {
+	order   chan chan []job // Decoded chunks, in input order.
+	pending []job           // Records of the current chunk not read yet.
+	err     error           // Read error, set before order is closed.
+}
+
+// newLineReader creates a lineReader for r, and starts reading ahead and
+// decoding until the context is cancelled. When resuming from a checkpoint, r
+// should be positioned right after a line.
+func newLineReader(ctx context.Context, r *bufio.Reader, start checkpoint, strict bool, workers int, logger *log.Logger) *lineReader {
+	workers = max(workers, 1)
+
+	lr := &lineReader{order: make(chan chan []job, workers)}
+	work := make(chan lineChunk, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for chunk := range work {
+				jobs := make([]job, len(chunk.lines))
+				for i, l := range chunk.lines {
+					jobs[i] = decodeLine(l, strict, logger)
+				}
+				chunk.out <- jobs
+			}
+		}()
+	}
+
+	go lr.readAhead(ctx, r, start, work)
+
+	return lr
+}
+
+// readAhead reads lines from r in chunks, hands them over to the workers, and
+// queues them for Read in input order.
+func (lr *lineReader) readAhead(ctx context.Context, r *bufio.Reader, start checkpoint, work chan<- lineChunk) {
+	defer close(work)
+	defer close(lr.order)
+
+	seq, num, offset := start.Records, start.Line, start.Offset
+	for eof := false; !eof; {
+		chunk := lineChunk{out: make(chan []job, 1)}
+		for !eof && len(chunk.lines) < lineChunkSize {
+			raw, n, long, err := readLine(r)
+			if errors.Is(err, io.EOF) {
+				eof = true
+			} else if err != nil {
+				lr.err = fmt.Errorf("reading line %d: %w", num+1, err)
+				return
+			}
+			if n == 0 {
+				break
+			}
+
+			num++
+			if long || len(bytes.TrimSpace(raw)) > 0 {
+				seq++
+				chunk.lines = append(chunk.lines, line{seq: seq, num: num, offset: offset, end: offset + n, raw: raw, long: long})
+			}
+			offset += n
+		}
+		if len(chunk.lines) == 0 {
+			continue
+		}
+
+		// Workers always complete their chunk, so Read never waits for a chunk
+		// that is not being decoded.
+		select {
+		case work <- chunk:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case lr.order <- chunk.out:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Read implements recordReader. Lines that cannot be decoded into a port are
+// returned with job.Err set, along with lines that are valid JSON but do not
+// match ndjsonPort in strict mode. Otherwise the offending values are left out
+// of the port and logged.
+func (lr *lineReader) Read() (job, error) {
+	for len(lr.pending) == 0 {
+		out, ok := <-lr.order
+		if !ok {
+			if lr.err != nil {
+				return job{}, lr.err
+			}
+
+			return job{}, io.EOF
+		}
+
+		lr.pending = <-out
+	}
+
+	j := lr.pending[0]
+	lr.pending = lr.pending[1:]
+	return j, nil
+}
+
+// decodeLine decodes a single line into a port.
+func decodeLine(l line, strict bool, logger *log.Logger) job {
+	j := job{Seq: l.seq, Line: l.num, Offset: l.offset, End: l.end, Raw: l.raw}
+	if l.long {
+		err := fmt.Errorf("line %d: %w", l.num, errLongLine)
+		j.Err = &ports.Error{Code: ports.ErrCodeInvalid, Msg: err.Error(), Cause: err}
+		return j
+	}
+
+	var rec ndjsonPort
+	err := unmarshalRecord(l.raw, &rec, strict)
+	j.Port = rec.port(rec.ID)
+	if err == nil {
+		return j
+	}
+
+	if rec.ID != "" {
+		err = fmt.Errorf("port %q: %w", rec.ID, err)
+	}
+	err = fmt.Errorf("line %d: %w", l.num, err)
+	var typeErr *json.UnmarshalTypeError
+	if !strict && errors.As(err, &typeErr) {
+		logger.Printf("%d: Ignoring unexpected values, %v", l.seq, err)
+		return j
+	}
+
+	j.Err = &ports.Error{Code: ports.ErrCodeInvalid, Msg: err.Error(), Cause: err}
+	return j
+}
+
+// readLine reads a single line from r, without the trailing newline. It returns
+// the number of bytes consumed, including the newline. Lines longer than
+// maxLineSize are consumed, but not returned.
+func readLine(r *bufio.Reader) (raw []byte, n int64, long bool, err error) {
+	for {
+		frag, err := r.ReadSlice('\n')
+		n += int64(len(frag))
+		if !long {
+			if len(raw)+len(frag) > maxLineSize+2 { // Allow for "\r\n".
+				raw, long = nil, true
+			} else {
+				raw = append(raw, frag...)
+			}
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		return bytes.TrimRight(raw, "\r\n"), n, long, err
+	}
+}
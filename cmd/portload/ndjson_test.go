@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestLineReader(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 1000; i++ {
+		_, _ = fmt.Fprintf(&b, `{"id": "ID%04d", "name": "Port %d", "code": "%d"}`+"\n", i, i, i)
+		if i == 500 {
+			b.WriteString("\n")
+			b.WriteString(`{"id": "BROKEN", "name": ` + "\n")
+			b.WriteString(`{"id": "LONG", "name": "` + strings.Repeat("x", maxLineSize) + `"}` + "\n")
+		}
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	lr := newLineReader(context.Background(), bufio.NewReader(strings.NewReader(b.String())), checkpoint{}, false, 8, logger)
+
+	var seq int
+	for {
+		j, err := lr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("Read(): %v", err)
+		}
+
+		seq++
+		if got, want := j.Seq, seq; got != want {
+			t.Fatalf("Read(): have record %d, want %d", got, want)
+		}
+
+		switch seq {
+		case 501, 502:
+			if !errors.Is(j.Err, &ports.Error{Code: ports.ErrCodeInvalid}) {
+				t.Errorf("Read(): have error %v for line %d, want invalid error", j.Err, j.Line)
+			}
+		default:
+			n := seq
+			if seq > 502 {
+				n = seq - 2
+			}
+			if got, want := j.Port.ID, fmt.Sprintf("ID%04d", n); got != want || j.Err != nil {
+				t.Errorf("Read(): have port %q, error %v, want port %q", got, j.Err, want)
+			}
+		}
+	}
+
+	if got, want := seq, 1002; got != want {
+		t.Errorf("Read(): have %d records, want %d", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path  string
+		input string
+		want  string
+	}{
+		{path: "ports.json", input: `{"id": "AEAJM"}`, want: formatJSON},
+		{path: "ports.ndjson.gz", input: "{\n", want: formatNDJSON},
+		{path: "ports.jsonl", input: "", want: formatNDJSON},
+		{path: "UNLOCODE CodeListPart1.CSV", input: "", want: formatUNLOCODE},
+		{path: "-", input: "{\n  \"AEAJM\": {\n", want: formatJSON},
+		{path: "-", input: `{"AEAJM": {"name": "Ajman"}}`, want: formatJSON},
+		{path: "-", input: `{"id": "AEAJM", "name": "Ajman"}` + "\n", want: formatNDJSON},
+	}
+
+	for _, tt := range tests {
+		if got := detectFormat(tt.path, bufio.NewReader(strings.NewReader(tt.input))); got != tt.want {
+			t.Errorf("detectFormat(%q, %q): have %q, want %q", tt.path, tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/christgf/ports"
+	"golang.org/x/sync/errgroup"
+)
+
+// job is a single port record travelling through the pipeline.
+type job struct {
+	Seq      int             // Position of the record in the input, starting from 1.
+	File     int             // Index of the input file holding the record.
+	Line     int             // Line number of the record, for line-based formats.
+	Offset   int64           // Input offset of the record.
+	End      int64           // Input offset right after the record.
+	Raw      json.RawMessage // The record as found in the input.
+	Port     ports.Port
+	Err      error               // Set if the record was rejected before storage.
+	Change   ports.Change        // The effect of storing the record, once stored.
+	Warnings []*ports.FieldError // Problems found with rules of ports.SeverityWarning, once stored.
+}
+
+// label identifies the record of the job in messages and reports, by its port
+// ID, or by its position in the input if the port ID is not known.
+func (j job) label() string {
+	switch {
+	case j.Port.ID != "":
+		return j.Port.ID
+	case j.Line > 0:
+		return fmt.Sprintf("line %d", j.Line)
+	default:
+		return fmt.Sprintf("offset %d", j.Offset)
+	}
+}
+
+// storeFunc records a batch of ports, usually ports.Service.StorePorts.
+type storeFunc func(ctx context.Context, ps []ports.Port) ([]ports.Result, error)
+
+// doneFunc is called by pipeline workers once a job has been stored, with the
+// error reported for that particular job by storeFunc, if any, and job.Change
+// and job.Warnings set as reported by storeFunc. Returning a
+// non-nil error aborts the pipeline. It may be called from multiple goroutines
+// concurrently.
+type doneFunc func(j job, err error) error
+
+// pipeline stores port records concurrently, using a fixed number of workers.
+//
+// Each worker owns a bounded queue, and records are routed to workers by
+// hashing the port ID. All versions of the same port are therefore written by
+// the same worker, in the order they were submitted, so the latest version in
+// the input always wins. Submit blocks while the destination queue is full,
+// applying backpressure to the caller and keeping memory use bounded.
+//
+// Workers store whatever is waiting in their queue as a single batch, up to
+// the batch size, without waiting for more records to arrive.
+type pipeline struct {
+	ctx    context.Context
+	group  *errgroup.Group
+	queues []chan job
+	store  storeFunc
+	done   doneFunc
+}
+
+// newPipeline creates a pipeline with the number of workers and batch size
+// provided, and starts the workers. Each worker queues up to batchSize records.
+// The pipeline is aborted when the context is cancelled, when storeFunc fails
+// for a batch as a whole, or when done returns an error. Writes already in
+// flight at that point are allowed to complete, and records still queued are
+// discarded.
+func newPipeline(ctx context.Context, workers, batchSize int, store storeFunc, done doneFunc) *pipeline {
+	workers, batchSize = max(workers, 1), max(batchSize, 1)
+
+	g, ctx := errgroup.WithContext(ctx)
+	pl := &pipeline{
+		ctx:    ctx,
+		group:  g,
+		queues: make([]chan job, workers),
+		store:  store,
+		done:   done,
+	}
+	for i := range pl.queues {
+		queue := make(chan job, batchSize)
+		pl.queues[i] = queue
+		g.Go(func() error {
+			batch := make([]job, 0, batchSize)
+			for j := range queue {
+				batch = gather(queue, append(batch[:0], j))
+				if ctx.Err() != nil {
+					continue // Aborted, drain the queue without writing.
+				}
+
+				if err := pl.flush(ctx, batch); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return pl
+}
+
+// gather appends jobs already waiting in the queue to batch, until the queue is
+// empty or the batch is full.
+func gather(queue <-chan job, batch []job) []job {
+	for len(batch) < cap(batch) {
+		select {
+		case j, ok := <-queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, j)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// flush stores a batch of jobs and reports the outcome of each one of them.
+// Jobs rejected before storage are reported with their own error, in order
+// with the rest.
+func (pl *pipeline) flush(ctx context.Context, batch []job) error {
+	ps := make([]ports.Port, 0, len(batch))
+	for _, j := range batch {
+		if j.Err == nil {
+			ps = append(ps, j.Port)
+		}
+	}
+
+	var results []ports.Result
+	if len(ps) > 0 {
+		// In-flight writes are not interrupted by cancellation.
+		var err error
+		if results, err = pl.store(context.WithoutCancel(ctx), ps); err != nil {
+			return fmt.Errorf("storing batch: %w", err)
+		}
+	}
+
+	var i int // Position of the next stored job in results.
+	for _, j := range batch {
+		jobErr := j.Err
+		if jobErr == nil {
+			jobErr, j.Change, j.Warnings = results[i].Err, results[i].Change, results[i].Warnings
+			i++
+		}
+
+		if err := pl.done(j, jobErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Submit hands a job over to the worker responsible for its port ID. It blocks
+// until the worker has room for it, and returns an error if the pipeline has
+// been aborted in the meantime.
+func (pl *pipeline) Submit(j job) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(j.Port.ID))
+	queue := pl.queues[h.Sum32()%uint32(len(pl.queues))]
+
+	select {
+	case queue <- j:
+		return nil
+	case <-pl.ctx.Done():
+		return context.Cause(pl.ctx)
+	}
+}
+
+// Wait signals the workers that no more jobs will be submitted, and waits for
+// them to finish. It returns the first error returned by doneFunc, if any. It
+// must be called exactly once, after the last call to Submit.
+func (pl *pipeline) Wait() error {
+	for _, queue := range pl.queues {
+		close(queue)
+	}
+
+	return pl.group.Wait()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christgf/ports"
+)
+
+func TestPipelineLatestVersionWins(t *testing.T) {
+	var mu sync.Mutex
+	stored := make(map[string]float64)
+
+	store := func(_ context.Context, ps []ports.Port) ([]ports.Result, error) {
+		time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range ps {
+			stored[p.ID] = p.Coords[0] // Coords hold the version.
+		}
+		return make([]ports.Result, len(ps)), nil
+	}
+
+	pl := newPipeline(context.Background(), 8, 16, store, func(job, error) error { return nil })
+
+	const ids, versions = 50, 20
+	for v := 1; v <= versions; v++ {
+		for i := 0; i < ids; i++ {
+			p := ports.Port{ID: fmt.Sprintf("ID%02d", i), Coords: []float64{float64(v)}}
+			if err := pl.Submit(job{Seq: v*ids + i, Port: p}); err != nil {
+				t.Fatalf("Submit(): %v", err)
+			}
+		}
+	}
+
+	if err := pl.Wait(); err != nil {
+		t.Fatalf("Wait(): %v", err)
+	}
+
+	for id, v := range stored {
+		if v != versions {
+			t.Errorf("port %q: have version %v stored, want %v", id, v, versions)
+		}
+	}
+}
+
+func TestPipelineAbort(t *testing.T) {
+	wantErr := errors.New("something went wrong")
+
+	pl := newPipeline(context.Background(), 2, 16, func(_ context.Context, ps []ports.Port) ([]ports.Result, error) {
+		return make([]ports.Result, len(ps)), nil
+	}, func(job, error) error {
+		return wantErr
+	})
+
+	// Keep submitting until the pipeline notices the failure and rejects jobs.
+	var err error
+	for i := 1; err == nil && i < 10000; i++ {
+		err = pl.Submit(job{Seq: i, Port: ports.Port{ID: fmt.Sprint(i)}})
+	}
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Submit(): have %v, want %v", err, wantErr)
+	}
+
+	if err := pl.Wait(); !errors.Is(err, wantErr) {
+		t.Errorf("Wait(): have %v, want %v", err, wantErr)
+	}
+}
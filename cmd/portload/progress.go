@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christgf/ports"
+)
+
+// progressInterval is how often an import in progress logs its progress.
+const progressInterval = 10 * time.Second
+
+// progress keeps track of how far an import has read its input files. It is
+// safe for concurrent use by multiple goroutines.
+//
+// Bytes read are counted as the input files are read for decoding, see
+// openInput, so they refer to the files as stored, compressed or not. Their
+// total is not known for input other than regular files, such as a pipe on
+// standard input.
+type progress struct {
+	files []inputFile
+	sizes []int64 // File sizes, -1 if not known.
+
+	mu        sync.Mutex
+	records   int       // Records read so far.
+	file      int       // Index of the file being read.
+	lastTime  time.Time // When the previous progress line was produced.
+	lastRecs  int       // Records read as of the previous progress line.
+	lastBytes int64     // Bytes read as of the previous progress line, -1 if not known.
+}
+
+// newProgress creates a progress for the files provided, starting from the
+// checkpoint provided.
+func newProgress(files []inputFile, start checkpoint) *progress {
+	p := &progress{
+		files:    files,
+		sizes:    make([]int64, len(files)),
+		records:  start.Records,
+		file:     start.File,
+		lastTime: time.Now(),
+		lastRecs: start.Records,
+	}
+	for i, in := range files {
+		p.sizes[i] = -1
+		if fi, err := in.f.Stat(); err == nil && fi.Mode().IsRegular() {
+			p.sizes[i] = fi.Size()
+		}
+	}
+
+	// Bytes skipped when resuming, which is exact for uncompressed files.
+	p.lastBytes = start.Offset
+	for _, size := range p.sizes[:start.File] {
+		if size < 0 {
+			p.lastBytes = -1
+			break
+		}
+		p.lastBytes += size
+	}
+
+	return p
+}
+
+// read records that job j has been read from the input.
+func (p *progress) read(j job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.records, p.file = j.Seq, j.File
+}
+
+// bytes returns the number of bytes read from the input files so far, and
+// their total size. Either is -1 if not known.
+func (p *progress) bytes() (read, total int64) {
+	for _, size := range p.sizes {
+		if size < 0 {
+			total = -1
+			break
+		}
+		total += size
+	}
+
+	for i := 0; i < p.file; i++ {
+		if p.sizes[i] < 0 {
+			return -1, total
+		}
+		read += p.sizes[i]
+	}
+	if p.files[p.file].read == nil {
+		return -1, total
+	}
+
+	return read + p.files[p.file].read.Load(), total
+}
+
+// line describes the progress made, including the number of records processed
+// and rejected, as counted by rejects. Rates and the estimated time remaining
+// are measured since the previous line.
+func (p *progress) line(now time.Time, processed, rejected int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := now.Sub(p.lastTime).Seconds()
+	read, total := p.bytes()
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "Progress: read %d records", p.records)
+	if elapsed > 0 {
+		_, _ = fmt.Fprintf(&b, " (%.0f/s)", float64(p.records-p.lastRecs)/elapsed)
+	}
+	_, _ = fmt.Fprintf(&b, ", stored %d, rejected %d", processed-rejected, rejected)
+
+	switch {
+	case read >= 0 && total > 0:
+		_, _ = fmt.Fprintf(&b, ", %s of %s (%.0f%%)", formatBytes(read), formatBytes(total), 100*float64(read)/float64(total))
+		if p.lastBytes >= 0 && read > p.lastBytes && elapsed > 0 {
+			rate := float64(read-p.lastBytes) / elapsed
+			eta := time.Duration(float64(total-read) / rate * float64(time.Second))
+			_, _ = fmt.Fprintf(&b, ", ETA %s", eta.Round(time.Second))
+		}
+	case read >= 0:
+		_, _ = fmt.Fprintf(&b, ", %s read", formatBytes(read))
+	}
+
+	p.lastTime, p.lastRecs, p.lastBytes = now, p.records, read
+
+	return b.String()
+}
+
+// formatBytes formats a number of bytes in binary units, e.g. 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// keepProgress periodically logs the progress tracked by p, along with the
+// records processed and rejected as counted by rej. It returns a function that
+// stops logging, and should be called once the import is over. Progress is not
+// logged if Main.Conf.Quiet is set.
+func (m Main) keepProgress(p *progress, rej *rejects) func() {
+	if m.Conf.Quiet {
+		return func() {}
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				processed, rejected := rej.counts()
+				m.Logger.Print(p.line(now, processed, rejected))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// Outcomes of an import, as reported by summary.
+const (
+	outcomeCompleted = "completed"
+	outcomeRejected  = "rejected"
+	outcomeAborted   = "aborted"
+)
+
+// summary describes an import once it is over, see Config.SummaryJSON.
+type summary struct {
+	Outcome          string         `json:"outcome"` // Either completed, rejected or aborted.
+	Error            string         `json:"error,omitempty"`
+	Files            []string       `json:"files"`
+	Records          int            `json:"records"` // Records processed, stored or rejected.
+	Stored           int            `json:"stored"`
+	Created          int            `json:"created"`
+	Updated          int            `json:"updated"`
+	Unchanged        int            `json:"unchanged"`
+	Unknown          int            `json:"unknown,omitempty"` // Records stored with unknown changes.
+	Flagged          int            `json:"flagged"`           // Records stored with warnings, see ports.SeverityWarning.
+	Rejected         int            `json:"rejected"`
+	Transforms       map[string]int `json:"transforms,omitempty"` // Records changed, by transform.
+	Seconds          float64        `json:"seconds"`
+	RecordsPerSecond float64        `json:"records_per_second"`
+}
+
+// newSummary describes an import that has started at the time provided, has
+// processed records as counted by rej and changes, either of which may be nil
+// if the import has failed before processing any, and has returned err.
+func newSummary(paths []string, started time.Time, rej *rejects, changes *changeCounts, err error) summary {
+	s := summary{Outcome: outcomeCompleted, Files: paths}
+	switch {
+	case errors.Is(err, errRejected):
+		s.Outcome, s.Error = outcomeRejected, err.Error()
+	case err != nil:
+		s.Outcome, s.Error = outcomeAborted, err.Error()
+	}
+
+	if rej != nil {
+		s.Records, s.Rejected = rej.counts()
+		s.Stored = s.Records - s.Rejected
+	}
+	if changes != nil {
+		s.Created = changes.count(ports.ChangeCreated)
+		s.Updated = changes.count(ports.ChangeUpdated)
+		s.Unchanged = changes.count(ports.ChangeUnchanged)
+		s.Unknown = changes.count(ports.ChangeUnknown)
+		s.Flagged = changes.flags()
+	}
+
+	s.Seconds = time.Since(started).Seconds()
+	if s.Seconds > 0 {
+		s.RecordsPerSecond = float64(s.Records) / s.Seconds
+	}
+
+	return s
+}
+
+// write the summary to w, as a single line of JSON.
+func (s summary) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
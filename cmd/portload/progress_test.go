@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/christgf/ports"
+)
+
+func TestProgressLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{' '}, 4096), 0o644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	in := inputFile{path: path, f: f, read: new(atomic.Int64)}
+	p := newProgress([]inputFile{in}, checkpoint{})
+	if _, err := io.CopyN(io.Discard, countingReader{r: f, n: in.read}, 1024); err != nil {
+		t.Fatalf("CopyN(): %v", err)
+	}
+	p.read(job{Seq: 100})
+
+	t.Log("Reading a quarter of the file in 10 seconds, expecting 30 more seconds to go")
+	got := p.line(p.lastTime.Add(10*time.Second), 90, 2)
+	want := "Progress: read 100 records (10/s), stored 88, rejected 2, 1.0 KiB of 4.0 KiB (25%), ETA 30s"
+	if got != want {
+		t.Errorf("line():\nhave: %s\nwant: %s", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		50 << 20:        "50.0 MiB",
+		3 << 30:         "3.0 GiB",
+		5<<40 + 1<<39:   "5.5 TiB",
+		1<<62 + 1<<61:   "6.0 EiB",
+		(1 << 20) - 512: "1023.5 KiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d): have %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	rej, err := openRejects("", false, -1, 0)
+	if err != nil {
+		t.Fatalf("openRejects(): %v", err)
+	}
+	changes := newChangeCounts()
+	for _, change := range []ports.Change{ports.ChangeCreated, ports.ChangeCreated, ports.ChangeUnchanged} {
+		rej.ok()
+		changes.add(change)
+	}
+	if err := rej.add(job{}, fmt.Errorf("invalid")); err != nil {
+		t.Fatalf("add(): %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := newSummary([]string{"ports.json"}, time.Now(), rej, changes, rej.outcome()).write(&buf); err != nil {
+		t.Fatalf("write(): %v", err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("write(): have %q, want a single line", buf.String())
+	}
+
+	var got summary
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	got.Error, got.Seconds, got.RecordsPerSecond = "", 0, 0
+	want := summary{Outcome: outcomeRejected, Files: []string{"ports.json"}, Records: 4, Stored: 3, Created: 2, Unchanged: 1, Rejected: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newSummary():\nhave: %+v\nwant: %+v", got, want)
+	}
+
+	t.Log("Summarising an import failing before processing any records")
+	if got := newSummary(nil, time.Now(), nil, nil, fmt.Errorf("opening file")); got.Outcome != outcomeAborted || got.Records != 0 {
+		t.Errorf("newSummary(): have %+v, want aborted with no records", got)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errRejected is returned by Main.Run when the import has completed, but some
+// of the records could not be stored.
+var errRejected = errors.New("completed with rejected records")
+
+// minErrorRateRecords is the number of records an import must process before
+// its error rate is checked against Config.MaxErrorRate, so that a handful of
+// early rejects does not abort it.
+const minErrorRateRecords = 1000
+
+// reject is an entry of the rejects file, describing a record that could not
+// be stored. Rejects are written as newline-delimited JSON.
+// Records that are not valid JSON are included as text instead.
+type reject struct {
+	ID     string          `json:"id"`
+	Offset int64           `json:"offset"`
+	Line   int             `json:"line,omitempty"`
+	Reason string          `json:"reason"`
+	Record json.RawMessage `json:"record,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+// rejects records the jobs that could not be stored, and keeps track of the
+// error budget of an import. It is safe for concurrent use by multiple
+// goroutines.
+type rejects struct {
+	mu        sync.Mutex
+	f         *os.File // Rejects file, nil if rejects are not written.
+	enc       *json.Encoder
+	processed int
+	rejected  int
+
+	maxErrors    int     // Maximum number of rejects, no limit if negative.
+	maxErrorRate float64 // Maximum ratio of rejects to records, no limit if zero.
+}
+
+// openRejects opens the rejects file at the path provided, if not empty. The
+// file is truncated, unless appending is requested.
+func openRejects(path string, appending bool, maxErrors int, maxErrorRate float64) (*rejects, error) {
+	rej := &rejects{
+		maxErrors:    maxErrors,
+		maxErrorRate: maxErrorRate,
+	}
+	if path == "" {
+		return rej, nil
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening rejects file: %w", err)
+	}
+	rej.f, rej.enc = f, json.NewEncoder(f)
+
+	return rej, nil
+}
+
+// ok counts a job stored successfully.
+func (rej *rejects) ok() {
+	rej.mu.Lock()
+	defer rej.mu.Unlock()
+
+	rej.processed++
+}
+
+// add counts a job that could not be stored and writes it to the rejects file.
+// It returns an error if the error budget has been exceeded, or if the rejects
+// file cannot be written.
+func (rej *rejects) add(j job, reason error) error {
+	rej.mu.Lock()
+	defer rej.mu.Unlock()
+
+	rej.processed++
+	rej.rejected++
+
+	if rej.enc != nil {
+		entry := reject{
+			ID:     j.Port.ID,
+			Offset: j.Offset,
+			Line:   j.Line,
+			Reason: reason.Error(),
+			Record: j.Raw,
+		}
+		if len(j.Raw) > 0 && !json.Valid(j.Raw) {
+			entry.Record, entry.Text = nil, string(j.Raw)
+		}
+
+		if err := rej.enc.Encode(entry); err != nil {
+			return fmt.Errorf("writing rejects file: %w", err)
+		}
+	}
+
+	return rej.check(false)
+}
+
+// check returns an error if the error budget has been exceeded. The error rate
+// is checked after minErrorRateRecords records, or once the import is over.
+func (rej *rejects) check(final bool) error {
+	if rej.maxErrors >= 0 && rej.rejected > rej.maxErrors {
+		return fmt.Errorf("aborted after %d rejected records, more than the maximum of %d", rej.rejected, rej.maxErrors)
+	}
+
+	if rej.maxErrorRate > 0 && (final || rej.processed >= minErrorRateRecords) {
+		if rate := float64(rej.rejected) / float64(rej.processed); rate > rej.maxErrorRate {
+			return fmt.Errorf("aborted after %d rejected records out of %d, more than the maximum rate of %s", rej.rejected, rej.processed, formatRate(rej.maxErrorRate))
+		}
+	}
+
+	return nil
+}
+
+// counts returns the number of records processed, and how many of them have
+// been rejected.
+func (rej *rejects) counts() (processed, rejected int) {
+	rej.mu.Lock()
+	defer rej.mu.Unlock()
+
+	return rej.processed, rej.rejected
+}
+
+// outcome checks the error budget one last time, once the import is over. It
+// returns errRejected if some records have been rejected within the budget.
+func (rej *rejects) outcome() error {
+	rej.mu.Lock()
+	defer rej.mu.Unlock()
+
+	if err := rej.check(true); err != nil {
+		return err
+	}
+	if rej.rejected > 0 {
+		return fmt.Errorf("%w: %d out of %d", errRejected, rej.rejected, rej.processed)
+	}
+
+	return nil
+}
+
+// Close closes the rejects file, if any.
+func (rej *rejects) Close() error {
+	if rej.f == nil {
+		return nil
+	}
+
+	return rej.f.Close()
+}
+
+// rateFlag is a flag.Value for ratios, expressed either as a fraction (0.01) or
+// as a percentage (1%).
+type rateFlag float64
+
+// String implements flag.Value.
+func (r *rateFlag) String() string {
+	return formatRate(float64(*r))
+}
+
+// Set implements flag.Value.
+func (r *rateFlag) Set(s string) error {
+	v, percent := strings.CutSuffix(s, "%")
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return err
+	}
+	if percent {
+		f /= 100
+	}
+	if f < 0 || f > 1 {
+		return fmt.Errorf("rate %s out of range", s)
+	}
+
+	*r = rateFlag(f)
+	return nil
+}
+
+// formatRate formats a ratio as a percentage.
+func formatRate(f float64) string {
+	return strconv.FormatFloat(f*100, 'f', -1, 64) + "%"
+}
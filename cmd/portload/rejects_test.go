@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestRejects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rejects.ndjson")
+
+	rej, err := openRejects(path, false, 1, 0)
+	if err != nil {
+		t.Fatalf("openRejects(): %v", err)
+	}
+	t.Cleanup(func() { _ = rej.Close() })
+
+	j := job{Seq: 1, Offset: 10, Raw: json.RawMessage(`{"name":"Ajman"}`), Port: ports.Port{ID: "AEAJM"}}
+	if err := rej.add(j, ports.ErrInvalidPortCode); err != nil {
+		t.Fatalf("add(): %v", err)
+	}
+	rej.ok()
+
+	if err := rej.outcome(); !errors.Is(err, errRejected) {
+		t.Errorf("outcome(): have %v, want %v", err, errRejected)
+	}
+
+	t.Log("Rejecting a second record, expecting the error budget to be exceeded")
+	if err := rej.add(j, ports.ErrInvalidPortCode); err == nil || errors.Is(err, errRejected) {
+		t.Errorf("add(): have %v, want error budget exceeded", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatalf("Scan(): expected a reject, got nothing")
+	}
+	want := `{"id":"AEAJM","offset":10,"reason":"port code should not be empty","record":{"name":"Ajman"}}`
+	if got := scanner.Text(); got != want {
+		t.Errorf("rejects file: unexpected entry\nhave: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRejectsErrorRate(t *testing.T) {
+	rej, err := openRejects("", false, -1, 0.01)
+	if err != nil {
+		t.Fatalf("openRejects(): %v", err)
+	}
+
+	// A reject among the first few records should not abort the import.
+	if err := rej.add(job{}, ports.ErrInvalidPortCode); err != nil {
+		t.Fatalf("add(): %v", err)
+	}
+	for i := 0; i < 99; i++ {
+		rej.ok()
+	}
+
+	if err := rej.outcome(); !errors.Is(err, errRejected) {
+		t.Errorf("outcome(): have %v, want %v", err, errRejected)
+	}
+
+	t.Logf("Rejecting records past %d records, expecting the error rate to be exceeded", minErrorRateRecords)
+	for i := 0; i < minErrorRateRecords; i++ {
+		rej.ok()
+	}
+
+	err = nil
+	for i := 0; err == nil && i < 100; i++ {
+		err = rej.add(job{}, ports.ErrInvalidPortCode)
+	}
+	if err == nil || errors.Is(err, errRejected) {
+		t.Errorf("add(): have %v, want error rate exceeded", err)
+	}
+}
+
+func TestRateFlag(t *testing.T) {
+	tests := []struct {
+		val  string
+		want float64
+		err  bool
+	}{
+		{val: "1%", want: 0.01},
+		{val: "0.05", want: 0.05},
+		{val: "150%", err: true},
+		{val: "one", err: true},
+	}
+
+	for _, tt := range tests {
+		var r rateFlag
+		err := r.Set(tt.val)
+		if gotErr := err != nil; gotErr != tt.err {
+			t.Errorf("Set(%q): have error %v, want error %t", tt.val, err, tt.err)
+		}
+		if got := float64(r); got != tt.want {
+			t.Errorf("Set(%q): have %v, want %v", tt.val, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/christgf/ports"
+)
+
+// reportSampleSize is the number of offending port IDs kept as a sample for
+// every group of problems in a report.
+const reportSampleSize = 5
+
+// Formats supported by Config.ReportFormat.
+const (
+	reportText = "text"
+	reportJSON = "json"
+)
+
+// report is a data quality report on the records of an input file, or on the
+// records in storage, see Main.Rescan.
+type report struct {
+	Records    int            `json:"records"`
+	Valid      int            `json:"valid"`   // Records that would be stored, flagged or not.
+	Invalid    int            `json:"invalid"` // Records that would be rejected.
+	Flagged    int            `json:"flagged"` // Valid records with warnings.
+	Errors     []*reportGroup `json:"errors"`
+	Warnings   []*reportGroup `json:"warnings"`
+	Duplicates reportGroup    `json:"duplicates"`
+	Transforms map[string]int `json:"transforms,omitempty"` // Records changed, by transform.
+
+	rules  *ports.RuleSet
+	groups map[string]*reportGroup // Errors, by reason.
+	flags  map[string]*reportGroup // Warnings, by reason.
+	seen   map[uint64]struct{}     // Hashes of the port IDs seen so far.
+}
+
+// reportGroup is a group of records sharing the same problem.
+type reportGroup struct {
+	Reason string   `json:"reason"`
+	Count  int      `json:"count"`
+	Sample []string `json:"sample"`
+}
+
+// add records a port ID in the group.
+func (g *reportGroup) add(portID string) {
+	g.Count++
+	if len(g.Sample) < reportSampleSize {
+		g.Sample = append(g.Sample, portID)
+	}
+}
+
+// newReport creates an empty report, checking records against the rules
+// provided.
+func newReport(rules *ports.RuleSet) *report {
+	return &report{
+		Errors:     []*reportGroup{},
+		Warnings:   []*reportGroup{},
+		Duplicates: reportGroup{Reason: "duplicate port ID", Sample: []string{}},
+		rules:      rules,
+		groups:     make(map[string]*reportGroup),
+		flags:      make(map[string]*reportGroup),
+		seen:       make(map[uint64]struct{}),
+	}
+}
+
+// add examines a record and adds it to the report. Records already rejected
+// while decoding are grouped by the kind of problem, see malformedReason.
+// Otherwise the
+// port is checked against the rules of the report, and added to the group of
+// every problem found, either with the errors or with the warnings, so that a
+// record may count in more than one group.
+//
+// Port IDs are tracked as 64-bit hashes to detect duplicates, so that memory use
+// stays modest for files with millions of records.
+func (rep *report) add(j job) {
+	rep.Records++
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(j.Port.ID))
+	if _, ok := rep.seen[h.Sum64()]; ok {
+		rep.Duplicates.add(j.label())
+	}
+	rep.seen[h.Sum64()] = struct{}{}
+
+	var reasons, flags []string
+	if j.Err != nil {
+		reasons = []string{malformedReason(j.Err)}
+	} else {
+		warnings, err := rep.rules.Validate(j.Port)
+		if err != nil {
+			var verr *ports.ValidationError
+			if !errors.As(err, &verr) {
+				verr = &ports.ValidationError{Fields: []*ports.FieldError{{Err: err}}}
+			}
+			reasons = uniqueReasons(verr.Fields)
+		}
+		flags = uniqueReasons(warnings)
+	}
+
+	if len(reasons) > 0 {
+		rep.Invalid++
+		addGroups(rep.groups, &rep.Errors, reasons, j.label())
+		return
+	}
+
+	rep.Valid++
+	if len(flags) > 0 {
+		rep.Flagged++
+		addGroups(rep.flags, &rep.Warnings, flags, j.label())
+	}
+}
+
+// unknownFieldPrefix starts the errors of the JSON decoder for fields unknown to
+// the value decoded, which have no type of their own.
+const unknownFieldPrefix = "json: unknown field "
+
+// malformedReason describes the problem with a record rejected while decoding,
+// telling unknown fields and values of the wrong type apart, by field, from
+// input that is not valid JSON or CSV, and from lines too long to read.
+func malformedReason(err error) string {
+	var (
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+		csvErr    *csv.ParseError
+	)
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("wrong type for field %s: %s, want %s", typeErr.Field, typeErr.Value, typeErr.Type)
+	case strings.Contains(err.Error(), unknownFieldPrefix):
+		msg := err.Error()
+		return "unknown field " + msg[strings.LastIndex(msg, unknownFieldPrefix)+len(unknownFieldPrefix):]
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "malformed JSON"
+	case errors.As(err, &csvErr):
+		return "malformed CSV"
+	case errors.Is(err, errLongLine):
+		return "line too long"
+	default:
+		return "malformed record"
+	}
+}
+
+// uniqueReasons returns the problems described by the errors provided, once
+// each, in order.
+func uniqueReasons(errs []*ports.FieldError) []string {
+	var reasons []string
+	for _, fe := range errs {
+		if !slices.Contains(reasons, fe.Error()) {
+			reasons = append(reasons, fe.Error())
+		}
+	}
+
+	return reasons
+}
+
+// addGroups adds the port ID to the group of each of the reasons provided,
+// creating missing groups in both the index and the list of groups provided.
+func addGroups(index map[string]*reportGroup, list *[]*reportGroup, reasons []string, portID string) {
+	for _, reason := range reasons {
+		g, ok := index[reason]
+		if !ok {
+			g = &reportGroup{Reason: reason}
+			index[reason] = g
+			*list = append(*list, g)
+		}
+		g.add(portID)
+	}
+}
+
+// write the report to w, in the format provided.
+func (rep *report) write(w io.Writer, format string) error {
+	// Most frequent problems first.
+	for _, groups := range [][]*reportGroup{rep.Errors, rep.Warnings} {
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].Count > groups[j].Count
+		})
+	}
+
+	switch format {
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case reportText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Records:\t%d\n", rep.Records)
+		_, _ = fmt.Fprintf(tw, "Valid:\t%d\n", rep.Valid)
+		_, _ = fmt.Fprintf(tw, "Invalid:\t%d\n", rep.Invalid)
+		for _, g := range rep.Errors {
+			_, _ = fmt.Fprintf(tw, "  %s:\t%d\t(e.g. %s)\n", g.Reason, g.Count, strings.Join(g.Sample, ", "))
+		}
+		_, _ = fmt.Fprintf(tw, "Flagged:\t%d\n", rep.Flagged)
+		for _, g := range rep.Warnings {
+			_, _ = fmt.Fprintf(tw, "  %s:\t%d\t(e.g. %s)\n", g.Reason, g.Count, strings.Join(g.Sample, ", "))
+		}
+		_, _ = fmt.Fprintf(tw, "Duplicates:\t%d\n", rep.Duplicates.Count)
+		if rep.Duplicates.Count > 0 {
+			_, _ = fmt.Fprintf(tw, "  %s:\t%d\t(e.g. %s)\n", rep.Duplicates.Reason, rep.Duplicates.Count, strings.Join(rep.Duplicates.Sample, ", "))
+		}
+		if len(rep.Transforms) > 0 {
+			_, _ = fmt.Fprintf(tw, "Transformed:\n")
+			for _, t := range transforms {
+				if n, ok := rep.Transforms[t.name]; ok {
+					_, _ = fmt.Fprintf(tw, "  %s:\t%d\n", t.name, n)
+				}
+			}
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported report format %q, use %q or %q", format, reportText, reportJSON)
+	}
+}
+
+// dryRun decodes the input files provided, transforms them with the chain
+// provided, and validates each record against the rules provided, without
+// storing anything, and writes a data quality report to Main.Stdout once the
+// whole input has been examined.
+func (m Main) dryRun(ctx context.Context, files []inputFile, chain *transformChain, rules *ports.RuleSet) error {
+	if f := m.Conf.ReportFormat; f != reportText && f != reportJSON {
+		return fmt.Errorf("unsupported report format %q, use %q or %q", f, reportText, reportJSON)
+	}
+
+	rep := newReport(rules)
+	if err := m.decodeFiles(ctx, files, checkpoint{}, chain.apply(func(j job) error {
+		rep.add(j)
+		return nil
+	})); err != nil {
+		return err
+	}
+	if len(chain.names) > 0 {
+		rep.Transforms = chain.changed()
+	}
+
+	if err := rep.write(m.Stdout, m.Conf.ReportFormat); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	return nil
+}
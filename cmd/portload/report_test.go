@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestReport(t *testing.T) {
+	t.Log("Ports without coordinates should be flagged, but not rejected")
+	rules, err := ports.NewRuleSet(ports.Rule{Field: "coords", Check: ports.CheckRequired, Severity: ports.SeverityWarning})
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+
+	rep := newReport(rules)
+	for _, j := range []job{
+		{Port: ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000", Coords: []float64{55.51, 25.41}}},
+		{Port: ports.Port{ID: "AEJEA", Name: "Jebel Ali", Code: "52051"}},
+		{Port: ports.Port{ID: "AEAUH", Name: "Abu Dhabi"}},
+		{Port: ports.Port{ID: "AEDXB"}},
+		{Port: ports.Port{ID: "AEFJR", Name: "Al Fujayrah"}},
+		{Port: ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000"}},
+		{Port: ports.Port{ID: "AEKLF"}, Err: errors.New(`port "AEKLF" at offset 1: json: unknown field "harbour"`)},
+	} {
+		rep.add(j)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.write(&buf, reportJSON); err != nil {
+		t.Fatalf("write(): %v", err)
+	}
+
+	want := `{
+  "records": 7,
+  "valid": 3,
+  "invalid": 4,
+  "flagged": 2,
+  "errors": [
+    {
+      "reason": "port code should not be empty",
+      "count": 3,
+      "sample": [
+        "AEAUH",
+        "AEDXB",
+        "AEFJR"
+      ]
+    },
+    {
+      "reason": "port name should not be empty",
+      "count": 1,
+      "sample": [
+        "AEDXB"
+      ]
+    },
+    {
+      "reason": "unknown field \"harbour\"",
+      "count": 1,
+      "sample": [
+        "AEKLF"
+      ]
+    }
+  ],
+  "warnings": [
+    {
+      "reason": "port coords should not be empty",
+      "count": 2,
+      "sample": [
+        "AEJEA",
+        "AEAJM"
+      ]
+    }
+  ],
+  "duplicates": {
+    "reason": "duplicate port ID",
+    "count": 1,
+    "sample": [
+      "AEAJM"
+    ]
+  }
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("write(): unexpected report\nhave: %s\nwant: %s", got, want)
+	}
+}
+
+func TestMalformedReason(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: `{"name": "Ajman", "harbour": "Ajman"}`, want: `unknown field "harbour"`},
+		{raw: `{"name": "Ajman", "code": 52000}`, want: "wrong type for field code: number, want string"},
+		{raw: `{"name": "Ajman", "code": `, want: "malformed JSON"},
+		{raw: `{"name": "Ajman" "code": "52000"}`, want: "malformed JSON"},
+	}
+	for _, tt := range tests {
+		_, err := unmarshalPort("AEAJM", []byte(tt.raw), true)
+		if err == nil {
+			t.Fatalf("unmarshalPort(%s): have no error, want one", tt.raw)
+		}
+		if got := malformedReason(fmt.Errorf("port %q: %w", "AEAJM", err)); got != tt.want {
+			t.Errorf("malformedReason(): %s: have %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+
+	if got, want := malformedReason(decodeLine(line{num: 1, long: true}, true, nil).Err), "line too long"; got != want {
+		t.Errorf("malformedReason(): have %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/christgf/ports"
+)
+
+// loadRules returns the validation rules in the file at Main.Conf.RulesPath, or
+// in the configuration file at Main.Conf.ConfigPath if the rules file is not
+// set, along with the default rules, see ports.NewRuleSet. The same rules file
+// can be shared with the ports HTTP API, so that records are held to the same
+// quality bar everywhere.
+func (m Main) loadRules() (*ports.RuleSet, error) {
+	if m.Conf.RulesPath != "" {
+		f, err := os.Open(m.Conf.RulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening rules file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		rules, err := ports.ReadRuleSet(f)
+		if err != nil {
+			return nil, fmt.Errorf("rules file %s: %w", m.Conf.RulesPath, err)
+		}
+		return rules, nil
+	}
+
+	var fc fileConfig
+	if m.Conf.ConfigPath != "" {
+		var err error
+		if fc, err = loadFileConfig(m.Conf.ConfigPath); err != nil {
+			return nil, err
+		}
+	}
+
+	rules, err := ports.NewRuleSet(fc.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", m.Conf.ConfigPath, err)
+	}
+
+	return rules, nil
+}
+
+// rescan checks the ports scanned against the rules provided, leaving retired
+// ports out, and returns the report on them.
+func rescan(ctx context.Context, scanner ports.Scanner, rules *ports.RuleSet) (*report, error) {
+	rep := newReport(rules)
+	if err := scanner.ScanPorts(ctx, func(p ports.Port) error {
+		if !p.Retired {
+			rep.add(job{Port: p})
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("scanning storage: %w", err)
+	}
+
+	return rep, nil
+}
+
+// Rescan executes the rescan command of Main. It checks every port in the
+// storage system selected by Main.Conf.Store against the rules loaded by
+// loadRules, as a dry run checks the input, and writes a data quality report to
+// Main.Stdout, in Main.Conf.ReportFormat, see report. Records are neither
+// modified nor removed, and retired ports are left out.
+//
+// Rescans are useful once the rules change, to find the records stored under
+// the previous rules that would now be rejected or flagged. They are not
+// supported through the ports HTTP API.
+func (m Main) Rescan(ctx context.Context) error {
+	if f := m.Conf.ReportFormat; f != reportText && f != reportJSON {
+		return fmt.Errorf("unsupported report format %q, use %q or %q", f, reportText, reportJSON)
+	}
+	if m.Conf.Target != "" {
+		return errors.New("rescans read storage directly, -target is not supported")
+	}
+
+	rules, err := m.loadRules()
+	if err != nil {
+		return err
+	}
+
+	store, closeFn, err := m.openStore(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	scanner, ok := store.(ports.Scanner)
+	if !ok {
+		return fmt.Errorf("store %q cannot scan ports, rescans are not supported", m.Conf.Store)
+	}
+
+	rep, err := rescan(ctx, scanner, rules)
+	if err != nil {
+		return err
+	}
+
+	if err := rep.write(m.Stdout, m.Conf.ReportFormat); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	configPath, rulesPath := filepath.Join(dir, "portload.json"), filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(configPath, []byte(`{"rules": [{"field": "code", "check": "required", "severity": "warning"}]}`), 0o644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	if err := os.WriteFile(rulesPath, []byte(`{"rules": [{"field": "code", "check": "required", "severity": "off"}]}`), 0o644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	p := ports.Port{ID: "AEAJM", Name: "Ajman"}
+
+	m := Main{Conf: Config{ConfigPath: configPath}}
+	rules, err := m.loadRules()
+	if err != nil {
+		t.Fatalf("loadRules(): %v", err)
+	}
+	if warnings, err := rules.Validate(p); err != nil || len(warnings) != 1 {
+		t.Errorf("Validate(): have %v, %v, want a warning for code from the config file", warnings, err)
+	}
+
+	t.Log("Setting the rules file, expecting the rules of the config file to be replaced")
+	m.Conf.RulesPath = rulesPath
+	if rules, err = m.loadRules(); err != nil {
+		t.Fatalf("loadRules(): %v", err)
+	}
+	if warnings, err := rules.Validate(p); err != nil || len(warnings) != 0 {
+		t.Errorf("Validate(): have %v, %v, want neither warnings nor errors", warnings, err)
+	}
+}
+
+func TestRescan(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "AEAJM", Name: "Ajman", Code: "52000", Coords: []float64{55.51, 25.41}},
+		{ID: "AEAUH", Name: "Abu Dhabi", Code: "52001"},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005", Country: "UAE"},
+		{ID: "AEFJR", Name: "Al Fujayrah", Code: "52051", Country: "UAE", Retired: true},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	t.Log("Tightening the rules after the ports have been stored")
+	rules, err := ports.NewRuleSet(
+		ports.Rule{Field: "coords", Check: ports.CheckRequired, Severity: ports.SeverityWarning},
+		ports.Rule{Field: "country", Check: ports.CheckEnum, Values: []string{"United Arab Emirates"}},
+	)
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+
+	rep, err := rescan(context.TODO(), db, rules)
+	if err != nil {
+		t.Fatalf("rescan(): %v", err)
+	}
+
+	if got, want := []int{rep.Records, rep.Valid, rep.Invalid, rep.Flagged}, []int{3, 2, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rescan(): have records, valid, invalid, flagged %v, want %v", got, want)
+	}
+	if got, want := rep.Errors[0].Sample, []string{"AEDXB"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rescan(): have invalid ports %v, want %v", got, want)
+	}
+	if got, want := rep.Warnings[0].Sample, []string{"AEAUH"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rescan(): have flagged ports %v, want %v", got, want)
+	}
+}
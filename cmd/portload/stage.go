@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/christgf/ports"
+)
+
+// stageStore prepares staging storage for the import, if the input should be
+// loaded into it and then swapped in place of the ports in storage at once, as
+// requested by Main.Conf.Stage. It returns nil if it should not. It returns an
+// error if the storage cannot stage ports, if sync mode is requested as well,
+// since the staged dataset replaces the ports in storage anyway, and if
+// checkpoints are requested, since staging storage is discarded when the import
+// is interrupted.
+func (m Main) stageStore(ctx context.Context, store ports.InsertFinder) (ports.Staged, error) {
+	if !m.Conf.Stage {
+		return nil, nil
+	}
+
+	if m.Conf.Sync {
+		return nil, errors.New("sync mode cannot be combined with staging, staged imports replace all ports anyway")
+	}
+	if m.Conf.CheckpointPath != "" {
+		return nil, errors.New("staged imports cannot be resumed, use either -stage or -checkpoint")
+	}
+	stager, ok := store.(ports.Stager)
+	if !ok {
+		return nil, fmt.Errorf("store %q cannot stage ports, staged imports are not supported", m.Conf.Store)
+	}
+
+	staged, err := stager.Stage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("staging: %w", err)
+	}
+
+	return staged, nil
+}
+
+// commitStage swaps the ports loaded into staging storage in place of the ports
+// in storage, after checking that staging storage holds exactly the ports
+// stored by the import, as tracked by stored. Since ports in storage that are
+// missing from the input are dropped by the swap, it refuses to commit if the
+// staged ports fall short of the ports in storage by more than
+// Main.Conf.MaxPrune, as a ratio of the ports in storage, much like prune does
+// in sync mode. The ports in storage are only counted if the storage is a
+// ports.Counter.
+func (m Main) commitStage(ctx context.Context, store ports.InsertFinder, staged ports.Staged, stored *seenPorts) error {
+	n, err := staged.CountPorts(ctx)
+	if err != nil {
+		return fmt.Errorf("counting staged ports: %w", err)
+	}
+	if want := stored.count(); n != want {
+		return fmt.Errorf("staged %d ports, want %d ports stored by the import, not swapping", n, want)
+	}
+
+	total := -1
+	if c, ok := store.(ports.Counter); ok {
+		if total, err = c.CountPorts(ctx); err != nil {
+			return fmt.Errorf("counting ports: %w", err)
+		}
+		if dropped := total - n; dropped > 0 && float64(dropped)/float64(total) > float64(m.Conf.MaxPrune) {
+			return fmt.Errorf("refusing to swap %d staged ports for %d ports in storage, dropping more than the maximum rate of %s", n, total, formatRate(float64(m.Conf.MaxPrune)))
+		}
+	}
+
+	previous, err := staged.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("swapping staged ports: %w", err)
+	}
+
+	if total < 0 {
+		m.Logger.Printf("Swapped in %d staged ports", n)
+	} else {
+		m.Logger.Printf("Swapped in %d staged ports, replacing %d", n, total)
+	}
+	if previous != "" {
+		m.Logger.Printf("Previous ports kept in %s for rollback", previous)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestCommitStage(t *testing.T) {
+	db := inmem.Open()
+	for i := 0; i < 10; i++ {
+		if err := db.InsertPort(context.TODO(), ports.Port{ID: fmt.Sprintf("ID%02d", i), Name: "Port"}); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	staged, err := db.Stage(context.TODO())
+	if err != nil {
+		t.Fatalf("Stage(): %v", err)
+	}
+	stored := newSeenPorts()
+	for i := 0; i < 8; i++ {
+		p := ports.Port{ID: fmt.Sprintf("ID%02d", i), Name: "Staged"}
+		if err := staged.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+		stored.add(p.ID)
+	}
+
+	m := Main{Conf: Config{MaxPrune: 0.1}, Logger: log.New(io.Discard, "", 0)}
+	t.Log("Swapping 8 staged ports for 10, above the limit, expecting nothing swapped")
+	if err := m.commitStage(context.TODO(), db, staged, stored); err == nil {
+		t.Fatal("commitStage(): have no error, want limit exceeded")
+	}
+
+	t.Log("Swapping with a port stored but missing from staging, expecting nothing swapped")
+	m.Conf.MaxPrune = 0.2
+	stored.add("ID08")
+	if err := m.commitStage(context.TODO(), db, staged, stored); err == nil {
+		t.Fatal("commitStage(): have no error, want count mismatch")
+	}
+	if p, err := db.FindPort(context.TODO(), "ID00"); err != nil || p.Name != "Port" {
+		t.Fatalf("FindPort(): have %+v, %v, want port left alone", p, err)
+	}
+
+	if err := staged.InsertPort(context.TODO(), ports.Port{ID: "ID08", Name: "Staged"}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	if err := m.commitStage(context.TODO(), db, staged, stored); err != nil {
+		t.Fatalf("commitStage(): %v", err)
+	}
+	if n, err := db.CountPorts(context.TODO()); err != nil || n != 9 {
+		t.Errorf("CountPorts(): have %d, %v, want 9 ports", n, err)
+	}
+	if p, err := db.FindPort(context.TODO(), "ID00"); err != nil || p.Name != "Staged" {
+		t.Errorf("FindPort(): have %+v, %v, want staged port", p, err)
+	}
+}
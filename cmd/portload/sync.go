@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/christgf/ports"
+)
+
+// seenPorts keeps track of the port IDs found in the input of an import. It is
+// safe for concurrent use by multiple goroutines.
+//
+// Port IDs are tracked as 64-bit hashes, as in report, so that memory use stays
+// modest for files with millions of records. A hash collision can only make a
+// port missing from the input look like it was found, so it is kept rather than
+// pruned.
+type seenPorts struct {
+	mu     sync.Mutex
+	hashes map[uint64]struct{}
+}
+
+// newSeenPorts creates an empty seenPorts.
+func newSeenPorts() *seenPorts {
+	return &seenPorts{hashes: make(map[uint64]struct{})}
+}
+
+// add records a port ID as found in the input.
+func (s *seenPorts) add(portID string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(portID))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hashes[h.Sum64()] = struct{}{}
+}
+
+// has reports whether the port ID has been found in the input.
+func (s *seenPorts) has(portID string) bool {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(portID))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.hashes[h.Sum64()]
+	return ok
+}
+
+// count returns the number of distinct port IDs found in the input.
+func (s *seenPorts) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.hashes)
+}
+
+// syncStore returns the storage provided as a ports.Retirer, if ports missing
+// from the input should be pruned once the import is over, as requested by
+// Main.Conf.Sync. It returns nil if they should not. It returns an error if the
+// storage cannot prune ports, or if the import resumes from a checkpoint, since
+// ports found before the checkpoint are not known.
+func (m Main) syncStore(store ports.InsertFinder, start checkpoint) (ports.Retirer, error) {
+	if !m.Conf.Sync {
+		if m.Conf.PruneHard {
+			return nil, errors.New("pruning requires sync mode, use -sync along with -prune-hard")
+		}
+
+		return nil, nil
+	}
+
+	r, ok := store.(ports.Retirer)
+	if !ok {
+		return nil, fmt.Errorf("store %q cannot retire ports, sync mode is not supported", m.Conf.Store)
+	}
+	if start.Records > 0 {
+		return nil, fmt.Errorf("sync mode cannot resume from checkpoint %s, remove it to start over", m.Conf.CheckpointPath)
+	}
+
+	return r, nil
+}
+
+// prune retires the ports in storage that have not been found in the input, or
+// deletes them if Main.Conf.PruneHard is set, in batches of Main.Conf.BatchSize
+// ports. It refuses to prune anything if the ports to prune exceed
+// Main.Conf.MaxPrune, as a ratio of the ports in storage, not counting ports
+// already retired unless they are to be deleted.
+func (m Main) prune(ctx context.Context, r ports.Retirer, seen *seenPorts) error {
+	var (
+		total   int
+		missing []string
+	)
+	if err := r.EachPortID(ctx, func(portID string, retired bool) error {
+		if retired && !m.Conf.PruneHard {
+			return nil
+		}
+
+		total++
+		if !seen.has(portID) {
+			missing = append(missing, portID)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("listing ports: %w", err)
+	}
+
+	if len(missing) == 0 {
+		m.Logger.Printf("No ports missing from the input, nothing to prune")
+		return nil
+	}
+	if ratio := float64(len(missing)) / float64(total); ratio > float64(m.Conf.MaxPrune) {
+		return fmt.Errorf("refusing to prune %d ports out of %d, more than the maximum rate of %s", len(missing), total, formatRate(float64(m.Conf.MaxPrune)))
+	}
+
+	pruneFn, action := r.RetirePorts, "Retired"
+	if m.Conf.PruneHard {
+		pruneFn, action = r.DeletePorts, "Deleted"
+	}
+
+	var pruned int
+	for batchSize := max(m.Conf.BatchSize, 1); len(missing) > 0; {
+		batch := missing[:min(batchSize, len(missing))]
+		missing = missing[len(batch):]
+
+		n, err := pruneFn(ctx, batch)
+		pruned += n
+		if err != nil {
+			m.Logger.Printf("%s %d ports missing from the input before failing", action, pruned)
+			return fmt.Errorf("pruning ports: %w", err)
+		}
+	}
+
+	m.Logger.Printf("%s %d ports missing from the input, out of %d", action, pruned, total)
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestPrune(t *testing.T) {
+	db := inmem.Open()
+	seen := newSeenPorts()
+	for i := 0; i < 10; i++ {
+		p := ports.Port{ID: fmt.Sprintf("ID%02d", i), Name: "Port", Code: "1"}
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+		if i < 8 {
+			seen.add(p.ID)
+		}
+	}
+
+	m := Main{Conf: Config{BatchSize: 1, MaxPrune: 0.1}, Logger: log.New(io.Discard, "", 0)}
+	t.Log("Pruning 2 ports out of 10, above the limit, expecting nothing pruned")
+	if err := m.prune(context.TODO(), db, seen); err == nil {
+		t.Fatal("prune(): have no error, want limit exceeded")
+	}
+
+	m.Conf.MaxPrune = 0.2
+	if err := m.prune(context.TODO(), db, seen); err != nil {
+		t.Fatalf("prune(): %v", err)
+	}
+	for id, want := range map[string]bool{"ID07": false, "ID08": true, "ID09": true} {
+		p, err := db.FindPort(context.TODO(), id)
+		if err != nil {
+			t.Fatalf("FindPort(): %v", err)
+		}
+		if p.Retired != want {
+			t.Errorf("prune(): have port %q retired %t, want %t", id, p.Retired, want)
+		}
+	}
+
+	t.Log("Pruning again, expecting retired ports to be left alone")
+	if err := m.prune(context.TODO(), db, seen); err != nil {
+		t.Fatalf("prune(): %v", err)
+	}
+
+	t.Log("Pruning hard, expecting retired ports to be deleted")
+	m.Conf.PruneHard = true
+	if err := m.prune(context.TODO(), db, seen); err != nil {
+		t.Fatalf("prune(): %v", err)
+	}
+	var n int
+	if err := db.EachPortID(context.TODO(), func(string, bool) error { n++; return nil }); err != nil {
+		t.Fatalf("EachPortID(): %v", err)
+	}
+	if got, want := n, 8; got != want {
+		t.Errorf("prune(): have %d ports left, want %d", got, want)
+	}
+}
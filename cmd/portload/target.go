@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/http"
+)
+
+// openTarget returns a client of the ports HTTP API at Main.Conf.Target, for
+// storing ports through the API rather than storage, with as many connections
+// kept alive as there are workers, see http.Client. It returns an error if the
+// target is not an HTTP URL, and if sync mode or a staged import is requested,
+// since they both need access to storage.
+func (m Main) openTarget() (*http.Client, error) {
+	if m.Conf.Sync || m.Conf.Stage {
+		return nil, errors.New("sync mode and staged imports need access to storage, they are not supported with -target")
+	}
+
+	u, err := url.Parse(m.Conf.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid target %q, use the base URL of the ports HTTP API, e.g. http://localhost:8080", m.Conf.Target)
+	}
+
+	return http.NewClient(m.Conf.Target, http.WithMaxConns(max(m.Conf.Workers, 1)), http.WithRetries(m.Conf.Retries)), nil
+}
+
+// remoteFinder is a ports.Finder looking ports up through the ports HTTP API.
+type remoteFinder struct {
+	client *http.Client
+}
+
+// FindPort implements ports.Finder.
+func (f remoteFinder) FindPort(ctx context.Context, portID string) (*ports.Port, error) {
+	return f.client.GetPortByID(ctx, portID)
+}
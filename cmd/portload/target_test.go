@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestOpenTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Config
+		wantErr bool
+	}{
+		{name: "http", conf: Config{Target: "http://localhost:8080"}},
+		{name: "https with path", conf: Config{Target: "https://example.com/api/"}},
+		{name: "no scheme", conf: Config{Target: "localhost:8080"}, wantErr: true},
+		{name: "no host", conf: Config{Target: "http://"}, wantErr: true},
+		{name: "sync mode", conf: Config{Target: "http://localhost:8080", Sync: true}, wantErr: true},
+		{name: "staged", conf: Config{Target: "http://localhost:8080", Stage: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Main{Conf: tt.conf, Logger: log.New(io.Discard, "", 0)}
+			if _, err := m.openTarget(); (err != nil) != tt.wantErr {
+				t.Errorf("openTarget(): have error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
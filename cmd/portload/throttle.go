@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/christgf/ports"
+)
+
+// adaptInterval is how often the adaptive throttle reconsiders its rate, so
+// that one slow period only halves the rate once, however many batches it
+// slows down.
+const adaptInterval = time.Second
+
+// tokenBucket is a token bucket rate limiter. Tokens are added at a steady rate,
+// up to the burst size, and taken as records are submitted for storage. It is
+// safe for concurrent use by multiple goroutines.
+//
+// Takers may run the bucket into debt rather than wait for enough tokens to
+// accumulate, so that requests larger than the burst size are served too, and
+// later takers wait for the debt to be paid off first.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second.
+	burst  float64 // Maximum number of tokens.
+	tokens float64 // Tokens available, negative when in debt.
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket creates a full token bucket, adding tokens at the rate
+// provided, with a burst size of one second worth of tokens.
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := max(rate, 1)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now(), now: time.Now}
+}
+
+// advance adds the tokens accumulated since the last call. It must be called
+// with tb.mu held.
+func (tb *tokenBucket) advance() {
+	now := tb.now()
+	if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+		tb.tokens = min(tb.tokens+elapsed*tb.rate, tb.burst)
+	}
+	tb.last = now
+}
+
+// reserve takes n tokens, and returns how long the caller should wait before
+// going ahead.
+func (tb *tokenBucket) reserve(n int) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance()
+	tb.tokens -= float64(n)
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// wait takes n tokens, and blocks until the caller may go ahead, or until the
+// context is cancelled.
+func (tb *tokenBucket) wait(ctx context.Context, n int) error {
+	d := tb.reserve(n)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// setRate changes the rate tokens are added at from now on, along with the
+// burst size.
+func (tb *tokenBucket) setRate(rate float64) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance()
+	tb.rate, tb.burst = rate, max(rate, 1)
+	tb.tokens = min(tb.tokens, tb.burst)
+}
+
+// limiter returns a throttle for the import, limiting storage writes to
+// Main.Conf.MaxRPS records per second, adaptively if Main.Conf.Adaptive is set.
+// It returns nil if writes should not be limited, and an error if adaptive mode
+// is requested without a maximum rate to adapt from.
+func (m Main) limiter() (*throttle, error) {
+	if m.Conf.MaxRPS <= 0 {
+		if m.Conf.Adaptive {
+			return nil, errors.New("adaptive throttling starts from a maximum rate, use -max-rps along with -adaptive")
+		}
+
+		return nil, nil
+	}
+
+	var maxLatency time.Duration
+	if m.Conf.Adaptive {
+		maxLatency = max(m.Conf.MaxLatency, time.Millisecond)
+	}
+
+	return newThrottle(m.Conf.MaxRPS, maxLatency, m.Logger.Printf), nil
+}
+
+// throttle limits the rate records are submitted for storage, with a
+// tokenBucket. In adaptive mode, it also watches how long each batch takes to
+// store and whether storage fails, halving the rate when batches are slower than
+// maxLatency or fail within an adaptInterval, and raising it back towards the
+// maximum rate by a tenth of it for every adaptInterval without trouble. It is
+// safe for concurrent use by multiple goroutines.
+type throttle struct {
+	bucket     *tokenBucket
+	maxRate    float64       // Maximum records per second.
+	minRate    float64       // Minimum records per second, in adaptive mode.
+	maxLatency time.Duration // Latency considered slow, zero unless in adaptive mode.
+	logf       func(format string, v ...any)
+
+	mu      sync.Mutex
+	rate    float64   // Current records per second.
+	since   time.Time // Start of the current adaptInterval.
+	slowest time.Duration
+	failed  int // Batches failing in the current adaptInterval.
+}
+
+// newThrottle creates a throttle for the maximum rate provided, in records per
+// second. The throttle is adaptive if maxLatency is positive.
+func newThrottle(maxRate float64, maxLatency time.Duration, logf func(format string, v ...any)) *throttle {
+	return &throttle{
+		bucket:     newTokenBucket(maxRate),
+		maxRate:    maxRate,
+		minRate:    max(maxRate/100, 1),
+		maxLatency: maxLatency,
+		logf:       logf,
+		rate:       maxRate,
+		since:      time.Now(),
+	}
+}
+
+// wait blocks until job j may be submitted for storage, or until the context is
+// cancelled. Jobs rejected before storage go through without waiting.
+func (t *throttle) wait(ctx context.Context, j job) error {
+	if j.Err != nil {
+		return nil
+	}
+
+	return t.bucket.wait(ctx, 1)
+}
+
+// store wraps a storeFunc, keeping track of how long each batch takes to store
+// and whether it fails, in adaptive mode. Ports rejected as invalid do not count
+// as failures, only ports that storage fails to store.
+func (t *throttle) store(store storeFunc) storeFunc {
+	if t.maxLatency <= 0 {
+		return store
+	}
+
+	return func(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+		started := time.Now()
+		results, err := store(ctx, ps)
+
+		failed := err != nil
+		for _, r := range results {
+			if errors.Is(r.Err, &ports.Error{Code: ports.ErrCodeInternal}) {
+				failed = true
+				break
+			}
+		}
+		t.observe(time.Now(), time.Since(started), failed)
+
+		return results, err
+	}
+}
+
+// observe records how long a batch took to store and whether it failed, as of
+// the time provided, and adapts the rate once every adaptInterval.
+func (t *throttle) observe(now time.Time, latency time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.slowest = max(t.slowest, latency)
+	if failed {
+		t.failed++
+	}
+	if now.Sub(t.since) < adaptInterval {
+		return
+	}
+
+	rate := t.rate
+	switch {
+	case t.failed > 0 || t.slowest > t.maxLatency:
+		if rate = max(t.rate/2, t.minRate); rate < t.rate {
+			t.logf("Throttling to %.0f records/s, slowest write took %s, %d writes failed", rate, t.slowest.Round(time.Millisecond), t.failed)
+		}
+	case t.rate < t.maxRate:
+		if rate = min(t.rate+t.maxRate/10, t.maxRate); rate == t.maxRate {
+			t.logf("Back to %.0f records/s", rate)
+		}
+	}
+	if rate != t.rate {
+		t.rate = rate
+		t.bucket.setRate(rate)
+	}
+
+	t.since, t.slowest, t.failed = now, 0, 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	tb := newTokenBucket(100)
+	tb.now, tb.last = func() time.Time { return now }, now
+
+	t.Log("Taking a full burst, expecting no wait")
+	if d := tb.reserve(100); d != 0 {
+		t.Fatalf("reserve(): have %s, want no wait", d)
+	}
+
+	t.Log("Taking more than the burst, expecting a wait for the debt to be paid off")
+	if got, want := tb.reserve(150), 1500*time.Millisecond; got != want {
+		t.Fatalf("reserve(): have %s, want %s", got, want)
+	}
+	if got, want := tb.reserve(1), 1510*time.Millisecond; got != want {
+		t.Fatalf("reserve(): have %s, want %s", got, want)
+	}
+
+	t.Log("Idling for a long time, expecting tokens to be capped at the burst size")
+	now = now.Add(time.Minute)
+	if d := tb.reserve(100); d != 0 {
+		t.Fatalf("reserve(): have %s, want no wait", d)
+	}
+	if got, want := tb.reserve(10), 100*time.Millisecond; got != want {
+		t.Fatalf("reserve(): have %s, want %s", got, want)
+	}
+
+	t.Log("Lowering the rate, expecting longer waits")
+	tb.setRate(10)
+	if got, want := tb.reserve(10), 2*time.Second; got != want {
+		t.Fatalf("reserve(): have %s, want %s", got, want)
+	}
+}
+
+func TestThrottleAdapt(t *testing.T) {
+	var logged []string
+	thr := newThrottle(1000, 100*time.Millisecond, func(format string, v ...any) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	})
+	now := thr.since
+
+	observe := func(latency time.Duration, failed bool) float64 {
+		now = now.Add(adaptInterval)
+		thr.observe(now, latency, failed)
+		return thr.rate
+	}
+
+	t.Log("Writing slowly, expecting the rate to be halved once per interval")
+	thr.observe(now.Add(time.Millisecond), 200*time.Millisecond, false)
+	if got, want := thr.rate, 1000.0; got != want {
+		t.Fatalf("observe(): have rate %v within the interval, want %v", got, want)
+	}
+	if got, want := observe(10*time.Millisecond, false), 500.0; got != want {
+		t.Fatalf("observe(): have rate %v, want %v", got, want)
+	}
+
+	t.Log("Failing to write, expecting the rate to be halved down to the minimum")
+	for range 10 {
+		observe(10*time.Millisecond, true)
+	}
+	if got, want := thr.rate, 10.0; got != want {
+		t.Fatalf("observe(): have rate %v, want %v", got, want)
+	}
+
+	t.Log("Recovering, expecting the rate to be raised back to the maximum")
+	for range 9 {
+		observe(10*time.Millisecond, false)
+	}
+	if got, want := thr.rate, 910.0; got != want {
+		t.Fatalf("observe(): have rate %v, want %v", got, want)
+	}
+	if got, want := observe(10*time.Millisecond, false), 1000.0; got != want {
+		t.Fatalf("observe(): have rate %v, want %v", got, want)
+	}
+	if got, want := observe(10*time.Millisecond, false), 1000.0; got != want {
+		t.Fatalf("observe(): have rate %v, want %v", got, want)
+	}
+
+	if got, want := len(logged), 8; got != want {
+		t.Errorf("observe(): have %d lines logged, want %d: %q", got, want, logged)
+	}
+	if got, want := thr.bucket.rate, 1000.0; got != want {
+		t.Errorf("observe(): have bucket rate %v, want %v", got, want)
+	}
+}
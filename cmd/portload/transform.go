@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/christgf/ports"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transform cleans up ports decoded from the input, before they are stored. It
+// changes the port in place, and reports whether it has changed anything.
+type transform func(p *ports.Port) bool
+
+// namedTransform is a transform, along with the name it is requested by.
+type namedTransform struct {
+	name string
+	fn   transform
+}
+
+// Transforms supported by Config.Transforms, in the order they are applied
+// when all of them are requested.
+var transforms = []namedTransform{
+	{"trim", trimSpace},
+	{"marks", combineMarks},
+	{"nfc", normalizeNFC},
+	{"upper", upperIDs},
+	{"drop-empty", dropEmpty},
+	{"dedupe", dedupeLists},
+	{"sort", sortLists},
+}
+
+// transformAll stands for all the transforms supported, in order.
+const transformAll = "all"
+
+// mapStrings replaces every string field of p, including the elements of list
+// fields, with the result of fn, and reports whether any of them has changed.
+func mapStrings(p *ports.Port, fn func(string) string) bool {
+	var changed bool
+	for _, s := range []*string{&p.ID, &p.Name, &p.Code, &p.City, &p.Province, &p.Country, &p.Timezone} {
+		if v := fn(*s); v != *s {
+			*s, changed = v, true
+		}
+	}
+	for _, list := range [][]string{p.Alias, p.Regions, p.UNLocs} {
+		for i, s := range list {
+			if v := fn(s); v != s {
+				list[i], changed = v, true
+			}
+		}
+	}
+
+	return changed
+}
+
+// mapLists replaces every list field of p with the result of fn, and reports
+// whether any of them has changed. Lists are handed to fn as copies, so that fn
+// may change them in place.
+func mapLists(p *ports.Port, fn func([]string) []string) bool {
+	var changed bool
+	for _, list := range []*[]string{&p.Alias, &p.Regions, &p.UNLocs} {
+		if len(*list) == 0 {
+			continue
+		}
+		if v := fn(slices.Clone(*list)); !slices.Equal(v, *list) {
+			*list, changed = v, true
+		}
+	}
+
+	return changed
+}
+
+// trimSpace removes leading and trailing white space from every string.
+func trimSpace(p *ports.Port) bool {
+	return mapStrings(p, strings.TrimSpace)
+}
+
+// spacingMarks maps accents that stand on their own, as found in input that
+// has been through a lossy conversion, to the combining marks they stand for.
+var spacingMarks = map[rune]rune{
+	'\u00a8': '\u0308', // Diaeresis.
+	'\u00af': '\u0304', // Macron.
+	'\u00b4': '\u0301', // Acute accent.
+	'\u00b8': '\u0327', // Cedilla.
+	'\u02c6': '\u0302', // Circumflex accent.
+	'\u02c7': '\u030c', // Caron.
+	'\u02d8': '\u0306', // Breve.
+	'\u02d9': '\u0307', // Dot above.
+	'\u02da': '\u030a', // Ring above.
+	'\u02db': '\u0328', // Ogonek.
+	'\u02dc': '\u0303', // Small tilde.
+	'\u02dd': '\u030b', // Double acute accent.
+}
+
+// combineMarks replaces accents standing on their own right after a letter,
+// e.g. in "Z¸aby", with the combining marks they stand for, so that they are
+// attached to the letter. Along with nfc, letters and marks are composed into
+// single characters where Unicode has them.
+func combineMarks(p *ports.Port) bool {
+	return mapStrings(p, func(s string) string {
+		if !strings.ContainsFunc(s, func(r rune) bool { _, ok := spacingMarks[r]; return ok }) {
+			return s
+		}
+
+		var (
+			b    strings.Builder
+			prev rune
+		)
+		for _, r := range s {
+			if m, ok := spacingMarks[r]; ok && isLetter(prev) {
+				r = m
+			}
+			b.WriteRune(r)
+			prev = r
+		}
+		return b.String()
+	})
+}
+
+// isLetter reports whether r is a Latin letter, possibly with accents.
+func isLetter(r rune) bool {
+	return 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || 'À' <= r && r <= 'ɏ' && r != '×' && r != '÷'
+}
+
+// normalizeNFC normalizes every string to Unicode Normalization Form C, so that
+// the same text is always encoded the same way.
+func normalizeNFC(p *ports.Port) bool {
+	return mapStrings(p, norm.NFC.String)
+}
+
+// upperIDs upper-cases the port ID and UN/LOCODEs, which are upper case by
+// definition.
+func upperIDs(p *ports.Port) bool {
+	var changed bool
+	if v := strings.ToUpper(p.ID); v != p.ID {
+		p.ID, changed = v, true
+	}
+	for i, s := range p.UNLocs {
+		if v := strings.ToUpper(s); v != s {
+			p.UNLocs[i], changed = v, true
+		}
+	}
+
+	return changed
+}
+
+// dropEmpty removes empty strings from list fields.
+func dropEmpty(p *ports.Port) bool {
+	return mapLists(p, func(list []string) []string {
+		return slices.DeleteFunc(list, func(s string) bool { return s == "" })
+	})
+}
+
+// dedupeLists removes repeated strings from list fields, keeping the first
+// occurrence.
+func dedupeLists(p *ports.Port) bool {
+	return mapLists(p, func(list []string) []string {
+		seen := make(map[string]bool, len(list))
+		return slices.DeleteFunc(list, func(s string) bool {
+			dup := seen[s]
+			seen[s] = true
+			return dup
+		})
+	})
+}
+
+// sortLists sorts list fields.
+func sortLists(p *ports.Port) bool {
+	return mapLists(p, func(list []string) []string {
+		slices.Sort(list)
+		return list
+	})
+}
+
+// transformChain applies a sequence of transforms to the ports decoded from the
+// input, and counts the records changed by each one of them. It is not safe for
+// concurrent use.
+type transformChain struct {
+	names  []string
+	fns    []transform
+	counts map[string]int
+}
+
+// newTransformChain creates a chain of the transforms named, in order. It
+// returns an error if one of them is not supported.
+func newTransformChain(names []string) (*transformChain, error) {
+	c := &transformChain{counts: make(map[string]int)}
+	for _, name := range names {
+		if name == transformAll {
+			for _, t := range transforms {
+				c.names, c.fns = append(c.names, t.name), append(c.fns, t.fn)
+			}
+			continue
+		}
+
+		i := slices.IndexFunc(transforms, func(t namedTransform) bool { return t.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unsupported transform %q, use %s or %s", name, transformNames(), transformAll)
+		}
+		c.names, c.fns = append(c.names, name), append(c.fns, transforms[i].fn)
+	}
+
+	return c, nil
+}
+
+// transformNames returns the names of the transforms supported, for messages.
+func transformNames() string {
+	names := make([]string, len(transforms))
+	for i, t := range transforms {
+		names[i] = t.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// apply wraps fn, so that the ports passed to it are transformed first. Records
+// rejected before storage are passed on as they are.
+func (c *transformChain) apply(fn func(j job) error) func(j job) error {
+	if len(c.fns) == 0 {
+		return fn
+	}
+
+	return func(j job) error {
+		if j.Err == nil {
+			for i, t := range c.fns {
+				if t(&j.Port) {
+					c.counts[c.names[i]]++
+				}
+			}
+		}
+
+		return fn(j)
+	}
+}
+
+// changed returns the number of records changed by each transform, by name.
+func (c *transformChain) changed() map[string]int {
+	counts := make(map[string]int, len(c.names))
+	for _, name := range c.names {
+		counts[name] = c.counts[name]
+	}
+
+	return counts
+}
+
+// String returns the number of records changed by each transform, in order.
+func (c *transformChain) String() string {
+	parts := make([]string, len(c.names))
+	for i, name := range c.names {
+		parts[i] = fmt.Sprintf("%s %d", name, c.counts[name])
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// loadTransforms returns the chain of transforms named by Main.Conf.Transforms,
+// or by the configuration file at Main.Conf.ConfigPath if the transforms are
+// not set otherwise, see fileConfig.
+func (m Main) loadTransforms() (*transformChain, error) {
+	names := m.Conf.Transforms
+	if names == nil && m.Conf.ConfigPath != "" {
+		fc, err := loadFileConfig(m.Conf.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		names = fc.Transforms
+	}
+
+	return newTransformChain(names)
+}
+
+// fileConfig is the configuration file of portload, in JSON format. Settings
+// in the file are overridden by the corresponding command-line flags.
+type fileConfig struct {
+	Transforms []string     `json:"transforms"` // Transforms applied to the ports decoded, in order.
+	Rules      []ports.Rule `json:"rules"`      // Validation rules, see ports.NewRuleSet.
+}
+
+// loadFileConfig reads the configuration file at the path provided. Fields
+// unknown to fileConfig are not allowed, to catch typos.
+func loadFileConfig(path string) (fileConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var fc fileConfig
+	if err := unmarshalRecord(b, &fc, true); err != nil {
+		return fileConfig{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return fc, nil
+}
+
+// namesFlag is a flag.Value for a comma-separated list of names. The list is
+// empty, rather than nil, once the flag is set, even to an empty string.
+type namesFlag []string
+
+func (n *namesFlag) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *namesFlag) Set(s string) error {
+	*n = []string{}
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*n = append(*n, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      ports.Port
+		want    ports.Port
+		changed bool
+	}{
+		{
+			name: "trim",
+			in:   ports.Port{ID: " AEAUH", Name: "Abu Dhabi\t", Alias: []string{" abu dhabi "}},
+			want: ports.Port{ID: "AEAUH", Name: "Abu Dhabi", Alias: []string{"abu dhabi"}},
+		},
+		{
+			name: "marks",
+			in:   ports.Port{Name: "Abu Z\u00b8aby", City: "Sa\u02dco Paulo", Province: "\u00b4"},
+			want: ports.Port{Name: "Abu Z\u0327aby", City: "Sa\u0303o Paulo", Province: "\u00b4"},
+		},
+		{
+			name: "nfc",
+			in:   ports.Port{City: "Sa\u0303o Paulo", Regions: []string{"Sa\u0303o Paulo"}},
+			want: ports.Port{City: "São Paulo", Regions: []string{"São Paulo"}},
+		},
+		{
+			name: "upper",
+			in:   ports.Port{ID: "aeauh", Name: "abu dhabi", UNLocs: []string{"aeauh", "AEDXB"}},
+			want: ports.Port{ID: "AEAUH", Name: "abu dhabi", UNLocs: []string{"AEAUH", "AEDXB"}},
+		},
+		{
+			name: "drop-empty",
+			in:   ports.Port{Alias: []string{"", "Abu Dhabi", ""}, Regions: []string{""}},
+			want: ports.Port{Alias: []string{"Abu Dhabi"}, Regions: []string{}},
+		},
+		{
+			name: "dedupe",
+			in:   ports.Port{UNLocs: []string{"AEAUH", "AEDXB", "AEAUH"}},
+			want: ports.Port{UNLocs: []string{"AEAUH", "AEDXB"}},
+		},
+		{
+			name: "sort",
+			in:   ports.Port{UNLocs: []string{"AEDXB", "AEAUH"}},
+			want: ports.Port{UNLocs: []string{"AEAUH", "AEDXB"}},
+		},
+		{
+			name: "sort",
+			in:   ports.Port{ID: "AEAUH", UNLocs: []string{"AEAUH"}},
+			want: ports.Port{ID: "AEAUH", UNLocs: []string{"AEAUH"}},
+		},
+	}
+
+	for _, tt := range tests {
+		chain, err := newTransformChain([]string{tt.name})
+		if err != nil {
+			t.Fatalf("newTransformChain(): %v", err)
+		}
+
+		p := tt.in
+		changed := chain.fns[0](&p)
+		if !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("%s: have %+v, want %+v", tt.name, p, tt.want)
+		}
+		if want := !reflect.DeepEqual(tt.in, tt.want); changed != want {
+			t.Errorf("%s: have changed %t, want %t", tt.name, changed, want)
+		}
+	}
+}
+
+func TestTransformChain(t *testing.T) {
+	if _, err := newTransformChain([]string{"trim", "capitalize"}); err == nil {
+		t.Fatal("newTransformChain(): have no error, want unsupported transform")
+	}
+
+	chain, err := newTransformChain([]string{transformAll})
+	if err != nil {
+		t.Fatalf("newTransformChain(): %v", err)
+	}
+
+	var got []ports.Port
+	fn := chain.apply(func(j job) error {
+		got = append(got, j.Port)
+		return nil
+	})
+	for _, p := range []ports.Port{
+		{ID: "aeauh ", Alias: []string{"Abu Z\u00b8aby ", "", "Abu Dhabi", "Abu Dhabi"}},
+		{ID: "AEDXB", Name: "Dubai"},
+	} {
+		if err := fn(job{Port: p}); err != nil {
+			t.Fatalf("apply(): %v", err)
+		}
+	}
+
+	want := []ports.Port{
+		{ID: "AEAUH", Alias: []string{"Abu Dhabi", "Abu Z\u0327aby"}},
+		{ID: "AEDXB", Name: "Dubai"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply():\nhave: %+v\nwant: %+v", got, want)
+	}
+	if got, want := chain.String(), "trim 1, marks 1, nfc 0, upper 1, drop-empty 1, dedupe 1, sort 1"; got != want {
+		t.Errorf("String(): have %q, want %q", got, want)
+	}
+}
+
+func TestLoadTransforms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portload.json")
+	if err := os.WriteFile(path, []byte(`{"transforms": ["trim", "upper"]}`), 0o644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	m := Main{Conf: Config{ConfigPath: path}}
+	chain, err := m.loadTransforms()
+	if err != nil {
+		t.Fatalf("loadTransforms(): %v", err)
+	}
+	if got, want := chain.names, []string{"trim", "upper"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loadTransforms(): have %v, want %v from the config file", got, want)
+	}
+
+	t.Log("Setting transforms by flag, expecting the config file to be overridden")
+	m.Conf.Transforms = []string{}
+	if chain, err = m.loadTransforms(); err != nil || len(chain.names) > 0 {
+		t.Errorf("loadTransforms(): have %v, %v, want no transforms", chain.names, err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/christgf/ports"
+)
+
+// Columns of the UN/LOCODE code list, in CSV format, as published by UNECE.
+const (
+	unlocodeChange      = iota // Change indicator, X marks entries to be removed.
+	unlocodeCountry            // ISO 3166 alpha-2 country code.
+	unlocodeLocation           // Location code, empty for country entries.
+	unlocodeName               // Name, with diacritics.
+	unlocodeNameASCII          // Name, without diacritics.
+	unlocodeSubdivision        // ISO 3166-2 subdivision code.
+	unlocodeFunction           // Function classifiers, 1 in the first position for ports.
+	unlocodeStatus             // Entry status.
+	unlocodeDate               // Date of last change.
+	unlocodeIATA               // IATA code, if different from the location code.
+	unlocodeCoordinates        // Coordinates, e.g. 2524N 05530E.
+	unlocodeColumns            // Number of columns required.
+)
+
+// unlocodeCoords matches the compact coordinate notation of UN/LOCODE, degrees
+// and minutes of latitude, followed by degrees and minutes of longitude.
+var unlocodeCoords = regexp.MustCompile(`^(\d{2})(\d{2})([NS])\s+(\d{3})(\d{2})([EW])$`)
+
+// unlocodeReader reads records from the UN/LOCODE code list, in CSV format.
+//
+// Only entries with the port function classifier are read, other entries are
+// skipped, along with country entries and entries marked for removal. Rows
+// that cannot be read or mapped onto a port are returned with job.Err set, and
+// their line number.
+type unlocodeReader struct {
+	csv     *csv.Reader
+	base    int64 // Offset of the CSV input in the file.
+	line    int   // Line number of the CSV input in the file, minus one.
+	seq     int   // Position of the last record read.
+	skipped int   // Number of entries skipped.
+	logger  *log.Logger
+}
+
+// newUNLOCODEReader creates a unlocodeReader for r. When resuming from a
+// checkpoint, r should be positioned right after a row.
+func newUNLOCODEReader(r io.Reader, start checkpoint, logger *log.Logger) *unlocodeReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // Checked when mapping rows onto ports.
+	cr.LazyQuotes = true
+
+	return &unlocodeReader{
+		csv:    cr,
+		base:   start.Offset,
+		line:   start.Line,
+		seq:    start.Records,
+		logger: logger,
+	}
+}
+
+// Read implements recordReader.
+func (ur *unlocodeReader) Read() (job, error) {
+	for {
+		offset := ur.base + ur.csv.InputOffset()
+		row, err := ur.csv.Read()
+		if errors.Is(err, io.EOF) {
+			if ur.skipped > 0 {
+				ur.logger.Printf("Skipped %d UN/LOCODE entries that are not ports", ur.skipped)
+			}
+			return job{}, io.EOF
+		}
+
+		var (
+			parseErr *csv.ParseError
+			lineNum  int
+		)
+		switch {
+		case errors.As(err, &parseErr):
+			lineNum = parseErr.StartLine
+		case err != nil:
+			return job{}, fmt.Errorf("reading CSV: %w", err)
+		default:
+			lineNum, _ = ur.csv.FieldPos(0)
+		}
+
+		j := job{Line: ur.line + lineNum, Offset: offset, End: ur.base + ur.csv.InputOffset(), Raw: csvRow(row)}
+		if err == nil {
+			if len(row) > unlocodeLocation && (row[unlocodeLocation] == "" || row[unlocodeChange] == "X") {
+				continue // Country entry, or entry to be removed.
+			}
+			if len(row) > unlocodeFunction && !strings.HasPrefix(row[unlocodeFunction], "1") {
+				ur.skipped++
+				continue
+			}
+
+			j.Port, err = unlocodePort(row)
+		}
+
+		ur.seq++
+		j.Seq = ur.seq
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", j.Line, err)
+			j.Err = &ports.Error{Code: ports.ErrCodeInvalid, Msg: err.Error(), Cause: err}
+		}
+
+		return j, nil
+	}
+}
+
+// unlocodePort maps a row of the UN/LOCODE code list onto a port. The UN/LOCODE
+// of the entry serves as both the ID and the code of the port, since the code
+// list has no port codes of its own. Country names are not part of the code
+// list, so the country code is used instead.
+func unlocodePort(row []string) (ports.Port, error) {
+	if len(row) < unlocodeColumns {
+		return ports.Port{}, fmt.Errorf("have %d columns, want at least %d", len(row), unlocodeColumns)
+	}
+	for i := range row {
+		row[i] = strings.TrimSpace(latin1ToUTF8(row[i]))
+	}
+
+	locode := row[unlocodeCountry] + row[unlocodeLocation]
+	p := ports.Port{
+		ID:       locode,
+		Name:     row[unlocodeName],
+		Code:     locode,
+		City:     row[unlocodeName],
+		Province: row[unlocodeSubdivision],
+		Country:  row[unlocodeCountry],
+		UNLocs:   []string{locode},
+	}
+	if alias := row[unlocodeNameASCII]; alias != "" && alias != p.Name {
+		p.Alias = []string{alias}
+	}
+
+	if c := row[unlocodeCoordinates]; c != "" {
+		coords, err := parseUNLOCODECoords(c)
+		if err != nil {
+			return ports.Port{ID: locode}, err
+		}
+		p.Coords = coords
+	}
+
+	return p, nil
+}
+
+// parseUNLOCODECoords converts coordinates in the compact UN/LOCODE notation,
+// e.g. 2524N 05530E, into decimal degrees, in [longitude, latitude] order as in
+// ports.Port.
+func parseUNLOCODECoords(s string) ([]float64, error) {
+	m := unlocodeCoords.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid coordinates %q", s)
+	}
+
+	deg := func(d, min, hemisphere string, limit float64) (float64, error) {
+		dv, _ := strconv.ParseFloat(d, 64)
+		mv, _ := strconv.ParseFloat(min, 64)
+		if mv >= 60 || dv+mv/60 > limit {
+			return 0, fmt.Errorf("invalid coordinates %q", s)
+		}
+
+		v := dv + mv/60
+		if hemisphere == "S" || hemisphere == "W" {
+			v = -v
+		}
+		return v, nil
+	}
+
+	lat, err := deg(m[1], m[2], m[3], 90)
+	if err != nil {
+		return nil, err
+	}
+	lon, err := deg(m[4], m[5], m[6], 180)
+	if err != nil {
+		return nil, err
+	}
+
+	return []float64{lon, lat}, nil
+}
+
+// latin1ToUTF8 returns s as is if it is valid UTF-8, and otherwise converts it
+// from ISO 8859-1, the encoding of older UN/LOCODE releases.
+func latin1ToUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// csvRow formats a row back into a line of CSV, without the trailing newline.
+func csvRow(row []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(row)
+	w.Flush()
+
+	return bytes.TrimRight(buf.Bytes(), "\r\n")
+}
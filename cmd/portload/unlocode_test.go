@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestUNLOCODEReader(t *testing.T) {
+	input := strings.Join([]string{
+		`,"AE",,".UNITED ARAB EMIRATES",,,,,,,,`,
+		`,"AE","AJM","Ajman","Ajman","AJ","1-----6-","AI","0307",,"2524N 05530E",`,
+		`,"AE","ALN","Al Ain","Al Ain","AZ","--34----","AI","0901",,"2412N 05544E",`,
+		`X,"AE","OLD","Old Port","Old Port",,"1-------","AI","0307",,,`,
+		`,"AE","BAD","Bad Coordinates","Bad Coordinates",,"1-------","AI","0307",,"9999X 00000E",`,
+		`,"AE","SHORT","Short Row"`,
+		`,"BR","SSZ","Santos","Santos","SP","1234----","AI","0401",,"2357S 04619W",`,
+		`,"CI","ABJ","Abidjan","Abidjan",,"1-------","AI","0401",,,`,
+		`,"DE","DUS","D` + "\xfc" + `sseldorf","Dusseldorf","NW","1-3-----","AI","0401",,"5113N 00647E",`,
+		``,
+	}, "\n")
+
+	logger := log.New(io.Discard, "", 0)
+	ur := newUNLOCODEReader(strings.NewReader(input), checkpoint{}, logger)
+
+	var jobs []job
+	for {
+		j, err := ur.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("Read(): %v", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if got, want := len(jobs), 6; got != want {
+		t.Fatalf("Read(): have %d records, want %d", got, want)
+	}
+
+	want := ports.Port{
+		ID:       "AEAJM",
+		Name:     "Ajman",
+		Code:     "AEAJM",
+		City:     "Ajman",
+		Province: "AJ",
+		Country:  "AE",
+		UNLocs:   []string{"AEAJM"},
+		Coords:   []float64{55.5, 25.4},
+	}
+	if got := jobs[0].Port; !reflect.DeepEqual(got, want) || jobs[0].Err != nil {
+		t.Errorf("Read(): have %+v, error %v, want %+v", got, jobs[0].Err, want)
+	}
+	if got, want := jobs[0].Line, 2; got != want {
+		t.Errorf("Read(): have line %d, want %d", got, want)
+	}
+
+	for _, j := range jobs[1:3] {
+		if !errors.Is(j.Err, &ports.Error{Code: ports.ErrCodeInvalid}) {
+			t.Errorf("Read(): have error %v for line %d, want invalid error", j.Err, j.Line)
+		}
+	}
+	if got, want := jobs[1].Line, 5; got != want {
+		t.Errorf("Read(): have line %d, want %d", got, want)
+	}
+
+	if got, want := jobs[3].Port.Coords, []float64{-(46 + 19.0/60), -(23 + 57.0/60)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Read(): have coordinates %v, want %v", got, want)
+	}
+	if got := jobs[4].Port.Coords; got != nil {
+		t.Errorf("Read(): have coordinates %v, want none", got)
+	}
+	if got, want := jobs[5].Port.Name, "Düsseldorf"; got != want {
+		t.Errorf("Read(): have name %q, want %q", got, want)
+	}
+	if got, want := jobs[5].Port.Alias, []string{"Dusseldorf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Read(): have alias %v, want %v", got, want)
+	}
+}
+
+func TestParseUNLOCODECoords(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []float64
+		wantErr bool
+	}{
+		{input: "2524N 05530E", want: []float64{55.5, 25.4}},
+		{input: "0000N 00000W", want: []float64{0, 0}},
+		{input: "3345S 15100W", want: []float64{-151, -33.75}},
+		{input: "2524N05530E", wantErr: true},
+		{input: "2560N 05530E", wantErr: true},
+		{input: "9100N 05530E", wantErr: true},
+		{input: "2524N 18100E", wantErr: true},
+		{input: "25.4, 55.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUNLOCODECoords(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseUNLOCODECoords(%q): have error %v, want error %t", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUNLOCODECoords(%q): have %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
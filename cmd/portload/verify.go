@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+// errMismatch is returned by Main.Verify when storage does not match the input.
+var errMismatch = errors.New("storage does not match the input")
+
+// checksumReport is the number of ports in a dataset, along with their
+// checksum, see ports.Checksum.
+type checksumReport struct {
+	Ports    int    `json:"ports"`
+	Checksum string `json:"checksum"`
+}
+
+// verification describes how the ports in storage compare with the input.
+// Ports are listed by ID, sorted, unless storage is only known by its checksum.
+type verification struct {
+	Match     bool           `json:"match"`
+	Input     checksumReport `json:"input"`
+	Storage   checksumReport `json:"storage"`
+	Invalid   int            `json:"invalid"`             // Records in the input that would be rejected.
+	Missing   []string       `json:"missing,omitempty"`   // Ports in the input, missing from storage or retired.
+	Extra     []string       `json:"extra,omitempty"`     // Ports in storage, missing from the input.
+	Differing []string       `json:"differing,omitempty"` // Ports in both, holding different information.
+	listed    bool           // Set if ports have been compared one by one.
+}
+
+// compare compares the ports in storage with the ones in the input, one by
+// one, and lists the ones that differ.
+func (v *verification) compare(ctx context.Context, input *inmem.DB, scanner ports.Scanner) error {
+	v.listed = true
+
+	found := make(map[string]bool)
+	if err := scanner.ScanPorts(ctx, func(stored ports.Port) error {
+		p, err := input.FindPort(ctx, stored.ID)
+		switch {
+		case errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}):
+			if !stored.Retired {
+				v.Extra = append(v.Extra, stored.ID)
+			}
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if stored.Retired {
+			return nil // Missing, listed below.
+		}
+		found[stored.ID] = true
+		if ports.Hash(*p) != ports.Hash(stored) {
+			v.Differing = append(v.Differing, stored.ID)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("scanning storage: %w", err)
+	}
+
+	if err := input.ScanPorts(ctx, func(p ports.Port) error {
+		if !found[p.ID] {
+			v.Missing = append(v.Missing, p.ID)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	slices.Sort(v.Missing)
+	slices.Sort(v.Extra)
+	slices.Sort(v.Differing)
+
+	return nil
+}
+
+// write the verification to w, in the format provided.
+func (v *verification) write(w io.Writer, format string) error {
+	switch format {
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case reportText:
+		match := "no"
+		if v.Match {
+			match = "yes"
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Match:\t%s\n", match)
+		_, _ = fmt.Fprintf(tw, "Input:\t%d ports, checksum %s\n", v.Input.Ports, v.Input.Checksum)
+		_, _ = fmt.Fprintf(tw, "Storage:\t%d ports, checksum %s\n", v.Storage.Ports, v.Storage.Checksum)
+		_, _ = fmt.Fprintf(tw, "Invalid:\t%d\n", v.Invalid)
+		if v.listed {
+			_, _ = fmt.Fprintf(tw, "Missing:\t%d\n", len(v.Missing))
+			_, _ = fmt.Fprintf(tw, "Extra:\t%d\n", len(v.Extra))
+			_, _ = fmt.Fprintf(tw, "Differing:\t%d\n", len(v.Differing))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		for _, list := range []struct {
+			sign string
+			ids  []string
+		}{{"-", v.Missing}, {"+", v.Extra}, {"~", v.Differing}} {
+			if len(list.ids) == 0 {
+				continue
+			}
+			_, _ = fmt.Fprintln(w)
+			for _, id := range list.ids {
+				_, _ = fmt.Fprintf(w, "%s %s\n", list.sign, id)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported report format %q, use %q or %q", format, reportText, reportJSON)
+	}
+}
+
+// Verify executes the verify command of Main. It decodes the input files as
+// described in Main.Run, transforming ports as an import would, and computes
+// the checksum of the ports an import would leave in storage, see
+// ports.Checksum: the last occurrence of each port in the input, leaving out
+// records that would be rejected by the rules loaded by loadRules. It compares
+// the checksum with the one of the ports in the storage system selected by
+// Main.Conf.Store, and lists the ports missing from storage, the ports in
+// storage missing from the input, and the ports holding different information,
+// writing the outcome to Main.Stdout in Main.Conf.ReportFormat. Retired ports
+// in storage are treated as missing.
+//
+// If Main.Conf.Target is set, the checksum is compared with the one served by
+// the ports HTTP API there instead, and ports are not listed. Verify returns
+// errMismatch if storage does not match the input.
+//
+// The input is held in memory, to look ports up by ID.
+func (m Main) Verify(ctx context.Context) error {
+	if f := m.Conf.ReportFormat; f != reportText && f != reportJSON {
+		return fmt.Errorf("unsupported report format %q, use %q or %q", f, reportText, reportJSON)
+	}
+
+	files, closeFiles, err := m.openFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles()
+
+	chain, err := m.loadTransforms()
+	if err != nil {
+		return err
+	}
+	rules, err := m.loadRules()
+	if err != nil {
+		return err
+	}
+
+	var v verification
+	input := inmem.Open()
+	if err := m.decodeFiles(ctx, files, checkpoint{}, chain.apply(func(j job) error {
+		if j.Err != nil {
+			v.Invalid++
+			return nil
+		}
+		if _, err := rules.Validate(j.Port); err != nil {
+			v.Invalid++
+			return nil
+		}
+		return input.InsertPort(ctx, j.Port)
+	})); err != nil {
+		return err
+	}
+
+	inputSum, err := (&ports.Service{Ports: input}).Checksum(ctx)
+	if err != nil {
+		return err
+	}
+
+	var storedSum ports.Checksum
+	if m.Conf.Target != "" {
+		client, err := m.openTarget()
+		if err != nil {
+			return err
+		}
+		if storedSum, err = client.Checksum(ctx); err != nil {
+			return fmt.Errorf("retrieving checksum: %w", err)
+		}
+	} else {
+		store, closeFn, err := m.openStore(ctx, false)
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		scanner, ok := store.(ports.Scanner)
+		if !ok {
+			return fmt.Errorf("store %q cannot scan ports, verification is not supported", m.Conf.Store)
+		}
+		if storedSum, err = (&ports.Service{Ports: store}).Checksum(ctx); err != nil {
+			return err
+		}
+		if storedSum != inputSum {
+			if err := v.compare(ctx, input, scanner); err != nil {
+				return err
+			}
+		}
+	}
+
+	v.Match = storedSum == inputSum
+	v.Input = checksumReport{Ports: inputSum.Ports, Checksum: inputSum.String()}
+	v.Storage = checksumReport{Ports: storedSum.Ports, Checksum: storedSum.String()}
+	if err := v.write(m.Stdout, m.Conf.ReportFormat); err != nil {
+		return fmt.Errorf("writing verification: %w", err)
+	}
+
+	if !v.Match {
+		return errMismatch
+	}
+
+	return nil
+}
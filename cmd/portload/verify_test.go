@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestVerificationCompare(t *testing.T) {
+	insert := func(db *inmem.DB, ps ...ports.Port) {
+		t.Helper()
+		for _, p := range ps {
+			if err := db.InsertPort(context.TODO(), p); err != nil {
+				t.Fatalf("InsertPort(): %v", err)
+			}
+		}
+	}
+
+	input := inmem.Open()
+	insert(input,
+		ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000"},
+		ports.Port{ID: "AEAUH", Name: "Abu Dhabi", Code: "52001"},
+		ports.Port{ID: "AEDXB", Name: "Dubai", Code: "52005"},
+		ports.Port{ID: "AEFJR", Name: "Al Fujayrah", Code: "52051"},
+	)
+
+	stored := inmem.Open()
+	insert(stored,
+		ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000", Alias: []string{}},
+		ports.Port{ID: "AEAUH", Name: "Abu Dhabi", Code: "52002"},
+		ports.Port{ID: "AEDXB", Name: "Dubai", Code: "52005", Retired: true},
+		ports.Port{ID: "AEKLF", Name: "Khor al Fakkan", Code: "52052"},
+	)
+
+	var v verification
+	if err := v.compare(context.TODO(), input, stored); err != nil {
+		t.Fatalf("compare(): %v", err)
+	}
+
+	if got, want := v.Missing, []string{"AEDXB", "AEFJR"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("compare(): have missing ports %v, want %v", got, want)
+	}
+	if got, want := v.Extra, []string{"AEKLF"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("compare(): have extra ports %v, want %v", got, want)
+	}
+	if got, want := v.Differing, []string{"AEAUH"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("compare(): have differing ports %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := v.write(&buf, reportText); err != nil {
+		t.Fatalf("write(): %v", err)
+	}
+	for _, line := range []string{"Match:      no\n", "Missing:    2\n", "\n- AEDXB\n- AEFJR\n", "\n+ AEKLF\n", "\n~ AEAUH\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("write(): have no %q in report\n%s", line, buf.String())
+		}
+	}
+}
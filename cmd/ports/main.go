@@ -46,12 +46,26 @@ type Main struct {
 	Logger *log.Logger
 }
 
-// Run executes Main, launching our HTTP API. It bootstraps ports.Service with
-// the appropriate dependencies, establishes a connection to our MongoDB database
-// and configures an HTTP server according to Main.Conf, and begins serving
-// incoming HTTP requests. It returns a meaningful error if something goes wrong,
-// or nil when the HTTP server is eventually shut down.
+// Run executes Main, launching our HTTP API. It loads the validation rules in
+// Main.Conf.RulesPath, if set, see ports.ReadRuleSet, bootstraps ports.Service
+// with the appropriate dependencies, establishes a connection to our MongoDB
+// database and configures an HTTP server according to Main.Conf, and begins
+// serving incoming HTTP requests. It returns a meaningful error if something
+// goes wrong, or nil when the HTTP server is eventually shut down.
 func (m Main) Run(ctx context.Context) error {
+	var rules *ports.RuleSet // Default rules, unless configured otherwise.
+	if m.Conf.RulesPath != "" {
+		f, err := os.Open(m.Conf.RulesPath)
+		if err != nil {
+			return fmt.Errorf("opening rules file: %w", err)
+		}
+		rules, err = ports.ReadRuleSet(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("rules file %s: %w", m.Conf.RulesPath, err)
+		}
+	}
+
 	mongoDB, err := mongo.Open(m.Conf.MongoDBURI)
 	if err != nil {
 		return fmt.Errorf("creating MongoDB client: %w", err)
@@ -74,6 +88,7 @@ func (m Main) Run(ctx context.Context) error {
 	// Create a new ports service with resolved dependencies.
 	service := &ports.Service{
 		Ports: mongoDB,
+		Rules: rules,
 	}
 
 	// Set up HTTP server, backed by our ports service implementation.
@@ -92,6 +107,7 @@ func (m Main) Run(ctx context.Context) error {
 type Config struct {
 	HTTPListenAddr string // The listener address for the HTTP server.
 	MongoDBURI     string // The MongoDB connection URI.
+	RulesPath      string // Path to the validation rules file, default rules if empty.
 }
 
 // ParseFlags parses the command line arguments and produces application
@@ -104,6 +120,7 @@ func ParseFlags() Config {
 	{
 		flag.StringVar(&conf.HTTPListenAddr, "http-listen-addr", getEnvString("PORTS_HTTP_LISTEN_ADDR", ":http"), "HTTP server port")
 		flag.StringVar(&conf.MongoDBURI, "mongodb-conn-uri", getEnvString("PORTS_MONGODB_CONN_URI", "mongodb://localhost:27017/ports"), "MongoDB connection URI")
+		flag.StringVar(&conf.RulesPath, "rules", getEnvString("PORTS_RULES", ""), "Path to JSON validation rules file")
 	}
 	flag.Parse()
 
@@ -0,0 +1,129 @@
+package ports
+
+import (
+	"context"
+	"math"
+)
+
+// EarthRadiusKm is the radius of the Earth used for distances, in kilometers.
+// It is the radius MongoDB uses for spherical queries, so that distances are
+// the same regardless of storage.
+const EarthRadiusKm = 6378.1
+
+// Point is a position on the surface of the Earth, in decimal degrees. Note
+// that Port.Coords holds the longitude first, as GeoJSON does, see
+// Port.Location.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Valid reports whether the latitude is between -90 and 90, and the longitude
+// between -180 and 180.
+func (pt Point) Valid() bool {
+	return pt.Lat >= -90 && pt.Lat <= 90 && pt.Lon >= -180 && pt.Lon <= 180
+}
+
+// Location returns the position of the Port, from Port.Coords, which holds the
+// longitude first and the latitude second. It reports false if the Port does
+// not have a valid pair of coordinates.
+func (p Port) Location() (Point, bool) {
+	if len(p.Coords) != 2 {
+		return Point{}, false
+	}
+
+	pt := Point{Lat: p.Coords[1], Lon: p.Coords[0]}
+	return pt, pt.Valid()
+}
+
+// Distance returns the great-circle distance between two points, in
+// kilometers, see EarthRadiusKm.
+func Distance(a, b Point) float64 {
+	const rad = math.Pi / 180
+	lat1, lat2 := a.Lat*rad, b.Lat*rad
+	dLat, dLon := (b.Lat-a.Lat)*rad, (b.Lon-a.Lon)*rad
+
+	// Haversine formula, accurate for small distances as well.
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// NearbyPort is a Port along with its distance from a position, see
+// Service.NearestPorts.
+type NearbyPort struct {
+	Port
+	DistanceKm float64 // Great-circle distance, see Distance.
+}
+
+// Locator can find the Port records in storage nearest to a position.
+type Locator interface {
+	// NearestPorts returns up to limit Port records located within
+	// maxDistance kilometers of the position provided, leaving retired
+	// records and records without a Port.Location out, sorted by distance,
+	// nearest first. A maxDistance of zero means no limit.
+	NearestPorts(ctx context.Context, at Point, maxDistance float64, limit int) ([]NearbyPort, error)
+}
+
+// Number of ports returned by Service.NearestPorts.
+const (
+	DefaultNearestLimit = 10  // Ports returned, unless requested otherwise.
+	MaxNearestLimit     = 100 // Ports returned at most, larger limits are capped.
+)
+
+// ErrGeoUnsupported is the error returned when the storage system cannot find
+// Port records by location, see Locator and AreaLister.
+var ErrGeoUnsupported = &Error{Code: ErrCodeInternal, Msg: "storage cannot find ports by location"}
+
+// ErrInvalidPosition is the error returned when a position is not valid, see
+// Point.Valid.
+var ErrInvalidPosition = &Error{Code: ErrCodeInvalid, Msg: "latitude should be between -90 and 90, and longitude between -180 and 180"}
+
+// ErrInvalidDistance is the error returned when the maximum distance requested
+// from Service.NearestPorts is negative.
+var ErrInvalidDistance = &Error{Code: ErrCodeInvalid, Msg: "distance should not be negative"}
+
+// ErrInvalidLimit is the error returned when the number of ports requested
+// from Service.NearestPorts is negative.
+var ErrInvalidLimit = &Error{Code: ErrCodeInvalid, Msg: "limit should not be negative"}
+
+// NearestPorts retrieves the Port records in storage nearest to the position
+// provided, in decimal degrees, along with their distance, nearest first.
+// Records further than maxDistance kilometers away are left out, unless
+// maxDistance is zero, and so are retired records and records without a
+// Port.Location.
+//
+// It returns limit records at most, DefaultNearestLimit if limit is zero,
+// capped to MaxNearestLimit. It returns an error if the arguments are not
+// valid, if the storage system cannot find records by location, see Locator,
+// if it fails, or if the context is cancelled before the operation is
+// completed.
+func (s *Service) NearestPorts(ctx context.Context, lat, lon, maxDistance float64, limit int) ([]NearbyPort, error) {
+	locator, ok := s.Ports.(Locator)
+	if !ok {
+		return nil, ErrGeoUnsupported
+	}
+
+	at := Point{Lat: lat, Lon: lon}
+	if !at.Valid() {
+		return nil, ErrInvalidPosition
+	}
+	if !(maxDistance >= 0) || math.IsInf(maxDistance, 1) {
+		return nil, ErrInvalidDistance
+	}
+
+	switch {
+	case limit < 0:
+		return nil, ErrInvalidLimit
+	case limit == 0:
+		limit = DefaultNearestLimit
+	case limit > MaxNearestLimit:
+		limit = MaxNearestLimit
+	}
+
+	nearest, err := locator.NearestPorts(ctx, at, maxDistance, limit)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not find nearest ports", Cause: err}
+	}
+
+	return nearest, nil
+}
@@ -0,0 +1,123 @@
+package ports_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mock"
+)
+
+func TestPortLocation(t *testing.T) {
+	tests := []struct {
+		coords []float64
+		want   ports.Point
+		ok     bool
+	}{
+		{coords: []float64{10.75, 59.91}, want: ports.Point{Lat: 59.91, Lon: 10.75}, ok: true},
+		{coords: []float64{-171.76, -13.83}, want: ports.Point{Lat: -13.83, Lon: -171.76}, ok: true},
+		{coords: []float64{59.91, 100.75}, ok: false}, // Latitude first.
+		{coords: []float64{10.75}, ok: false},
+		{coords: nil, ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := ports.Port{Coords: tt.coords}.Location()
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("Location(%v): have %+v, %t, want %+v, %t", tt.coords, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ports.Point
+		want float64
+	}{
+		{name: "same position", a: ports.Point{Lat: 59.91, Lon: 10.75}, b: ports.Point{Lat: 59.91, Lon: 10.75}, want: 0},
+		{name: "one degree along the equator", a: ports.Point{}, b: ports.Point{Lon: 1}, want: 111.319},
+		{name: "Oslo to Bergen", a: ports.Point{Lat: 59.91, Lon: 10.75}, b: ports.Point{Lat: 60.39, Lon: 5.32}, want: 305.475},
+		{name: "Suva to Apia, across the antimeridian", a: ports.Point{Lat: -18.14, Lon: 178.44}, b: ports.Point{Lat: -13.83, Lon: -171.76}, want: 1152.911},
+		{name: "pole to pole", a: ports.Point{Lat: 90}, b: ports.Point{Lat: -90}, want: 20037.392},
+	}
+	for _, tt := range tests {
+		if got := ports.Distance(tt.a, tt.b); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("Distance(): %s: have %.3f km, want %.3f km", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServiceNearestPorts(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}},
+		{ID: "NOBGO", Name: "Bergen", Coords: []float64{5.32, 60.39}},
+		{ID: "NOHFT", Name: "Hammerfest", Coords: []float64{23.68, 70.66}, Retired: true},
+		{ID: "NOTOS", Name: "Tromso", Coords: []float64{18.96, 69.65}},
+		{ID: "NOXXX", Name: "Nowhere"},
+		{ID: "AEDXB", Name: "Dubai", Coords: []float64{55.27, 25.2}},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	s := &ports.Service{Ports: db}
+
+	ids := func(ps []ports.NearbyPort) []string {
+		var ids []string
+		for _, p := range ps {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Log("Finding ports near Oslo, expecting located current ports only, nearest first")
+	nearest, err := s.NearestPorts(context.TODO(), 59.91, 10.75, 0, 0)
+	if err != nil {
+		t.Fatalf("NearestPorts(): %v", err)
+	}
+	if got, want := ids(nearest), []string{"NOOSL", "NOBGO", "NOTOS", "AEDXB"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NearestPorts(): have %v, want %v", got, want)
+	}
+	if got, want := nearest[1].DistanceKm, ports.Distance(ports.Point{Lat: 59.91, Lon: 10.75}, ports.Point{Lat: 60.39, Lon: 5.32}); got != want {
+		t.Errorf("NearestPorts(): have distance %f km to Bergen, want %f km", got, want)
+	}
+
+	t.Log("Finding ports within 1000 km of Oslo, two at most")
+	nearest, err = s.NearestPorts(context.TODO(), 59.91, 10.75, 1000, 2)
+	if err != nil {
+		t.Fatalf("NearestPorts(): %v", err)
+	}
+	if got, want := ids(nearest), []string{"NOOSL", "NOBGO"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NearestPorts(): have %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		lat, lon, distance float64
+		limit              int
+		want               error
+	}{
+		{lat: 91, lon: 10.75, want: ports.ErrInvalidPosition},
+		{lat: 10.75, lon: 181, want: ports.ErrInvalidPosition},
+		{lat: math.NaN(), lon: 0, want: ports.ErrInvalidPosition},
+		{lat: 59.91, lon: 10.75, distance: -1, want: ports.ErrInvalidDistance},
+		{lat: 59.91, lon: 10.75, distance: math.NaN(), want: ports.ErrInvalidDistance},
+		{lat: 59.91, lon: 10.75, limit: -1, want: ports.ErrInvalidLimit},
+	} {
+		if _, err := s.NearestPorts(context.TODO(), tt.lat, tt.lon, tt.distance, tt.limit); !errors.Is(err, tt.want) {
+			t.Errorf("NearestPorts(%v, %v, %v, %d): have %v, want %v", tt.lat, tt.lon, tt.distance, tt.limit, err, tt.want)
+		}
+	}
+}
+
+func TestServiceNearestPortsUnsupported(t *testing.T) {
+	s := &ports.Service{Ports: &mock.InsertFinder{}}
+
+	if _, err := s.NearestPorts(context.TODO(), 59.91, 10.75, 0, 10); !errors.Is(err, ports.ErrGeoUnsupported) {
+		t.Errorf("NearestPorts(): have %v, want %v", err, ports.ErrGeoUnsupported)
+	}
+}
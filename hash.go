@@ -0,0 +1,46 @@
+package ports
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+)
+
+// Hash returns a fingerprint of the information held by Port, so that storage
+// systems can tell whether a record has changed without comparing each field.
+// Ports holding the same information have the same fingerprint. Nil and empty
+// lists are considered the same.
+func Hash(p Port) string {
+	sum := fingerprint(p)
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint returns the fingerprint of p, see Hash, in binary form.
+func fingerprint(p Port) [16]byte {
+	buf := make([]byte, 0, 256)
+	appendString := func(s string) {
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+
+	for _, s := range []string{p.ID, p.Name, p.Code, p.City, p.Province, p.Country, p.Timezone} {
+		appendString(s)
+	}
+	for _, list := range [][]string{p.Alias, p.Regions, p.UNLocs} {
+		buf = binary.AppendUvarint(buf, uint64(len(list)))
+		for _, s := range list {
+			appendString(s)
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(p.Coords)))
+	for _, c := range p.Coords {
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c))
+	}
+	if p.Retired {
+		buf = append(buf, 1)
+	}
+
+	sum := sha256.Sum256(buf)
+	return [16]byte(sum[:16])
+}
@@ -0,0 +1,40 @@
+package ports_test
+
+import (
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestHash(t *testing.T) {
+	p := ports.Port{
+		ID:      "AEAJM",
+		Name:    "Ajman",
+		Code:    "52000",
+		City:    "Ajman",
+		Country: "United Arab Emirates",
+		Alias:   []string{},
+		UNLocs:  []string{"AEAJM"},
+		Coords:  []float64{55.5136433, 25.4052165},
+	}
+
+	same := p
+	same.Alias = nil
+	if got, want := ports.Hash(same), ports.Hash(p); got != want {
+		t.Errorf("Hash(): have %s for nil alias, want %s as for empty alias", got, want)
+	}
+
+	for name, change := range map[string]func(p *ports.Port){
+		"name":        func(p *ports.Port) { p.Name = "Ajman Port" },
+		"fields":      func(p *ports.Port) { p.City, p.Province = "", "Ajman" },
+		"lists":       func(p *ports.Port) { p.Alias, p.UNLocs = []string{"AEAJM"}, nil },
+		"coordinates": func(p *ports.Port) { p.Coords = []float64{25.4052165, 55.5136433} },
+		"retired":     func(p *ports.Port) { p.Retired = true },
+	} {
+		changed := p
+		change(&changed)
+		if ports.Hash(changed) == ports.Hash(p) {
+			t.Errorf("Hash(): have the same fingerprint after changing %s", name)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/christgf/ports"
+)
+
+const (
+	defaultClientTimeout  = 60 * time.Second
+	defaultClientRetries  = 5
+	defaultClientBackoff  = 200 * time.Millisecond
+	defaultClientMaxConns = 16
+	maxClientBackoff      = 10 * time.Second
+)
+
+// Client is a client of the HTTP API served by Server, providing the same
+// capabilities as PortService over HTTP.
+//
+// Requests failing with HTTP 5xx responses, or not getting a response at all,
+// are retried with exponential backoff, which is safe since storing a port
+// again has no further effect. Errors reported by the server are returned as
+// ports.Error instances, carrying the error code of the ErrorResponse, and the
+// details of validation errors as a ports.ValidationError cause.
+// Connections are kept alive and reused across requests. A Client is safe for
+// concurrent use by multiple goroutines.
+type Client struct {
+	client  *http.Client
+	baseURL string
+	retries int
+	backoff time.Duration
+	noBulk  atomic.Bool // Set once the server turns out not to serve HandleStorePorts.
+}
+
+// WithRetries specifies how many times a failing request should be retried.
+// Zero means requests are not retried.
+func WithRetries(n int) func(*Client) {
+	return func(c *Client) {
+		c.retries = max(n, 0)
+	}
+}
+
+// WithBackoff specifies how long the client should wait before retrying a
+// failing request for the first time. The wait doubles after every attempt.
+//
+// Currently used to make retries faster in tests.
+func WithBackoff(d time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.backoff = d
+	}
+}
+
+// WithMaxConns specifies how many idle connections to the server the client
+// should keep alive for reuse, usually as many as the requests it is expected
+// to make concurrently.
+func WithMaxConns(n int) func(*Client) {
+	return func(c *Client) {
+		transport := c.client.Transport.(*http.Transport)
+		transport.MaxIdleConns, transport.MaxIdleConnsPerHost = n, n
+	}
+}
+
+// NewClient creates and returns a new Client for the HTTP API served at the
+// base URL provided, e.g. http://localhost:8080. It is configured with
+// reasonable defaults, but configuration can be overridden using functional
+// options.
+func NewClient(baseURL string, opts ...func(*Client)) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns, transport.MaxIdleConnsPerHost = defaultClientMaxConns, defaultClientMaxConns
+
+	c := &Client{
+		client:  &http.Client{Transport: transport, Timeout: defaultClientTimeout},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		retries: defaultClientRetries,
+		backoff: defaultClientBackoff,
+	}
+	for _, optionFn := range opts {
+		optionFn(c)
+	}
+
+	return c
+}
+
+// StorePort records port information through the HTTP API, see
+// HandleStorePort, and returns the warnings reported, if any.
+func (c *Client) StorePort(ctx context.Context, p ports.Port) ([]*ports.FieldError, error) {
+	var out warnings
+	if err := c.do(ctx, http.MethodPost, "/ports", newPort(p), &out); err != nil {
+		return nil, err
+	}
+
+	return newFieldErrors(out.Warnings), nil
+}
+
+// StorePorts records information for multiple ports through the HTTP API, see
+// HandleStorePorts, reporting the outcome for each of them as a Result, in the
+// same order as the ports provided, as ports.Service.StorePorts does. If the
+// server does not serve HandleStorePorts, ports are stored one by one with
+// StorePort instead, in which case Result.Change is ports.ChangeUnknown. It
+// returns an error if the batch as a whole could not be stored.
+func (c *Client) StorePorts(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+	if !c.noBulk.Load() {
+		results, err := c.storeBulk(ctx, ps)
+		if !errors.Is(err, errNoEndpoint) {
+			return results, err
+		}
+		c.noBulk.Store(true)
+	}
+
+	results := make([]ports.Result, len(ps))
+	for i, p := range ps {
+		results[i].ID = p.ID
+		found, err := c.StorePort(ctx, p)
+		if err != nil {
+			if !isClientError(err) {
+				return nil, err
+			}
+			results[i].Err = err
+		}
+		results[i].Warnings = found
+	}
+
+	return results, nil
+}
+
+// storeBulk stores ports with a single request to HandleStorePorts, in chunks
+// of up to maxBulkPorts ports.
+func (c *Client) storeBulk(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+	results := make([]ports.Result, 0, len(ps))
+	for len(ps) > 0 {
+		chunk := ps[:min(len(ps), maxBulkPorts)]
+		ps = ps[len(chunk):]
+
+		batch := make([]port, len(chunk))
+		for i, p := range chunk {
+			batch[i] = newPort(p)
+		}
+
+		var out []result
+		if err := c.do(ctx, http.MethodPost, "/ports/bulk", batch, &out); err != nil {
+			return nil, err
+		}
+		if len(out) != len(chunk) {
+			return nil, fmt.Errorf("POST /ports/bulk: have %d results for %d ports", len(out), len(chunk))
+		}
+
+		for _, r := range out {
+			res := ports.Result{ID: r.ID, Change: parseChange(r.Change), Warnings: newFieldErrors(r.Warnings)}
+			if r.Error != nil {
+				res.Err = newError(*r.Error)
+			}
+			results = append(results, res)
+		}
+	}
+
+	return results, nil
+}
+
+// GetPortByID retrieves port information through the HTTP API, see
+// HandleGetPort.
+func (c *Client) GetPortByID(ctx context.Context, portID string) (*ports.Port, error) {
+	var p port
+	if err := c.do(ctx, http.MethodGet, "/ports?portID="+url.QueryEscape(portID), nil, &p); err != nil {
+		return nil, err
+	}
+
+	port := p.port()
+	port.Retired = p.Retired
+
+	return &port, nil
+}
+
+// Checksum retrieves the checksum of the port records served by the HTTP API,
+// see HandleChecksum.
+func (c *Client) Checksum(ctx context.Context) (ports.Checksum, error) {
+	var out checksum
+	if err := c.do(ctx, http.MethodGet, "/ports/checksum", nil, &out); err != nil {
+		return ports.Checksum{}, err
+	}
+
+	b, err := hex.DecodeString(out.Checksum)
+	if err != nil || len(b) != 16 {
+		return ports.Checksum{}, fmt.Errorf("GET /ports/checksum: invalid checksum %q", out.Checksum)
+	}
+
+	return ports.Checksum{
+		Ports: out.Ports,
+		Sum:   [2]uint64{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])},
+	}, nil
+}
+
+// errNoEndpoint is returned by Client.do when the server does not serve the
+// endpoint requested, as opposed to an endpoint reporting an error.
+var errNoEndpoint = errors.New("endpoint not served")
+
+// do sends an HTTP request with the method and path provided, and with in as
+// its JSON body, if not nil, and decodes the JSON response body into out, if
+// not nil. Requests are retried as described in Client.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = c.try(ctx, method, path, body, out); !retry || attempt >= c.retries {
+			break
+		}
+
+		d := min(c.backoff<<attempt, maxClientBackoff)
+		d = d/2 + rand.N(d/2+1) // Jitter, so that clients do not retry in lockstep.
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s %s: %w", method, path, context.Cause(ctx))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// try sends an HTTP request once, as described in Client.do, and reports
+// whether it should be retried if it fails.
+func (c *Client) try(ctx context.Context, method, path string, body []byte, out any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body) // Drain, so that the connection can be reused.
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil {
+			return false, nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("decoding response: %w", err)
+		}
+		return false, nil
+	}
+
+	var errResp ErrorResponse
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") || json.NewDecoder(resp.Body).Decode(&errResp) != nil {
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+			return false, errNoEndpoint
+		}
+		errResp = ErrorResponse{Code: ports.ErrCodeInternal, Message: resp.Status}
+	}
+
+	return resp.StatusCode >= 500, newError(errResp)
+}
+
+// newError returns the ports.Error described by an ErrorResponse. Details are
+// returned as a ports.ValidationError cause, so that callers can examine them
+// as they would for ports.Validate.
+func newError(resp ErrorResponse) *ports.Error {
+	err := &ports.Error{Code: resp.Code, Msg: resp.Message}
+	if len(resp.Details) > 0 {
+		err.Cause = &ports.ValidationError{Fields: newFieldErrors(resp.Details)}
+	}
+
+	return err
+}
+
+// newFieldErrors returns the problems described by the details provided.
+func newFieldErrors(details []FieldDetail) []*ports.FieldError {
+	if len(details) == 0 {
+		return nil
+	}
+
+	errs := make([]*ports.FieldError, len(details))
+	for i, d := range details {
+		errs[i] = &ports.FieldError{Field: d.Field, Err: errors.New(d.Reason)}
+	}
+
+	return errs
+}
+
+// isClientError reports whether err describes a problem with the request, as
+// reported by the server with an HTTP 4xx response, rather than a failure of
+// the server.
+func isClientError(err error) bool {
+	var portsErr *ports.Error
+	return errors.As(err, &portsErr) && portsErr.Code != ports.ErrCodeInternal
+}
+
+// parseChange returns the ports.Change named by s, see ports.Change.String.
+func parseChange(s string) ports.Change {
+	for _, c := range []ports.Change{ports.ChangeCreated, ports.ChangeUpdated, ports.ChangeUnchanged} {
+		if s == c.String() {
+			return c
+		}
+	}
+
+	return ports.ChangeUnknown
+}
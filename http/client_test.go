@@ -0,0 +1,180 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/http"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mock"
+)
+
+func TestClientStorePorts(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{
+		Ports: &mock.InsertFinder{
+			InsertPortFn: func(_ context.Context, p ports.Port) error {
+				if p.ID == "GRPIR" {
+					return errors.New("the database has gone missing")
+				}
+				return nil
+			},
+		},
+	}, http.WithLoggerOutput(io.Discard))
+
+	var failures atomic.Int32
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if failures.Add(1) <= 2 {
+			t.Log("Failing the first requests, the client should retry them")
+			w.WriteHeader(nethttp.StatusBadGateway)
+			return
+		}
+		srv.HandleStorePorts(w, r)
+	}))
+	defer ts.Close()
+
+	client := http.NewClient(ts.URL, http.WithBackoff(time.Millisecond))
+	results, err := client.StorePorts(context.Background(), []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101"},
+		{ID: "", Name: "Nowhere"},
+		{ID: "GRPIR", Name: "Piraeus", Code: "47701"},
+	})
+	if err != nil {
+		t.Fatalf("StorePorts(): %v", err)
+	}
+	if got, want := len(results), 3; got != want {
+		t.Fatalf("StorePorts(): have %d results, want %d", got, want)
+	}
+
+	if got, want := results[0].Err, error(nil); got != want {
+		t.Errorf("StorePorts(): have error %v for port %q, want %v", got, results[0].ID, want)
+	}
+	if got, want := results[1].Err, (&ports.Error{Code: ports.ErrCodeInvalid}); !errors.Is(got, want) {
+		t.Errorf("StorePorts(): have error %v for port %q, want %v", got, results[1].ID, want)
+	}
+	var validationErr *ports.ValidationError
+	if !errors.As(results[1].Err, &validationErr) || len(validationErr.Fields) != 2 || validationErr.Fields[0].Field != "id" {
+		t.Errorf("StorePorts(): have error %v for port %q, want validation details for id and code", results[1].Err, results[1].ID)
+	}
+	if got, want := results[2].Err, (&ports.Error{Code: ports.ErrCodeInternal}); !errors.Is(got, want) {
+		t.Errorf("StorePorts(): have error %v for port %q, want %v", got, results[2].ID, want)
+	}
+}
+
+func TestClientStorePortsFallback(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{Ports: &mock.InsertFinder{}}, http.WithLoggerOutput(io.Discard))
+
+	var stored atomic.Int32
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("POST /ports", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		stored.Add(1)
+		srv.HandleStorePort(w, r)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	t.Log("The server does not serve the bulk endpoint, ports should be stored one by one")
+
+	client := http.NewClient(ts.URL, http.WithBackoff(time.Millisecond))
+	results, err := client.StorePorts(context.Background(), []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101"},
+		{ID: "", Name: "Nowhere"},
+	})
+	if err != nil {
+		t.Fatalf("StorePorts(): %v", err)
+	}
+
+	if got, want := stored.Load(), int32(2); got != want {
+		t.Errorf("StorePorts(): have %d requests to store a single port, want %d", got, want)
+	}
+	if got, want := results[0].Err, error(nil); got != want {
+		t.Errorf("StorePorts(): have error %v for port %q, want %v", got, results[0].ID, want)
+	}
+	if got, want := results[1].Err, (&ports.Error{Code: ports.ErrCodeInvalid}); !errors.Is(got, want) {
+		t.Errorf("StorePorts(): have error %v for port %q, want %v", got, results[1].ID, want)
+	}
+}
+
+func TestClientRetriesExhausted(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(nethttp.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, `{"code":"internal","message":"could not insert"}`)
+	}))
+	defer ts.Close()
+
+	client := http.NewClient(ts.URL, http.WithRetries(2), http.WithBackoff(time.Millisecond))
+	_, err := client.StorePort(context.Background(), ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"})
+
+	if got, want := err, (&ports.Error{Code: ports.ErrCodeInternal}); !errors.Is(got, want) {
+		t.Errorf("StorePort(): have error %v, want %v", got, want)
+	}
+	if got, want := requests.Load(), int32(3); got != want {
+		t.Errorf("StorePort(): have %d requests, want %d", got, want)
+	}
+}
+
+func TestClientGetPortByID(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{
+		Ports: &mock.InsertFinder{
+			FindPortFn: func(ctx context.Context, portID string) (*ports.Port, error) {
+				if portID != "MXACA" {
+					return nil, &ports.Error{Code: ports.ErrCodeNotFound, Msg: "could not be found"}
+				}
+				return &ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"}, nil
+			},
+		},
+	}, http.WithLoggerOutput(io.Discard))
+
+	ts := httptest.NewServer(nethttp.HandlerFunc(srv.HandleGetPort))
+	defer ts.Close()
+
+	client := http.NewClient(ts.URL)
+	p, err := client.GetPortByID(context.Background(), "MXACA")
+	if err != nil {
+		t.Fatalf("GetPortByID(): %v", err)
+	}
+	if got, want := p.Name, "Acapulco"; got != want {
+		t.Errorf("GetPortByID(): have name %q, want %q", got, want)
+	}
+
+	_, err = client.GetPortByID(context.Background(), "FOOBAR")
+	if got, want := err, (&ports.Error{Code: ports.ErrCodeNotFound}); !errors.Is(got, want) {
+		t.Errorf("GetPortByID(): have error %v, want %v", got, want)
+	}
+}
+
+func TestClientChecksum(t *testing.T) {
+	db := inmem.Open()
+	var want ports.Checksum
+	for _, p := range []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101"},
+		{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"},
+	} {
+		want.Add(p)
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	srv := http.NewServer(":http", &ports.Service{Ports: db}, http.WithLoggerOutput(io.Discard))
+	ts := httptest.NewServer(nethttp.HandlerFunc(srv.HandleChecksum))
+	defer ts.Close()
+
+	got, err := http.NewClient(ts.URL).Checksum(context.Background())
+	if err != nil {
+		t.Fatalf("Checksum(): %v", err)
+	}
+	if got != want {
+		t.Errorf("Checksum(): have %d ports with checksum %s, want %d ports with checksum %s", got.Ports, got, want.Ports, want)
+	}
+}
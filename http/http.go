@@ -17,8 +17,13 @@ import (
 
 // PortService provides the business logic implementation for our HTTP API.
 type PortService interface {
-	StorePort(ctx context.Context, p ports.Port) error
+	StorePort(ctx context.Context, p ports.Port) ([]*ports.FieldError, error)
+	StorePorts(ctx context.Context, ps []ports.Port) ([]ports.Result, error)
 	GetPortByID(ctx context.Context, portID string) (*ports.Port, error)
+	ListPorts(ctx context.Context, f ports.Filter, cursor string, size int) (*ports.Page, error)
+	NearestPorts(ctx context.Context, lat, lon, maxDistance float64, limit int) ([]ports.NearbyPort, error)
+	ListPortsWithin(ctx context.Context, a ports.Area, cursor string, size int) (*ports.Page, error)
+	Checksum(ctx context.Context) (ports.Checksum, error)
 }
 
 const (
@@ -94,8 +99,13 @@ func NewServer(addr string, ps PortService, opts ...func(*Server)) *Server {
 		mux.HandleFunc("GET /ready", srv.HandleReady)
 
 		// Ports API.
-		mux.HandleFunc("GET /ports", srv.HandleGetPort)
+		mux.HandleFunc("GET /ports", srv.HandleGetPorts)
 		mux.HandleFunc("POST /ports", srv.HandleStorePort)
+		mux.HandleFunc("POST /ports/bulk", srv.HandleStorePorts)
+		mux.HandleFunc("GET /ports/checksum", srv.HandleChecksum)
+		mux.HandleFunc("GET /ports/nearest", srv.HandleNearestPorts)
+		mux.HandleFunc("GET /ports/within", srv.HandleListPortsWithinBBox)
+		mux.HandleFunc("POST /ports/within", srv.HandleListPortsWithinPolygon)
 	}
 
 	return srv
@@ -122,10 +132,33 @@ func (s *Server) Serve(ctx context.Context) error {
 }
 
 // ErrorResponse is the HTTP response body delivered for every HTTP request that
-// cannot be processed.
+// cannot be processed. Details are only set for ports that fail validation,
+// describing the problem with each field, see ports.ValidationError.
 type ErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []FieldDetail `json:"details,omitempty"`
+}
+
+// FieldDetail describes the problem with the value of a single port field, see
+// ports.FieldError.
+type FieldDetail struct {
+	Field  string `json:"field"`  // Path of the field, e.g. "unlocs[1]".
+	Reason string `json:"reason"` // The problem, in human-readable form.
+}
+
+// newFieldDetails returns the descriptions of the problems provided.
+func newFieldDetails(errs []*ports.FieldError) []FieldDetail {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	details := make([]FieldDetail, len(errs))
+	for i, fe := range errs {
+		details[i] = FieldDetail{Field: fe.Field, Reason: fe.Error()}
+	}
+
+	return details
 }
 
 // ReplyErr examines the error provided and replies to an HTTP request with an
@@ -140,6 +173,13 @@ type ErrorResponse struct {
 // The function does not otherwise end the request; the caller should ensure no
 // further writes are done to w.
 func (s *Server) ReplyErr(w http.ResponseWriter, err error) {
+	statusCode, resp := newErrorResponse(err)
+	s.Reply(w, statusCode, resp)
+}
+
+// newErrorResponse examines the error provided and returns the ErrorResponse
+// describing it, along with an appropriate HTTP status code, see ReplyErr.
+func newErrorResponse(err error) (int, *ErrorResponse) {
 	var (
 		statusCode = http.StatusServiceUnavailable // Default HTTP status code.
 		errCode    = ports.ErrCodeInternal         // Default response error code.
@@ -155,15 +195,26 @@ func (s *Server) ReplyErr(w http.ResponseWriter, err error) {
 		switch portsErr.Code {
 		case ports.ErrCodeInvalid:
 			statusCode = http.StatusBadRequest
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				statusCode = http.StatusRequestEntityTooLarge // Request body too large, see decodeBody.
+			}
 		case ports.ErrCodeNotFound:
 			statusCode = http.StatusNotFound
 		}
 	}
 
-	s.Reply(w, statusCode, &ErrorResponse{
+	resp := &ErrorResponse{
 		Code:    errCode,
 		Message: errMsg,
-	})
+	}
+
+	var validationErr *ports.ValidationError
+	if errCode == ports.ErrCodeInvalid && errors.As(err, &validationErr) {
+		resp.Details = newFieldDetails(validationErr.Fields)
+	}
+
+	return statusCode, resp
 }
 
 // Reply to an HTTP request with the specified HTTP code and an optional payload.
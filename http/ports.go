@@ -2,7 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/christgf/ports"
 )
@@ -20,22 +25,31 @@ type port struct {
 	Timezone string    `json:"timezone,omitempty"`
 	UNLocs   []string  `json:"unlocs,omitempty"`
 	Coords   []float64 `json:"coords,omitempty"`
+	Retired  bool      `json:"retired,omitempty"` // Set by storage, ignored when storing.
 }
 
-// HandleGetPort handles HTTP requests for retrieving a ports.Port record. The
-// HTTP request must provide a non-empty port identifier as a "portID" query
-// parameter. All responses are JSON encoded, and all errors are JSON
-// representations of an ErrorResponse instance.
-func (s *Server) HandleGetPort(w http.ResponseWriter, r *http.Request) {
-	portID := r.URL.Query().Get("portID")
-
-	p, err := s.Ports.GetPortByID(r.Context(), portID)
-	if err != nil {
-		s.ReplyErr(w, err)
-		return
+// newPort returns the representation of a ports.Port as a JSON document.
+func newPort(p ports.Port) port {
+	return port{
+		ID:       p.ID,
+		Name:     p.Name,
+		Code:     p.Code,
+		City:     p.City,
+		Province: p.Province,
+		Country:  p.Country,
+		Alias:    p.Alias,
+		Regions:  p.Regions,
+		Timezone: p.Timezone,
+		UNLocs:   p.UNLocs,
+		Coords:   p.Coords,
+		Retired:  p.Retired,
 	}
+}
 
-	s.Reply(w, http.StatusOK, port{
+// port returns the ports.Port described by the JSON document. Retired is left
+// out, since it is up to storage.
+func (p port) port() ports.Port {
+	return ports.Port{
 		ID:       p.ID,
 		Name:     p.Name,
 		Code:     p.Code,
@@ -47,18 +61,354 @@ func (s *Server) HandleGetPort(w http.ResponseWriter, r *http.Request) {
 		Timezone: p.Timezone,
 		UNLocs:   p.UNLocs,
 		Coords:   p.Coords,
-	})
+	}
+}
+
+// HandleGetPort handles HTTP requests for retrieving a ports.Port record. The
+// HTTP request must provide a non-empty port identifier as a "portID" query
+// parameter. All responses are JSON encoded, and all errors are JSON
+// representations of an ErrorResponse instance.
+func (s *Server) HandleGetPort(w http.ResponseWriter, r *http.Request) {
+	portID := r.URL.Query().Get("portID")
+
+	p, err := s.Ports.GetPortByID(r.Context(), portID)
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	s.Reply(w, http.StatusOK, newPort(*p))
+}
+
+// HandleGetPorts handles HTTP requests for ports.Port records, retrieving a
+// single record with HandleGetPort if the HTTP request provides a "portID"
+// query parameter, or listing records with HandleListPorts otherwise.
+func (s *Server) HandleGetPorts(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("portID") {
+		s.HandleGetPort(w, r)
+		return
+	}
+
+	s.HandleListPorts(w, r)
+}
+
+// page is the representation of ports.Page as a JSON document. NextCursor is
+// omitted on the last page.
+type page struct {
+	Ports      []port `json:"ports"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ErrInvalidLimit is the error returned when the number of ports requested from
+// HandleListPorts, HandleNearestPorts or the handlers listing ports within an
+// area is not a number.
+var ErrInvalidLimit = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "limit should be a number"}
+
+// HandleListPorts handles HTTP requests for listing ports.Port records, a page
+// at a time, see ports.Service.ListPorts. Records may be filtered by the
+// "country", "province", "city", "region" and "timezone" query parameters,
+// pages are sized by the "limit" query parameter, and the "cursor" query
+// parameter selects the page following the one that returned it. The handler
+// should respond with HTTP 200 (OK) and a JSON representation of a page
+// instance. All errors are JSON representations of an ErrorResponse instance.
+func (s *Server) HandleListPorts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var size int
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			s.ReplyErr(w, ErrInvalidLimit)
+			return
+		}
+		size = n
+	}
+
+	f := ports.Filter{
+		Country:  q.Get("country"),
+		Province: q.Get("province"),
+		City:     q.Get("city"),
+		Region:   q.Get("region"),
+		Timezone: q.Get("timezone"),
+	}
+	pg, err := s.Ports.ListPorts(r.Context(), f, q.Get("cursor"), size)
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	s.Reply(w, http.StatusOK, newPage(pg))
+}
+
+// newPage returns the representation of a ports.Page as a JSON document.
+func newPage(pg *ports.Page) page {
+	out := page{Ports: make([]port, len(pg.Ports)), NextCursor: pg.Next}
+	for i, p := range pg.Ports {
+		out.Ports[i] = newPort(p)
+	}
+
+	return out
+}
+
+// nearbyPort is the representation of ports.NearbyPort as a JSON document.
+type nearbyPort struct {
+	port
+	DistanceKm float64 `json:"distance_km"` // Rounded to the meter.
+}
+
+// nearbyPorts is the HTTP response body delivered for ports.NearbyPort records,
+// nearest first.
+type nearbyPorts struct {
+	Ports []nearbyPort `json:"ports"`
+}
+
+// ErrInvalidCoordinates is the error returned when the position requested from
+// HandleNearestPorts is missing, or is not a pair of numbers.
+var ErrInvalidCoordinates = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "lat and lon should be numbers"}
+
+// ErrInvalidRadius is the error returned when the distance requested from
+// HandleNearestPorts is not a number.
+var ErrInvalidRadius = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "radius_km should be a number"}
+
+// HandleNearestPorts handles HTTP requests for the ports.Port records nearest
+// to a position, see ports.Service.NearestPorts. The position is given by the
+// "lat" and "lon" query parameters, in decimal degrees, records further away
+// than the "radius_km" query parameter are left out, if provided, and the
+// "limit" query parameter caps the number of records. The handler should
+// respond with HTTP 200 (OK) and a JSON representation of a nearbyPorts
+// instance. All errors are JSON representations of an ErrorResponse instance.
+func (s *Server) HandleNearestPorts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(q.Get("lat"), 64)
+	if err != nil {
+		s.ReplyErr(w, ErrInvalidCoordinates)
+		return
+	}
+	lon, err := strconv.ParseFloat(q.Get("lon"), 64)
+	if err != nil {
+		s.ReplyErr(w, ErrInvalidCoordinates)
+		return
+	}
+
+	var radius float64
+	if v := q.Get("radius_km"); v != "" {
+		if radius, err = strconv.ParseFloat(v, 64); err != nil {
+			s.ReplyErr(w, ErrInvalidRadius)
+			return
+		}
+	}
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			s.ReplyErr(w, ErrInvalidLimit)
+			return
+		}
+	}
+
+	nearest, err := s.Ports.NearestPorts(r.Context(), lat, lon, radius, limit)
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	out := nearbyPorts{Ports: make([]nearbyPort, len(nearest))}
+	for i, p := range nearest {
+		out.Ports[i] = nearbyPort{port: newPort(p.Port), DistanceKm: math.Round(p.DistanceKm*1000) / 1000}
+	}
+
+	s.Reply(w, http.StatusOK, out)
+}
+
+// ErrInvalidBBox is the error returned when the bounding box requested from
+// HandleListPortsWithinBBox is missing, or is not four numbers.
+var ErrInvalidBBox = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "bbox should be four numbers: min lon, min lat, max lon, max lat"}
+
+// HandleListPortsWithinBBox handles HTTP requests for listing the ports.Port
+// records located within a bounding box, a page at a time, see
+// ports.Service.ListPortsWithin. The box is given by the "bbox" query
+// parameter, as a GeoJSON bbox: the longitude and latitude of the south-west
+// corner, followed by those of the north-east corner, separated by commas. A
+// box whose western longitude is greater than its eastern one crosses the
+// antimeridian. Pages are requested as with HandleListPorts, and the handler
+// should respond with HTTP 200 (OK) and a JSON representation of a page
+// instance. All errors are JSON representations of an ErrorResponse instance.
+func (s *Server) HandleListPortsWithinBBox(w http.ResponseWriter, r *http.Request) {
+	values := strings.Split(r.URL.Query().Get("bbox"), ",")
+	if len(values) != 4 {
+		s.ReplyErr(w, ErrInvalidBBox)
+		return
+	}
+
+	var bbox [4]float64
+	for i, v := range values {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			s.ReplyErr(w, ErrInvalidBBox)
+			return
+		}
+		bbox[i] = n
+	}
+
+	s.listPortsWithin(w, r, ports.Area{BBox: &ports.BBox{MinLon: bbox[0], MinLat: bbox[1], MaxLon: bbox[2], MaxLat: bbox[3]}})
+}
+
+// geometry is a GeoJSON geometry object, as expected by
+// HandleListPortsWithinPolygon.
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ErrInvalidGeometry is the error returned when the HTTP request body of
+// HandleListPortsWithinPolygon is not a GeoJSON Polygon or MultiPolygon.
+var ErrInvalidGeometry = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "geometry should be a GeoJSON Polygon or MultiPolygon"}
+
+// maxPolygonBytes is the maximum size of the HTTP request body accepted by
+// HandleListPortsWithinPolygon.
+const maxPolygonBytes = 1 << 20
+
+// maxPolygonVertices is the maximum number of positions, across all rings and
+// polygons, accepted by HandleListPortsWithinPolygon, which is plenty for a sea
+// area or an EEZ while keeping the cost of a query bounded.
+const maxPolygonVertices = 10000
+
+// ErrTooManyVertices is the error returned when the geometry requested by
+// HandleListPortsWithinPolygon has more than maxPolygonVertices positions.
+var ErrTooManyVertices = &ports.Error{Code: ports.ErrCodeInvalid, Msg: fmt.Sprintf("too many vertices, %d at most", maxPolygonVertices)}
+
+// polygons returns the ports.Polygon records described by a GeoJSON Polygon or
+// MultiPolygon. Positions hold the longitude first, and any altitude is
+// ignored. It returns ErrTooManyVertices for more than maxPolygonVertices
+// positions.
+func (g geometry) polygons() ([]ports.Polygon, error) {
+	var coordinates [][][][]float64
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, ErrInvalidGeometry
+		}
+		coordinates = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+			return nil, ErrInvalidGeometry
+		}
+	default:
+		return nil, ErrInvalidGeometry
+	}
+
+	var vertices int
+	for _, rings := range coordinates {
+		for _, ring := range rings {
+			vertices += len(ring)
+		}
+	}
+	if vertices > maxPolygonVertices {
+		return nil, ErrTooManyVertices
+	}
+
+	polygons := make([]ports.Polygon, len(coordinates))
+	for i, rings := range coordinates {
+		polygons[i] = make(ports.Polygon, len(rings))
+		for j, ring := range rings {
+			polygons[i][j] = make([]ports.Point, len(ring))
+			for k, position := range ring {
+				if len(position) < 2 {
+					return nil, ErrInvalidGeometry
+				}
+				polygons[i][j][k] = ports.Point{Lat: position[1], Lon: position[0]}
+			}
+		}
+	}
+
+	return polygons, nil
+}
+
+// HandleListPortsWithinPolygon handles HTTP requests for listing the
+// ports.Port records located within polygons, a page at a time, see
+// ports.Service.ListPortsWithin. The HTTP request body must be a GeoJSON
+// Polygon or MultiPolygon geometry object of up to maxPolygonBytes bytes, or the
+// handler responds with HTTP 413 (Request Entity Too Large), and up to
+// maxPolygonVertices positions. Pages are requested as with HandleListPorts, and
+// the handler should respond with HTTP 200 (OK) and a JSON representation of a
+// page instance. All errors are JSON representations of an ErrorResponse
+// instance.
+func (s *Server) HandleListPortsWithinPolygon(w http.ResponseWriter, r *http.Request) {
+	var g geometry
+	if err := decodeBody(w, r, maxPolygonBytes, &g); err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	polygons, err := g.polygons()
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	s.listPortsWithin(w, r, ports.Area{Polygons: polygons})
+}
+
+// listPortsWithin replies with a page of the ports.Port records located within
+// the area, sized and selected by the "limit" and "cursor" query parameters, as
+// with HandleListPorts.
+func (s *Server) listPortsWithin(w http.ResponseWriter, r *http.Request, a ports.Area) {
+	q := r.URL.Query()
+
+	var size int
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			s.ReplyErr(w, ErrInvalidLimit)
+			return
+		}
+		size = n
+	}
+
+	pg, err := s.Ports.ListPortsWithin(r.Context(), a, q.Get("cursor"), size)
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	s.Reply(w, http.StatusOK, newPage(pg))
 }
 
 // ErrDecodeRequest is the error returned when an HTTP request payload cannot be
 // decoded, usually because of invalid JSON input.
 var ErrDecodeRequest = &ports.Error{Code: ports.ErrCodeInvalid, Msg: "could not decode"}
 
+// decodeBody decodes the JSON body of HTTP request r into v, reading up to limit
+// bytes of it. It returns ErrDecodeRequest if the body cannot be decoded, or an
+// error wrapping an http.MaxBytesError if it is larger than limit, which
+// ReplyErr responds to with HTTP 413 (Request Entity Too Large).
+func decodeBody(w http.ResponseWriter, r *http.Request, limit int64, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &ports.Error{Code: ports.ErrCodeInvalid, Msg: fmt.Sprintf("request body too large, %d bytes at most", maxErr.Limit), Cause: err}
+		}
+		return ErrDecodeRequest
+	}
+
+	return nil
+}
+
+// warnings is the HTTP response body delivered for ports stored despite
+// problems found with rules of ports.SeverityWarning.
+type warnings struct {
+	Warnings []FieldDetail `json:"warnings"`
+}
+
 // HandleStorePort handles HTTP requests for creating a new ports.Port record.
 // The HTTP request must provide all the necessary information as part of the
 // request body in JSON format. The handler should respond with HTTP 201
-// (Created) and no response body when the information is successfully recorded.
-// All errors are JSON representations of an ErrorResponse instance.
+// (Created) and no response body when the information is successfully recorded,
+// or a JSON representation of a warnings instance if the port has been stored
+// with warnings. All errors are JSON representations of an ErrorResponse
+// instance.
 func (s *Server) HandleStorePort(w http.ResponseWriter, r *http.Request) {
 	var p port
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -66,22 +416,107 @@ func (s *Server) HandleStorePort(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.Ports.StorePort(r.Context(), ports.Port{
-		ID:       p.ID,
-		Name:     p.Name,
-		Code:     p.Code,
-		City:     p.City,
-		Province: p.Province,
-		Country:  p.Country,
-		Alias:    p.Alias,
-		Regions:  p.Regions,
-		Timezone: p.Timezone,
-		UNLocs:   p.UNLocs,
-		Coords:   p.Coords,
-	}); err != nil {
+	found, err := s.Ports.StorePort(r.Context(), p.port())
+	if err != nil {
 		s.ReplyErr(w, err)
 		return
 	}
+	if len(found) > 0 {
+		s.Reply(w, http.StatusCreated, warnings{Warnings: newFieldDetails(found)})
+		return
+	}
 
 	s.Reply(w, http.StatusCreated, nil)
 }
+
+// maxBulkPorts is the maximum number of ports accepted by HandleStorePorts in
+// a single HTTP request.
+const maxBulkPorts = 1000
+
+// maxBulkBytes is the maximum size of the HTTP request body accepted by
+// HandleStorePorts, allowing for maxBulkPorts ports of up to 8 KiB on average,
+// so that oversized requests are turned down before being decoded in full.
+const maxBulkBytes = maxBulkPorts * (8 << 10)
+
+// ErrTooManyPorts is the error returned when an HTTP request holds more ports
+// than HandleStorePorts accepts at once.
+var ErrTooManyPorts = &ports.Error{Code: ports.ErrCodeInvalid, Msg: fmt.Sprintf("too many ports, %d at most", maxBulkPorts)}
+
+// result is the representation of ports.Result as a JSON document. Change is
+// omitted when it is not known, Error is only set for ports that could not be
+// stored, and Warnings only for ports stored with warnings.
+type result struct {
+	ID       string         `json:"id"`
+	Change   string         `json:"change,omitempty"`
+	Error    *ErrorResponse `json:"error,omitempty"`
+	Warnings []FieldDetail  `json:"warnings,omitempty"`
+}
+
+// HandleStorePorts handles HTTP requests for storing multiple ports.Port
+// records at once. The HTTP request body must be a JSON array of ports, as
+// expected by HandleStorePort, holding up to maxBulkPorts ports in up to
+// maxBulkBytes bytes, or the handler responds with HTTP 413 (Request Entity Too
+// Large). The handler should respond with HTTP 200 (OK) and a JSON array
+// describing the outcome for each port, in the same order, once the batch has
+// been processed, even if some of the ports could not be stored. Errors
+// affecting the batch as a whole are JSON representations of an ErrorResponse
+// instance.
+func (s *Server) HandleStorePorts(w http.ResponseWriter, r *http.Request) {
+	var batch []port
+	if err := decodeBody(w, r, maxBulkBytes, &batch); err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+	if len(batch) > maxBulkPorts {
+		s.ReplyErr(w, ErrTooManyPorts)
+		return
+	}
+
+	ps := make([]ports.Port, len(batch))
+	for i, p := range batch {
+		ps[i] = p.port()
+	}
+
+	res, err := s.Ports.StorePorts(r.Context(), ps)
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	out := make([]result, len(res))
+	for i, r := range res {
+		out[i].ID = r.ID
+		if r.Err != nil {
+			_, out[i].Error = newErrorResponse(r.Err)
+			continue
+		}
+		if r.Change != ports.ChangeUnknown {
+			out[i].Change = r.Change.String()
+		}
+		out[i].Warnings = newFieldDetails(r.Warnings)
+	}
+
+	s.Reply(w, http.StatusOK, out)
+}
+
+// checksum is the representation of ports.Checksum as a JSON document.
+type checksum struct {
+	Ports    int    `json:"ports"`
+	Checksum string `json:"checksum"`
+}
+
+// HandleChecksum handles HTTP requests for the checksum of the ports.Port
+// records in storage, see ports.Checksum, so that the records served by
+// different instances or environments can be compared. The handler should
+// respond with HTTP 200 (OK) and a JSON document holding the number of records
+// and their checksum. All errors are JSON representations of an ErrorResponse
+// instance.
+func (s *Server) HandleChecksum(w http.ResponseWriter, r *http.Request) {
+	c, err := s.Ports.Checksum(r.Context())
+	if err != nil {
+		s.ReplyErr(w, err)
+		return
+	}
+
+	s.Reply(w, http.StatusOK, checksum{Ports: c.Ports, Checksum: c.String()})
+}
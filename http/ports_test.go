@@ -7,11 +7,13 @@ import (
 	"io"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/christgf/ports"
 	"github.com/christgf/ports/http"
+	"github.com/christgf/ports/inmem"
 	"github.com/christgf/ports/mock"
 )
 
@@ -191,7 +193,27 @@ func TestHandleStorePortInvalidError(t *testing.T) {
 		t.Errorf("HandleStorePort(): have content type header %q, want %q", got, want)
 	}
 
-	wantBody := `{"code":"invalid","message":"port ID should not be empty"}`
+	wantBody := `{"code":"invalid","message":"port ID should not be empty; port code should not be empty","details":[{"field":"id","reason":"port ID should not be empty"},{"field":"code","reason":"port code should not be empty"}]}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleStorePort(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleStorePortWarnings(t *testing.T) {
+	rules, err := ports.NewRuleSet(ports.Rule{Field: "coords", Check: ports.CheckRequired, Severity: ports.SeverityWarning})
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+	srv := http.NewServer(":http", &ports.Service{Ports: &mock.InsertFinder{}, Rules: rules}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleStorePort(rec, httptest.NewRequest("POST", "/ports", bytes.NewBufferString(`{ "ID": "MXACA", "Name": "Acapulco", "Code": "20101" }`)))
+
+	if got, want := rec.Result().StatusCode, 201; got != want {
+		t.Errorf("HandleStorePort(): have response code %d, want %d", got, want)
+	}
+
+	wantBody := `{"warnings":[{"field":"coords","reason":"port coords should not be empty"}]}`
 	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
 		t.Errorf("HandleStorePort(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
 	}
@@ -232,6 +254,284 @@ func TestHandleStorePortInsertError(t *testing.T) {
 	}
 }
 
+func TestHandleStorePorts(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{
+		Ports: &mock.InsertFinder{
+			InsertPortFn: func(_ context.Context, p ports.Port) error {
+				if p.ID == "GRPIR" {
+					return errors.New("the database has gone missing")
+				}
+				return nil
+			},
+		},
+	}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleStorePorts(rec, httptest.NewRequest("POST", "/ports/bulk", bytes.NewBufferString(`[
+		{ "ID": "MXACA", "Name": "Acapulco", "Code": "20101" },
+		{ "ID": "", "Name": "Nowhere", "Code": "00000" },
+		{ "ID": "GRPIR", "Name": "Piraeus", "Code": "47701" }
+	]`)))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleStorePorts(): have response code %d, want %d", got, want)
+	}
+
+	wantBody := `[{"id":"MXACA"},{"id":"","error":{"code":"invalid","message":"port ID should not be empty","details":[{"field":"id","reason":"port ID should not be empty"}]}},{"id":"GRPIR","error":{"code":"internal","message":"could not insert"}}]`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleStorePorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleStorePortsTooMany(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{}, http.WithWriteTimeout(time.Second))
+
+	body := "[" + strings.Repeat(`{"ID":"MXACA"},`, 1000) + `{"ID":"MXACA"}]`
+
+	rec := httptest.NewRecorder()
+	srv.HandleStorePorts(rec, httptest.NewRequest("POST", "/ports/bulk", bytes.NewBufferString(body)))
+
+	if got, want := rec.Result().StatusCode, 400; got != want {
+		t.Errorf("HandleStorePorts(): have response code %d, want %d", got, want)
+	}
+
+	wantBody := `{"code":"invalid","message":"too many ports, 1000 at most"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleStorePorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleStorePortsTooLarge(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{}, http.WithWriteTimeout(time.Second))
+
+	body := `[{"ID":"MXACA","Name":"` + strings.Repeat("A", 9<<20) + `"}]`
+
+	rec := httptest.NewRecorder()
+	srv.HandleStorePorts(rec, httptest.NewRequest("POST", "/ports/bulk", bytes.NewBufferString(body)))
+
+	if got, want := rec.Result().StatusCode, 413; got != want {
+		t.Errorf("HandleStorePorts(): have response code %d, want %d", got, want)
+	}
+
+	wantBody := `{"code":"invalid","message":"request body too large, 8192000 bytes at most"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleStorePorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleChecksum(t *testing.T) {
+	db := inmem.Open()
+	p := ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"}
+	if err := db.InsertPort(context.TODO(), p); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+
+	var want ports.Checksum
+	want.Add(p)
+
+	srv := http.NewServer(":http", &ports.Service{Ports: db}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleChecksum(rec, httptest.NewRequest("GET", "/ports/checksum", nil))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleChecksum(): have response code %d, want %d", got, want)
+	}
+
+	wantBody := `{"ports":1,"checksum":"` + want.String() + `"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleChecksum(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleListPorts(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Code: "40301", Country: "Norway"},
+		{ID: "NOBGO", Name: "Bergen", Code: "40302", Country: "Norway"},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005", Country: "United Arab Emirates"},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	srv := http.NewServer(":http", &ports.Service{Ports: db}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleGetPorts(rec, httptest.NewRequest("GET", "/ports?country=Norway&limit=1", nil))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleGetPorts(): have response code %d, want %d", got, want)
+	}
+	wantBody := `{"ports":[{"id":"NOBGO","name":"Bergen","code":"40302","city":"","province":"","country":"Norway"}],"next_cursor":"Tk9CR08"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleGetPorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+
+	t.Log("Requesting the next page, expecting the last port in Norway")
+	rec = httptest.NewRecorder()
+	srv.HandleGetPorts(rec, httptest.NewRequest("GET", "/ports?country=Norway&limit=1&cursor=Tk9CR08", nil))
+
+	wantBody = `{"ports":[{"id":"NOOSL","name":"Oslo","code":"40301","city":"","province":"","country":"Norway"}]}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleGetPorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleListPortsInvalidLimit(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{Ports: inmem.Open()}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleListPorts(rec, httptest.NewRequest("GET", "/ports?limit=all", nil))
+
+	if got, want := rec.Result().StatusCode, 400; got != want {
+		t.Errorf("HandleListPorts(): have response code %d, want %d", got, want)
+	}
+}
+
+func TestHandleNearestPorts(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Code: "40301", Country: "Norway", Coords: []float64{10.75, 59.91}},
+		{ID: "NOBGO", Name: "Bergen", Code: "40302", Country: "Norway", Coords: []float64{5.32, 60.39}},
+		{ID: "AEDXB", Name: "Dubai", Code: "52005", Country: "United Arab Emirates", Coords: []float64{55.27, 25.2}},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	srv := http.NewServer(":http", &ports.Service{Ports: db}, http.WithWriteTimeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	srv.HandleNearestPorts(rec, httptest.NewRequest("GET", "/ports/nearest?lat=60.39&lon=5.32&radius_km=1000", nil))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleNearestPorts(): have response code %d, want %d", got, want)
+	}
+	wantBody := `{"ports":[` +
+		`{"id":"NOBGO","name":"Bergen","code":"40302","city":"","province":"","country":"Norway","coords":[5.32,60.39],"distance_km":0},` +
+		`{"id":"NOOSL","name":"Oslo","code":"40301","city":"","province":"","country":"Norway","coords":[10.75,59.91],"distance_km":305.475}]}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleNearestPorts(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleNearestPortsInvalid(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{Ports: inmem.Open()}, http.WithWriteTimeout(time.Second))
+
+	for _, target := range []string{
+		"/ports/nearest?lon=5.32",
+		"/ports/nearest?lat=north&lon=5.32",
+		"/ports/nearest?lat=91&lon=5.32",
+		"/ports/nearest?lat=60.39&lon=5.32&radius_km=far",
+		"/ports/nearest?lat=60.39&lon=5.32&radius_km=-1",
+		"/ports/nearest?lat=60.39&lon=5.32&limit=all",
+	} {
+		rec := httptest.NewRecorder()
+		srv.HandleNearestPorts(rec, httptest.NewRequest("GET", target, nil))
+
+		if got, want := rec.Result().StatusCode, 400; got != want {
+			t.Errorf("HandleNearestPorts(%s): have response code %d, want %d", target, got, want)
+		}
+	}
+}
+
+func TestHandleListPortsWithin(t *testing.T) {
+	db := inmem.Open()
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Code: "40301", Country: "Norway", Coords: []float64{10.75, 59.91}},
+		{ID: "NODRM", Name: "Drammen", Code: "40303", Country: "Norway", Coords: []float64{10.23, 59.73}},
+		{ID: "FJSUV", Name: "Suva", Code: "68201", Country: "Fiji", Coords: []float64{178.44, -18.14}},
+		{ID: "WSAPW", Name: "Apia", Code: "62001", Country: "Samoa", Coords: []float64{-171.76, -13.83}},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	srv := http.NewServer(":http", &ports.Service{Ports: db}, http.WithWriteTimeout(time.Second))
+
+	t.Log("Listing ports within a box across the antimeridian, one at a time")
+	rec := httptest.NewRecorder()
+	srv.HandleListPortsWithinBBox(rec, httptest.NewRequest("GET", "/ports/within?bbox=170,-25,-165,-10&limit=1", nil))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleListPortsWithinBBox(): have response code %d, want %d", got, want)
+	}
+	wantBody := `{"ports":[{"id":"FJSUV","name":"Suva","code":"68201","city":"","province":"","country":"Fiji","coords":[178.44,-18.14]}],"next_cursor":"RkpTVVY"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleListPortsWithinBBox(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+
+	t.Log("Listing ports within a polygon around Oslo, expecting Drammen only")
+	body := `{"type":"Polygon","coordinates":[[[9,58],[12,58],[12,61],[9,61],[9,58]],[[10.5,59.8],[11,59.8],[11,60],[10.5,60],[10.5,59.8]]]}`
+	rec = httptest.NewRecorder()
+	srv.HandleListPortsWithinPolygon(rec, httptest.NewRequest("POST", "/ports/within", strings.NewReader(body)))
+
+	if got, want := rec.Result().StatusCode, 200; got != want {
+		t.Fatalf("HandleListPortsWithinPolygon(): have response code %d, want %d", got, want)
+	}
+	wantBody = `{"ports":[{"id":"NODRM","name":"Drammen","code":"40303","city":"","province":"","country":"Norway","coords":[10.23,59.73]}]}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleListPortsWithinPolygon(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+}
+
+func TestHandleListPortsWithinInvalid(t *testing.T) {
+	srv := http.NewServer(":http", &ports.Service{Ports: inmem.Open()}, http.WithWriteTimeout(time.Second))
+
+	for _, target := range []string{
+		"/ports/within",
+		"/ports/within?bbox=4,57,32",
+		"/ports/within?bbox=4,57,32,north",
+		"/ports/within?bbox=4,72,32,57",
+		"/ports/within?bbox=4,57,32,72&limit=all",
+	} {
+		rec := httptest.NewRecorder()
+		srv.HandleListPortsWithinBBox(rec, httptest.NewRequest("GET", target, nil))
+
+		if got, want := rec.Result().StatusCode, 400; got != want {
+			t.Errorf("HandleListPortsWithinBBox(%s): have response code %d, want %d", target, got, want)
+		}
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{"type":"Point","coordinates":[10.75,59.91]}`,
+		`{"type":"Polygon","coordinates":[[10.75,59.91]]}`,
+		`{"type":"Polygon","coordinates":[[[9],[12,58],[12,61],[9,61],[9,58]]]}`,
+		`{"type":"MultiPolygon","coordinates":[[[[9,58],[12,58],[9,58]]]]}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.HandleListPortsWithinPolygon(rec, httptest.NewRequest("POST", "/ports/within", strings.NewReader(body)))
+
+		if got, want := rec.Result().StatusCode, 400; got != want {
+			t.Errorf("HandleListPortsWithinPolygon(%s): have response code %d, want %d", body, got, want)
+		}
+	}
+
+	t.Log("Requesting ports within a polygon with too many vertices, expecting 400")
+	body := `{"type":"Polygon","coordinates":[[[9,58],` + strings.Repeat(`[12,58],`, 10000) + `[9,58]]]}`
+	rec := httptest.NewRecorder()
+	srv.HandleListPortsWithinPolygon(rec, httptest.NewRequest("POST", "/ports/within", strings.NewReader(body)))
+
+	if got, want := rec.Result().StatusCode, 400; got != want {
+		t.Errorf("HandleListPortsWithinPolygon(): have response code %d, want %d", got, want)
+	}
+	wantBody := `{"code":"invalid","message":"too many vertices, 10000 at most"}`
+	if gotBody := readAll(t, rec.Result().Body); gotBody != wantBody {
+		t.Errorf("HandleListPortsWithinPolygon(): unexpected response body\nhave: %s\nwant: %s", gotBody, wantBody)
+	}
+
+	t.Log("Requesting ports within a polygon larger than accepted, expecting 413")
+	body = `{"type":"Polygon","coordinates":` + strings.Repeat(" ", 1<<20) + `[[[9,58],[12,58],[12,61],[9,58]]]}`
+	rec = httptest.NewRecorder()
+	srv.HandleListPortsWithinPolygon(rec, httptest.NewRequest("POST", "/ports/within", strings.NewReader(body)))
+
+	if got, want := rec.Result().StatusCode, 413; got != want {
+		t.Errorf("HandleListPortsWithinPolygon(): have response code %d, want %d", got, want)
+	}
+}
+
 func readAll(t *testing.T, src io.ReadCloser) string {
 	t.Helper()
 	defer func() {
@@ -0,0 +1,208 @@
+package inmem
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/christgf/ports"
+)
+
+// cellSize is the size of the cells of the spatial index, in degrees of
+// latitude and longitude, see grid.
+const cellSize = 1
+
+// Number of cells of the spatial index along each axis.
+const (
+	gridRows = 180 / cellSize
+	gridCols = 360 / cellSize
+)
+
+// cell identifies a cell of the spatial index by row, from the South Pole, and
+// column, from the antimeridian eastwards.
+type cell struct {
+	row, col int
+}
+
+// rowOf returns the row of the spatial index holding a latitude.
+func rowOf(lat float64) int {
+	return max(0, min(int((lat+90)/cellSize), gridRows-1))
+}
+
+// colOf returns the column of the spatial index holding a longitude.
+func colOf(lon float64) int {
+	return max(0, min(int((lon+180)/cellSize), gridCols-1))
+}
+
+// grid is a spatial index of port identifiers by location, bucketing ports
+// into cells of cellSize degrees, so that searching around a position visits
+// the ports in nearby cells only, rather than every port. Ports without a
+// ports.Port.Location are left out.
+type grid map[cell]map[string]struct{}
+
+// add indexes the location of the port.
+func (g grid) add(p ports.Port) {
+	pt, ok := p.Location()
+	if !ok {
+		return
+	}
+
+	c := cell{row: rowOf(pt.Lat), col: colOf(pt.Lon)}
+	if g[c] == nil {
+		g[c] = make(map[string]struct{})
+	}
+	g[c][p.ID] = struct{}{}
+}
+
+// remove drops the location of the port from the index.
+func (g grid) remove(p ports.Port) {
+	pt, ok := p.Location()
+	if !ok {
+		return
+	}
+
+	c := cell{row: rowOf(pt.Lat), col: colOf(pt.Lon)}
+	delete(g[c], p.ID)
+	if len(g[c]) == 0 {
+		delete(g, c)
+	}
+}
+
+// search calls fn with the identifiers of the ports in the cells overlapping
+// the area between the latitudes and longitudes provided. The area wraps around
+// the antimeridian if minLon is greater than maxLon.
+func (g grid) search(minLat, maxLat, minLon, maxLon float64, fn func(portID string)) {
+	first, last := colOf(minLon), colOf(maxLon)
+	if minLon > maxLon {
+		last += gridCols // Wrap around, past the last column.
+	}
+
+	for row := rowOf(minLat); row <= rowOf(maxLat); row++ {
+		for col := first; col <= last; col++ {
+			for id := range g[cell{row: row, col: col % gridCols}] {
+				fn(id)
+			}
+		}
+	}
+}
+
+// halfCircumference is the furthest two positions can be from each other, in
+// kilometers.
+const halfCircumference = math.Pi * ports.EarthRadiusKm
+
+// capBounds returns the latitudes and longitudes bounding the area within
+// radius kilometers of a position, in degrees. The longitudes wrap around the
+// antimeridian if minLon is greater than maxLon, and span every longitude if
+// the area holds a pole.
+func capBounds(at ports.Point, radius float64) (minLat, maxLat, minLon, maxLon float64) {
+	const deg = 180 / math.Pi
+	angle := radius / ports.EarthRadiusKm // Radians.
+
+	minLat, maxLat = at.Lat-angle*deg, at.Lat+angle*deg
+	if minLat <= -90 || maxLat >= 90 {
+		return max(minLat, -90), min(maxLat, 90), -180, 180
+	}
+
+	// Meridians converge towards the poles, so the area spans more longitudes
+	// than latitudes, the further it is from the equator.
+	dLon := math.Asin(min(math.Sin(angle)/math.Cos(at.Lat/deg), 1)) * deg
+	minLon, maxLon = at.Lon-dLon, at.Lon+dLon
+	if minLon <= -180 {
+		minLon += 360 // The antimeridian is both -180 and 180.
+	}
+	if maxLon >= 180 {
+		maxLon -= 360
+	}
+
+	return minLat, maxLat, minLon, maxLon
+}
+
+// nearestRadius is the radius of the first area searched by NearestPorts, in
+// kilometers.
+const nearestRadius = 100
+
+// NearestPorts can find the ports.Port records in memory nearest to a
+// position, using a spatial index. It searches increasingly larger areas
+// around the position, until one holds enough records or reaches maxDistance.
+func (db *DB) NearestPorts(ctx context.Context, at ports.Point, maxDistance float64, limit int) ([]ports.NearbyPort, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	bound := halfCircumference
+	if maxDistance > 0 {
+		bound = min(maxDistance, bound)
+	}
+
+	for radius := min(nearestRadius, bound); ; radius = min(radius*4, bound) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var nearest []ports.NearbyPort
+		minLat, maxLat, minLon, maxLon := capBounds(at, radius)
+		db.index.search(minLat, maxLat, minLon, maxLon, func(portID string) {
+			p := db.data[portID]
+			if p.Retired {
+				return
+			}
+
+			pt, _ := p.Location()
+			if d := ports.Distance(at, pt); d <= radius || radius == halfCircumference {
+				nearest = append(nearest, ports.NearbyPort{Port: p, DistanceKm: d})
+			}
+		})
+
+		// Records outside the area are further than any record in it.
+		if len(nearest) >= limit || radius == bound {
+			slices.SortFunc(nearest, func(a, b ports.NearbyPort) int {
+				return cmp.Or(cmp.Compare(a.DistanceKm, b.DistanceKm), cmp.Compare(a.ID, b.ID))
+			})
+
+			return nearest[:min(limit, len(nearest))], nil
+		}
+	}
+}
+
+// ListPortsWithin can list the ports.Port records in memory located within an
+// area, a page at a time, using a spatial index. Records are sorted as they
+// are listed, since they are not held in order.
+func (db *DB) ListPortsWithin(_ context.Context, a ports.Area, after string, limit int) ([]ports.Port, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	var bounds []ports.BBox
+	if a.BBox != nil {
+		bounds = append(bounds, *a.BBox)
+	}
+	for _, p := range a.Polygons {
+		bounds = append(bounds, p.Bounds())
+	}
+
+	var ps []ports.Port
+	seen := make(map[string]bool) // Polygons may overlap.
+	for _, b := range bounds {
+		db.index.search(b.MinLat, b.MaxLat, b.MinLon, b.MaxLon, func(portID string) {
+			if seen[portID] {
+				return
+			}
+			seen[portID] = true
+
+			p := db.data[portID]
+			if portID <= after || p.Retired {
+				return
+			}
+			if pt, _ := p.Location(); a.Contains(pt) {
+				ps = append(ps, p)
+			}
+		})
+	}
+	slices.SortFunc(ps, func(a, b ports.Port) int { return strings.Compare(a.ID, b.ID) })
+
+	if len(ps) > limit {
+		ps = ps[:limit]
+	}
+
+	return ps, nil
+}
@@ -0,0 +1,222 @@
+package inmem_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestDBNearestPorts(t *testing.T) {
+	db := inmem.Open()
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	var all []ports.Port
+	for i := range 2000 {
+		p := ports.Port{ID: fmt.Sprintf("P%04d", i), Coords: []float64{rnd.Float64()*360 - 180, rnd.Float64()*180 - 90}}
+		if i%10 == 0 {
+			p.Retired = true
+		}
+		all = append(all, p)
+	}
+	all = append(all,
+		ports.Port{ID: "FJSUV", Name: "Suva", Coords: []float64{178.44, -18.14}},
+		ports.Port{ID: "WSAPW", Name: "Apia", Coords: []float64{-171.76, -13.83}},
+		ports.Port{ID: "XXNPL", Name: "North Pole", Coords: []float64{0, 90}},
+		ports.Port{ID: "XXAM1", Name: "Antimeridian", Coords: []float64{180, 0}},
+		ports.Port{ID: "XXAM2", Name: "Antimeridian", Coords: []float64{-180, 0.5}},
+		ports.Port{ID: "XXXXX", Name: "Nowhere", Coords: []float64{0, 100}},
+	)
+	if _, err := db.InsertPorts(context.TODO(), all); err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+
+	// nearest finds the nearest ports the slow way, by distance to every one.
+	nearest := func(at ports.Point, maxDistance float64, limit int) []string {
+		var found []ports.NearbyPort
+		for _, p := range all {
+			pt, ok := p.Location()
+			if !ok || p.Retired {
+				continue
+			}
+			if d := ports.Distance(at, pt); maxDistance == 0 || d <= maxDistance {
+				found = append(found, ports.NearbyPort{Port: p, DistanceKm: d})
+			}
+		}
+		slices.SortFunc(found, func(a, b ports.NearbyPort) int {
+			return cmp.Or(cmp.Compare(a.DistanceKm, b.DistanceKm), cmp.Compare(a.ID, b.ID))
+		})
+
+		var ids []string
+		for _, p := range found[:min(limit, len(found))] {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name        string
+		at          ports.Point
+		maxDistance float64
+		limit       int
+	}{
+		{name: "Oslo", at: ports.Point{Lat: 59.91, Lon: 10.75}, limit: 10},
+		{name: "Oslo, within 500 km", at: ports.Point{Lat: 59.91, Lon: 10.75}, maxDistance: 500, limit: 10},
+		{name: "Suva, across the antimeridian", at: ports.Point{Lat: -18.14, Lon: 178.44}, maxDistance: 2000, limit: 100},
+		{name: "Apia, across the antimeridian", at: ports.Point{Lat: -13.83, Lon: -171.76}, limit: 25},
+		{name: "on the antimeridian", at: ports.Point{Lat: 0, Lon: -180}, maxDistance: 100, limit: 10},
+		{name: "near the North Pole", at: ports.Point{Lat: 89.5, Lon: -120}, limit: 20},
+		{name: "South Pole", at: ports.Point{Lat: -90, Lon: 0}, maxDistance: 3000, limit: 100},
+		{name: "furthest", at: ports.Point{Lat: 12, Lon: 34}, limit: 100},
+	}
+	for _, tt := range tests {
+		found, err := db.NearestPorts(context.TODO(), tt.at, tt.maxDistance, tt.limit)
+		if err != nil {
+			t.Fatalf("NearestPorts(): %s: %v", tt.name, err)
+		}
+
+		var got []string
+		for _, p := range found {
+			got = append(got, p.ID)
+		}
+		if want := nearest(tt.at, tt.maxDistance, tt.limit); !reflect.DeepEqual(got, want) {
+			t.Errorf("NearestPorts(): %s: have %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestDBNearestPortsIndex(t *testing.T) {
+	db := inmem.Open()
+	oslo := ports.Point{Lat: 59.91, Lon: 10.75}
+
+	nearest := func() []string {
+		t.Helper()
+		found, err := db.NearestPorts(context.TODO(), oslo, 100, 10)
+		if err != nil {
+			t.Fatalf("NearestPorts(): %v", err)
+		}
+		var ids []string
+		for _, p := range found {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	if err := db.InsertPort(context.TODO(), ports.Port{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	if got, want := nearest(), []string{"NOOSL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NearestPorts(): have %v, want %v", got, want)
+	}
+
+	t.Log("Moving the port to Bergen, expecting it out of reach")
+	if err := db.InsertPort(context.TODO(), ports.Port{ID: "NOOSL", Name: "Oslo", Coords: []float64{5.32, 60.39}}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	if got := nearest(); got != nil {
+		t.Errorf("NearestPorts(): have %v, want nothing", got)
+	}
+
+	t.Log("Staging the port back in Oslo, expecting it found once committed")
+	staged, err := db.Stage(context.TODO())
+	if err != nil {
+		t.Fatalf("Stage(): %v", err)
+	}
+	if err := staged.InsertPort(context.TODO(), ports.Port{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	if _, err := staged.Commit(context.TODO()); err != nil {
+		t.Fatalf("Commit(): %v", err)
+	}
+	if got, want := nearest(), []string{"NOOSL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NearestPorts(): have %v, want %v", got, want)
+	}
+
+	t.Log("Deleting the port, expecting nothing found")
+	if _, err := db.DeletePorts(context.TODO(), []string{"NOOSL"}); err != nil {
+		t.Fatalf("DeletePorts(): %v", err)
+	}
+	if got := nearest(); got != nil {
+		t.Errorf("NearestPorts(): have %v, want nothing", got)
+	}
+}
+
+func TestDBListPortsWithin(t *testing.T) {
+	db := inmem.Open()
+
+	rnd := rand.New(rand.NewPCG(3, 4))
+	var all []ports.Port
+	for i := range 2000 {
+		p := ports.Port{ID: fmt.Sprintf("P%04d", i), Coords: []float64{rnd.Float64()*360 - 180, rnd.Float64()*180 - 90}}
+		if i%10 == 0 {
+			p.Retired = true
+		}
+		all = append(all, p)
+	}
+	if _, err := db.InsertPorts(context.TODO(), all); err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+
+	// within finds the ports within the area the slow way, by checking every one.
+	within := func(a ports.Area, after string, limit int) []string {
+		var ids []string
+		for _, p := range all {
+			if pt, ok := p.Location(); ok && !p.Retired && p.ID > after && a.Contains(pt) {
+				ids = append(ids, p.ID)
+			}
+		}
+		slices.Sort(ids)
+		return ids[:min(limit, len(ids))]
+	}
+
+	ring := func(lonLat ...float64) []ports.Point {
+		var r []ports.Point
+		for i := 0; i+1 < len(lonLat); i += 2 {
+			r = append(r, ports.Point{Lat: lonLat[i+1], Lon: lonLat[i]})
+		}
+		return append(r, r[0])
+	}
+
+	tests := []struct {
+		name  string
+		area  ports.Area
+		after string
+	}{
+		{name: "box", area: ports.Area{BBox: &ports.BBox{MinLon: -30, MinLat: -10, MaxLon: 40, MaxLat: 35}}},
+		{name: "box, after P1000", area: ports.Area{BBox: &ports.BBox{MinLon: -30, MinLat: -10, MaxLon: 40, MaxLat: 35}}, after: "P1000"},
+		{name: "box across the antimeridian", area: ports.Area{BBox: &ports.BBox{MinLon: 150, MinLat: -40, MaxLon: -150, MaxLat: 40}}},
+		{name: "whole world", area: ports.Area{BBox: &ports.BBox{MinLon: -180, MinLat: -90, MaxLon: 180, MaxLat: 90}}},
+		{name: "polygon with a hole", area: ports.Area{Polygons: []ports.Polygon{
+			{ring(-60, -50, 60, -50, 0, 60), ring(-10, -10, 10, -10, 0, 10)},
+		}}},
+		{name: "polygons across the antimeridian", area: ports.Area{Polygons: []ports.Polygon{
+			{ring(160, -30, -160, -45, -140, 20, 170, 50)},
+			{ring(170, 0, -175, 0, -175, 10, 170, 10)}, // Overlapping the first.
+			{ring(-20, 60, 20, 60, 20, 80, -20, 80)},
+		}}},
+	}
+	for _, tt := range tests {
+		ps, err := db.ListPortsWithin(context.TODO(), tt.area, tt.after, 100)
+		if err != nil {
+			t.Fatalf("ListPortsWithin(): %s: %v", tt.name, err)
+		}
+
+		var got []string
+		for _, p := range ps {
+			got = append(got, p.ID)
+		}
+		want := within(tt.area, tt.after, 100)
+		if len(want) == 0 {
+			t.Fatalf("ListPortsWithin(): %s: expecting ports within the area to test with", tt.name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ListPortsWithin(): %s: have %v, want %v", tt.name, got, want)
+		}
+	}
+}
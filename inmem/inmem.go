@@ -7,15 +7,23 @@ import (
 	"github.com/christgf/ports"
 )
 
-// DB is an in-memory implementation of ports.InsertFinder.
+// DB is an in-memory implementation of ports.InsertFinder, ports.BatchInserter,
+// ports.Retirer, ports.Stager, ports.Scanner, ports.Lister, ports.Locator and
+// ports.AreaLister.
 type DB struct {
 	sync.RWMutex
-	data map[string]ports.Port
+	data  map[string]ports.Port
+	index grid // Locations of the records in data.
+
+	// Records replaced by the last Staging.Commit, kept for Rollback.
+	previous      map[string]ports.Port
+	previousIndex grid
 }
 
 // Open instantiates and returns a new DB.
 func Open() *DB {
 	return &DB{
-		data: make(map[string]ports.Port, 0),
+		data:  make(map[string]ports.Port, 0),
+		index: make(grid),
 	}
 }
@@ -2,6 +2,8 @@ package inmem
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	"github.com/christgf/ports"
 )
@@ -11,11 +13,50 @@ func (db *DB) InsertPort(_ context.Context, p ports.Port) error {
 	db.Lock()
 	defer db.Unlock()
 
-	db.data[p.ID] = p
+	db.store(p)
 
 	return nil
 }
 
+// store stores the record, replacing any record with the same identifier, and
+// indexes its location. The caller should hold the lock.
+func (db *DB) store(p ports.Port) {
+	if existing, ok := db.data[p.ID]; ok {
+		db.index.remove(existing)
+	}
+	db.data[p.ID] = p
+	db.index.add(p)
+}
+
+// InsertPorts can store multiple ports.Port records in memory at once, under a
+// single lock. Records are stored in order, so the last occurrence of a port ID
+// wins. Records holding the same information as the ones in memory are left
+// alone, see ports.Hash. It never fails.
+func (db *DB) InsertPorts(_ context.Context, ps []ports.Port) ([]ports.Result, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	results := make([]ports.Result, len(ps))
+	for i, p := range ps {
+		results[i].ID = p.ID
+
+		existing, ok := db.data[p.ID]
+		switch {
+		case !ok:
+			results[i].Change = ports.ChangeCreated
+		case ports.Hash(existing) == ports.Hash(p):
+			results[i].Change = ports.ChangeUnchanged
+			continue
+		default:
+			results[i].Change = ports.ChangeUpdated
+		}
+
+		db.store(p)
+	}
+
+	return results, nil
+}
+
 // FindPort can retrieve ports.Port records from memory.
 func (db *DB) FindPort(_ context.Context, portID string) (*ports.Port, error) {
 	db.RLock()
@@ -28,3 +69,99 @@ func (db *DB) FindPort(_ context.Context, portID string) (*ports.Port, error) {
 
 	return &p, nil
 }
+
+// ScanPorts can call fn with each ports.Port record in memory. The records are
+// read locked until the function returns, so fn should not modify storage.
+func (db *DB) ScanPorts(ctx context.Context, fn func(p ports.Port) error) error {
+	db.RLock()
+	defer db.RUnlock()
+
+	for _, p := range db.data {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListPorts can list the ports.Port records in memory matching the filter, a
+// page at a time. Records are sorted as they are listed, since they are not
+// held in order.
+func (db *DB) ListPorts(_ context.Context, f ports.Filter, after string, limit int) ([]ports.Port, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	var ps []ports.Port
+	for id, p := range db.data {
+		if id > after && !p.Retired && f.Match(p) {
+			ps = append(ps, p)
+		}
+	}
+	slices.SortFunc(ps, func(a, b ports.Port) int { return strings.Compare(a.ID, b.ID) })
+
+	if len(ps) > limit {
+		ps = ps[:limit]
+	}
+
+	return ps, nil
+}
+
+// EachPortID can call fn with the identifier of each ports.Port record in
+// memory. The records are read locked until the function returns, so fn should
+// not modify them.
+func (db *DB) EachPortID(ctx context.Context, fn func(portID string, retired bool) error) error {
+	db.RLock()
+	defer db.RUnlock()
+
+	for id, p := range db.data {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(id, p.Retired); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RetirePorts can retire ports.Port records in memory.
+func (db *DB) RetirePorts(_ context.Context, portIDs []string) (int, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	var n int
+	for _, id := range portIDs {
+		p, ok := db.data[id]
+		if !ok || p.Retired {
+			continue
+		}
+
+		p.Retired = true
+		db.data[id] = p
+		n++
+	}
+
+	return n, nil
+}
+
+// DeletePorts can delete ports.Port records from memory.
+func (db *DB) DeletePorts(_ context.Context, portIDs []string) (int, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	var n int
+	for _, id := range portIDs {
+		if p, ok := db.data[id]; ok {
+			db.index.remove(p)
+			delete(db.data, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -46,3 +46,169 @@ func TestDBInsertFindPort(t *testing.T) {
 		t.Fatalf("FindPort(): port mismatch\nhave: %+v\nwant: %+v\n", got, want)
 	}
 }
+
+func TestDBInsertPorts(t *testing.T) {
+	db := inmem.Open()
+
+	batch := []ports.Port{
+		{ID: "MXACA", Name: "ACAPULCO"},
+		{ID: "MXCOA", Name: "Coatzacoalcos"},
+		{ID: "MXACA", Name: "Acapulco"},
+	}
+
+	results, err := db.InsertPorts(context.TODO(), batch)
+	if err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+	if got, want := len(results), len(batch); got != want {
+		t.Fatalf("InsertPorts(): have %d results, want %d", got, want)
+	}
+
+	t.Log("FindPort for a port ID repeated in the batch, expecting the last occurrence")
+	p, err := db.FindPort(context.TODO(), "MXACA")
+	if err != nil {
+		t.Fatalf("FindPort(): %v", err)
+	}
+
+	if got, want := p, &batch[2]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindPort(): port mismatch\nhave: %+v\nwant: %+v\n", got, want)
+	}
+
+	t.Log("InsertPorts for ports already in memory, expecting unchanged ports to be reported")
+	results, err = db.InsertPorts(context.TODO(), []ports.Port{
+		{ID: "MXACA", Name: "Acapulco"},
+		{ID: "MXCOA", Name: "COATZACOALCOS"},
+		{ID: "MXZLO", Name: "Manzanillo"},
+	})
+	if err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+
+	var changes []ports.Change
+	for _, r := range results {
+		changes = append(changes, r.Change)
+	}
+	if got, want := changes, []ports.Change{ports.ChangeUnchanged, ports.ChangeUpdated, ports.ChangeCreated}; !reflect.DeepEqual(got, want) {
+		t.Errorf("InsertPorts(): have changes %v, want %v", got, want)
+	}
+}
+
+func TestDBRetirePorts(t *testing.T) {
+	db := inmem.Open()
+
+	acapulco := ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"}
+	for _, p := range []ports.Port{acapulco, {ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"}, {ID: "MXZLO", Name: "Manzanillo", Code: "20103"}} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	t.Log("Retiring two ports, one of them missing, expecting one port retired")
+	if n, err := db.RetirePorts(context.TODO(), []string{"MXACA", "MXVER"}); err != nil || n != 1 {
+		t.Fatalf("RetirePorts(): have %d, %v, want 1 port retired", n, err)
+	}
+	if n, err := db.RetirePorts(context.TODO(), []string{"MXACA"}); err != nil || n != 0 {
+		t.Fatalf("RetirePorts(): have %d, %v, want no ports retired again", n, err)
+	}
+
+	t.Log("Deleting a port, expecting one port deleted")
+	if n, err := db.DeletePorts(context.TODO(), []string{"MXZLO", "MXVER"}); err != nil || n != 1 {
+		t.Fatalf("DeletePorts(): have %d, %v, want 1 port deleted", n, err)
+	}
+
+	got := make(map[string]bool)
+	if err := db.EachPortID(context.TODO(), func(portID string, retired bool) error {
+		got[portID] = retired
+		return nil
+	}); err != nil {
+		t.Fatalf("EachPortID(): %v", err)
+	}
+	if want := map[string]bool{"MXACA": true, "MXCOA": false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EachPortID(): have %v, want %v", got, want)
+	}
+
+	t.Log("Inserting a retired port again, expecting it to be current again")
+	if err := db.InsertPort(context.TODO(), acapulco); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	p, err := db.FindPort(context.TODO(), acapulco.ID)
+	if err != nil {
+		t.Fatalf("FindPort(): %v", err)
+	}
+	if p.Retired {
+		t.Errorf("FindPort(): have retired port %q, want current", p.ID)
+	}
+}
+
+func TestDBScanPorts(t *testing.T) {
+	db := inmem.Open()
+
+	want := map[string]ports.Port{
+		"MXACA": {ID: "MXACA", Name: "Acapulco", Code: "20101", UNLocs: []string{"MXACA"}, Coords: []float64{-99.87, 16.85}},
+		"MXCOA": {ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102", Retired: true},
+	}
+	for _, p := range want {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	got := make(map[string]ports.Port)
+	if err := db.ScanPorts(context.TODO(), func(p ports.Port) error {
+		got[p.ID] = p
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanPorts(): %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanPorts(): ports mismatch\nhave: %+v\nwant: %+v\n", got, want)
+	}
+
+	t.Log("Scanning with a function failing, expecting the scan to stop with its error")
+	errStop := errors.New("stop")
+	if err := db.ScanPorts(context.TODO(), func(p ports.Port) error { return errStop }); !errors.Is(err, errStop) {
+		t.Errorf("ScanPorts(): have %v, want %v", err, errStop)
+	}
+}
+
+func TestDBListPorts(t *testing.T) {
+	db := inmem.Open()
+
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Country: "Norway", Regions: []string{"Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "NOBGO", Name: "Bergen", Country: "Norway", Regions: []string{"Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "NOHFT", Name: "Hammerfest", Country: "Norway", Timezone: "Europe/Oslo", Retired: true},
+		{ID: "NOTOS", Name: "Tromso", Country: "Norway", Regions: []string{"Arctic", "Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "AEDXB", Name: "Dubai", Country: "United Arab Emirates", Timezone: "Asia/Dubai"},
+	} {
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	ids := func(ps []ports.Port) []string {
+		var ids []string
+		for _, p := range ps {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Log("Listing ports in Norway, expecting current ports only, sorted by ID")
+	ps, err := db.ListPorts(context.TODO(), ports.Filter{Country: "Norway"}, "", 10)
+	if err != nil {
+		t.Fatalf("ListPorts(): %v", err)
+	}
+	if got, want := ids(ps), []string{"NOBGO", "NOOSL", "NOTOS"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPorts(): have %v, want %v", got, want)
+	}
+
+	t.Log("Listing ports in Europe after NOBGO, one at most")
+	ps, err = db.ListPorts(context.TODO(), ports.Filter{Region: "Europe", Timezone: "Europe/Oslo"}, "NOBGO", 1)
+	if err != nil {
+		t.Fatalf("ListPorts(): %v", err)
+	}
+	if got, want := ids(ps), []string{"NOOSL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPorts(): have %v, want %v", got, want)
+	}
+}
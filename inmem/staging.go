@@ -0,0 +1,86 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/christgf/ports"
+)
+
+// CountPorts can count the ports.Port records in memory.
+func (db *DB) CountPorts(_ context.Context) (int, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	return len(db.data), nil
+}
+
+// Stage can prepare an empty staging DB, whose records replace the records of
+// db once committed. It implements ports.Stager.
+func (db *DB) Stage(_ context.Context) (ports.Staged, error) {
+	return &Staging{DB: Open(), live: db}, nil
+}
+
+// Staging is an in-memory implementation of ports.Staged.
+type Staging struct {
+	*DB
+	live *DB // The DB whose records are replaced on commit.
+}
+
+// previousName is the name returned by Staging.Commit for the records kept for
+// rollback, see DB.Rollback.
+const previousName = "previous"
+
+// Commit can swap the staged records in place of the records of the live DB,
+// under a single lock, leaving the staging DB empty. The previous records of the
+// live DB are kept for DB.Rollback, replacing any kept by an earlier commit, and
+// previousName is returned, or an empty string if the live DB was empty.
+func (s *Staging) Commit(_ context.Context) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.live.Lock()
+	defer s.live.Unlock()
+
+	var previous string
+	if len(s.live.data) > 0 {
+		s.live.previous, s.live.previousIndex = s.live.data, s.live.index
+		previous = previousName
+	}
+
+	s.live.data, s.data = s.data, make(map[string]ports.Port)
+	s.live.index, s.index = s.index, make(grid)
+
+	return previous, nil
+}
+
+// ErrNoPrevious is the error returned by DB.Rollback when no records have been
+// kept for rollback.
+var ErrNoPrevious = &ports.Error{Code: ports.ErrCodeNotFound, Msg: "no previous ports kept for rollback"}
+
+// Rollback can swap the records replaced by the last Staging.Commit back in
+// place of the records in memory, under a single lock. The records replaced by
+// the rollback are kept in turn, so that rolling back again undoes it.
+func (db *DB) Rollback(_ context.Context) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.previous == nil {
+		return ErrNoPrevious
+	}
+
+	db.data, db.previous = db.previous, db.data
+	db.index, db.previousIndex = db.previousIndex, db.index
+
+	return nil
+}
+
+// Abort can discard the staged records.
+func (s *Staging) Abort(_ context.Context) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.data = make(map[string]ports.Port)
+	s.index = make(grid)
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package inmem_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+)
+
+func TestDBStage(t *testing.T) {
+	db := inmem.Open()
+	if err := db.InsertPort(context.TODO(), ports.Port{ID: "MXACA", Name: "Acapulco"}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+
+	staged, err := db.Stage(context.TODO())
+	if err != nil {
+		t.Fatalf("Stage(): %v", err)
+	}
+
+	t.Log("Inserting ports into staging, expecting them to be invisible until committed")
+	for _, p := range []ports.Port{{ID: "MXCOA", Name: "Coatzacoalcos"}, {ID: "MXZLO", Name: "Manzanillo"}} {
+		if err := staged.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	if _, err := db.FindPort(context.TODO(), "MXCOA"); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+		t.Fatalf("FindPort(): have %v, want not found error before commit", err)
+	}
+	if n, err := staged.CountPorts(context.TODO()); err != nil || n != 2 {
+		t.Fatalf("CountPorts(): have %d, %v, want 2 staged ports", n, err)
+	}
+
+	if _, err := db.FindPort(context.TODO(), "MXCOA"); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+		t.Fatalf("FindPort(): have %v, want not found error before commit", err)
+	}
+	if err := db.Rollback(context.TODO()); !errors.Is(err, inmem.ErrNoPrevious) {
+		t.Fatalf("Rollback(): have %v, want %v before commit", err, inmem.ErrNoPrevious)
+	}
+
+	previous, err := staged.Commit(context.TODO())
+	if err != nil {
+		t.Fatalf("Commit(): %v", err)
+	}
+	if previous == "" {
+		t.Errorf("Commit(): have no name for the previous ports, want one")
+	}
+
+	t.Log("Committing staging, expecting the staged ports to replace the ports in memory")
+	if n, err := db.CountPorts(context.TODO()); err != nil || n != 2 {
+		t.Fatalf("CountPorts(): have %d, %v, want 2 ports", n, err)
+	}
+	if _, err := db.FindPort(context.TODO(), "MXACA"); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+		t.Errorf("FindPort(): have %v, want not found error for a port replaced", err)
+	}
+	if _, err := db.FindPort(context.TODO(), "MXCOA"); err != nil {
+		t.Errorf("FindPort(): %v", err)
+	}
+
+	t.Log("Rolling back, expecting the previous ports back, and rolling back again to undo it")
+	if err := db.Rollback(context.TODO()); err != nil {
+		t.Fatalf("Rollback(): %v", err)
+	}
+	if _, err := db.FindPort(context.TODO(), "MXACA"); err != nil {
+		t.Errorf("FindPort(): have %v after rollback, want the previous port", err)
+	}
+	if n, err := db.CountPorts(context.TODO()); err != nil || n != 1 {
+		t.Errorf("CountPorts(): have %d, %v, want 1 port after rollback", n, err)
+	}
+	if err := db.Rollback(context.TODO()); err != nil {
+		t.Fatalf("Rollback(): %v", err)
+	}
+	if n, err := db.CountPorts(context.TODO()); err != nil || n != 2 {
+		t.Errorf("CountPorts(): have %d, %v, want 2 ports after undoing the rollback", n, err)
+	}
+}
@@ -0,0 +1,136 @@
+package ports
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+)
+
+// Filter selects Port records by the values of their fields, see Lister. Values
+// are matched exactly, and empty values match any Port.
+type Filter struct {
+	Country  string
+	Province string
+	City     string
+	Region   string // Matches ports with the region among their Regions.
+	Timezone string
+}
+
+// Match reports whether the Port record is selected by the filter.
+func (f Filter) Match(p Port) bool {
+	return (f.Country == "" || p.Country == f.Country) &&
+		(f.Province == "" || p.Province == f.Province) &&
+		(f.City == "" || p.City == f.City) &&
+		(f.Region == "" || slices.Contains(p.Regions, f.Region)) &&
+		(f.Timezone == "" || p.Timezone == f.Timezone)
+}
+
+// Lister can list the Port records in storage matching a Filter, a page at a
+// time.
+type Lister interface {
+	// ListPorts returns up to limit Port records matching the filter, leaving
+	// retired records out, sorted by identifier. Records start right after the
+	// identifier provided, or from the first record if it is empty.
+	ListPorts(ctx context.Context, f Filter, after string, limit int) ([]Port, error)
+}
+
+// Page sizes of Service.ListPorts.
+const (
+	DefaultPageSize = 100  // Records per page, unless requested otherwise.
+	MaxPageSize     = 1000 // Records per page at most, larger pages are capped.
+)
+
+// Page is a page of Port records, see Service.ListPorts.
+type Page struct {
+	Ports []Port
+	Next  string // Cursor of the next page, empty on the last page.
+}
+
+// ErrListUnsupported is the error returned when the storage system cannot list
+// Port records, see Lister.
+var ErrListUnsupported = &Error{Code: ErrCodeInternal, Msg: "storage cannot list ports"}
+
+// ErrInvalidPageSize is the error returned when the page size requested from
+// Service.ListPorts is negative.
+var ErrInvalidPageSize = &Error{Code: ErrCodeInvalid, Msg: "page size should not be negative"}
+
+// ErrInvalidCursor is the error returned when the cursor provided to
+// Service.ListPorts was not returned by it.
+var ErrInvalidCursor = &Error{Code: ErrCodeInvalid, Msg: "invalid cursor"}
+
+// ListPorts retrieves a page of the Port records in storage matching the
+// filter, leaving retired records out. Records are sorted by identifier, and
+// pages start from the cursor provided, taken from Page.Next of the previous
+// page, or from the first record if the cursor is empty. Since the cursor
+// points past the last record of the previous page, rather than counting
+// records, pages are stable: records stored while paging through do not shift
+// the records of later pages.
+//
+// Pages hold size records at most, DefaultPageSize if size is zero, and are
+// capped to MaxPageSize. It returns an error if the cursor or the size are not
+// valid, if the storage system cannot list records, see Lister, if it fails, or
+// if the context is cancelled before the operation is completed.
+func (s *Service) ListPorts(ctx context.Context, f Filter, cursor string, size int) (*Page, error) {
+	lister, ok := s.Ports.(Lister)
+	if !ok {
+		return nil, ErrListUnsupported
+	}
+
+	size, err := pageSize(size)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for one more record, to tell whether there is a next page.
+	ps, err := lister.ListPorts(ctx, f, after, size+1)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not list ports", Cause: err}
+	}
+
+	return newPage(ps, size), nil
+}
+
+// pageSize returns the page size requested, DefaultPageSize if zero, capped to
+// MaxPageSize. It returns ErrInvalidPageSize if the size is negative.
+func pageSize(size int) (int, error) {
+	switch {
+	case size < 0:
+		return 0, ErrInvalidPageSize
+	case size == 0:
+		return DefaultPageSize, nil
+	default:
+		return min(size, MaxPageSize), nil
+	}
+}
+
+// decodeCursor returns the identifier of the last record of the previous page,
+// held by a cursor, or an empty string if the cursor is empty. It returns
+// ErrInvalidCursor if the cursor was not returned by newPage.
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) == 0 {
+		return "", ErrInvalidCursor
+	}
+
+	return string(b), nil
+}
+
+// newPage returns a page of size records out of the records provided, sorted
+// by identifier, which hold one more record if there is a next page.
+func newPage(ps []Port, size int) *Page {
+	page := &Page{Ports: ps}
+	if len(ps) > size {
+		page.Ports = ps[:size]
+		page.Next = base64.RawURLEncoding.EncodeToString([]byte(ps[size-1].ID))
+	}
+
+	return page
+}
@@ -0,0 +1,127 @@
+package ports_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"github.com/christgf/ports/inmem"
+	"github.com/christgf/ports/mock"
+)
+
+func TestFilterMatch(t *testing.T) {
+	p := ports.Port{ID: "NOOSL", Country: "Norway", City: "Oslo", Regions: []string{"Europe", "Scandinavia"}, Timezone: "Europe/Oslo"}
+
+	tests := []struct {
+		filter ports.Filter
+		want   bool
+	}{
+		{filter: ports.Filter{}, want: true},
+		{filter: ports.Filter{Country: "Norway", Timezone: "Europe/Oslo"}, want: true},
+		{filter: ports.Filter{Region: "Scandinavia"}, want: true},
+		{filter: ports.Filter{Region: "Asia"}, want: false},
+		{filter: ports.Filter{Country: "Norway", City: "Bergen"}, want: false},
+		{filter: ports.Filter{Country: "norway"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(p); got != tt.want {
+			t.Errorf("Match(%+v): have %t, want %t", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestServiceListPorts(t *testing.T) {
+	db := inmem.Open()
+	var want []string
+	for i := range 25 {
+		p := ports.Port{ID: fmt.Sprintf("NO%03d", i), Country: "Norway"}
+		if i%5 == 0 {
+			p.Country = "Sweden"
+		} else {
+			want = append(want, p.ID)
+		}
+		if err := db.InsertPort(context.TODO(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	s := &ports.Service{Ports: db}
+
+	t.Log("Paging through ports in Norway, expecting every one of them once, in order")
+	var (
+		got    []string
+		cursor string
+		pages  int
+	)
+	for {
+		page, err := s.ListPorts(context.TODO(), ports.Filter{Country: "Norway"}, cursor, 7)
+		if err != nil {
+			t.Fatalf("ListPorts(): %v", err)
+		}
+		pages++
+		for _, p := range page.Ports {
+			got = append(got, p.ID)
+		}
+
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+
+		t.Log("Storing a port while paging through, expecting later pages not to shift")
+		if err := db.InsertPort(context.TODO(), ports.Port{ID: "NA000", Country: "Norway"}); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPorts(): have ports %v, want %v", got, want)
+	}
+	if got, want := pages, 3; got != want {
+		t.Errorf("ListPorts(): have %d pages, want %d", got, want)
+	}
+}
+
+func TestServiceListPortsPageSize(t *testing.T) {
+	db := inmem.Open()
+	for i := range ports.MaxPageSize + 1 {
+		if err := db.InsertPort(context.TODO(), ports.Port{ID: fmt.Sprintf("P%04d", i)}); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	s := &ports.Service{Ports: db}
+
+	for _, tt := range []struct {
+		size, want int
+	}{
+		{size: 0, want: ports.DefaultPageSize},
+		{size: 10, want: 10},
+		{size: ports.MaxPageSize * 2, want: ports.MaxPageSize},
+	} {
+		page, err := s.ListPorts(context.TODO(), ports.Filter{}, "", tt.size)
+		if err != nil {
+			t.Fatalf("ListPorts(): %v", err)
+		}
+		if got := len(page.Ports); got != tt.want {
+			t.Errorf("ListPorts(%d): have %d ports, want %d", tt.size, got, tt.want)
+		}
+	}
+
+	if _, err := s.ListPorts(context.TODO(), ports.Filter{}, "", -1); !errors.Is(err, ports.ErrInvalidPageSize) {
+		t.Errorf("ListPorts(): have %v, want %v", err, ports.ErrInvalidPageSize)
+	}
+	if _, err := s.ListPorts(context.TODO(), ports.Filter{}, "not a cursor!", 10); !errors.Is(err, ports.ErrInvalidCursor) {
+		t.Errorf("ListPorts(): have %v, want %v", err, ports.ErrInvalidCursor)
+	}
+}
+
+func TestServiceListPortsUnsupported(t *testing.T) {
+	s := &ports.Service{Ports: &mock.InsertFinder{}}
+
+	if _, err := s.ListPorts(context.TODO(), ports.Filter{}, "", 10); !errors.Is(err, ports.ErrListUnsupported) {
+		t.Errorf("ListPorts(): have %v, want %v", err, ports.ErrListUnsupported)
+	}
+}
@@ -46,3 +46,52 @@ func (m *InsertFinder) FindPort(ctx context.Context, portID string) (*ports.Port
 
 	return m.FindPortFn(ctx, portID)
 }
+
+// BatchInsertFinder is a mock implementation of ports.InsertFinder that is also
+// a ports.BatchInserter.
+type BatchInsertFinder struct {
+	InsertFinder
+	InsertPortsFn func(ctx context.Context, ps []ports.Port) ([]ports.Result, error)
+
+	InsertPortsCalls int
+}
+
+// InsertPorts invokes the mock implementation.
+func (m *BatchInsertFinder) InsertPorts(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+	m.Lock()
+	m.InsertPortsCalls++
+	m.Unlock()
+
+	if m.InsertPortsFn == nil {
+		results := make([]ports.Result, len(ps))
+		for i, p := range ps {
+			results[i].ID = p.ID
+		}
+
+		return results, nil
+	}
+
+	return m.InsertPortsFn(ctx, ps)
+}
+
+// AreaListFinder is a mock implementation of ports.InsertFinder that is also a
+// ports.AreaLister.
+type AreaListFinder struct {
+	InsertFinder
+	ListPortsWithinFn func(ctx context.Context, a ports.Area, after string, limit int) ([]ports.Port, error)
+
+	ListPortsWithinCalls int
+}
+
+// ListPortsWithin invokes the mock implementation.
+func (m *AreaListFinder) ListPortsWithin(ctx context.Context, a ports.Area, after string, limit int) ([]ports.Port, error) {
+	m.Lock()
+	m.ListPortsWithinCalls++
+	m.Unlock()
+
+	if m.ListPortsWithinFn == nil {
+		return nil, nil
+	}
+
+	return m.ListPortsWithinFn(ctx, a, after, limit)
+}
@@ -0,0 +1,203 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/christgf/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// locationFilter matches the BSON documents holding a valid pair of
+// coordinates, longitude first, without a GeoJSON location, see port.Location.
+func locationFilter() bson.D {
+	return bson.D{
+		{Key: "location", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "coords", Value: bson.D{{Key: "$size", Value: 2}}},
+		{Key: "coords.0", Value: bson.D{{Key: "$gte", Value: -180}, {Key: "$lte", Value: 180}}},
+		{Key: "coords.1", Value: bson.D{{Key: "$gte", Value: -90}, {Key: "$lte", Value: 90}}},
+	}
+}
+
+// hasLocation matches the BSON documents holding a GeoJSON location, see
+// port.Location.
+func hasLocation() bson.D {
+	return bson.D{{Key: "location", Value: bson.D{{Key: "$exists", Value: true}}}}
+}
+
+// backfillLocations will set the GeoJSON location of the BSON documents of the
+// Ports collection stored before locations were, see port.Location. Since
+// unchanged ports are never written again, see upsertFilter, those documents
+// would otherwise be left out of geospatial queries.
+func (db *DB) backfillLocations(ctx context.Context) error {
+	if _, err := db.Ports().UpdateMany(ctx, locationFilter(), mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "location", Value: bson.D{
+			{Key: "type", Value: "Point"},
+			{Key: "coordinates", Value: "$coords"},
+		}}}}},
+	}); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// NearestPorts will retrieve up to limit BSON documents from the Ports
+// collection located within maxDistance kilometers of the position provided,
+// or at any distance if maxDistance is zero, nearest first, leaving retired
+// ports out, and return the corresponding information as ports.Port records
+// along with their distance. Queries are supported by the geospatial index
+// created by CreateIndexes, and distances are computed on a sphere.
+func (db *DB) NearestPorts(ctx context.Context, at ports.Point, maxDistance float64, limit int) ([]ports.NearbyPort, error) {
+	near := bson.D{
+		{Key: "near", Value: newPoint(at)},
+		{Key: "key", Value: "location"},
+		{Key: "distanceField", Value: "distance"},
+		{Key: "spherical", Value: true},
+		{Key: "query", Value: bson.D{{Key: "retired", Value: bson.D{{Key: "$ne", Value: true}}}}},
+	}
+	if maxDistance > 0 {
+		near = append(near, bson.E{Key: "maxDistance", Value: maxDistance * 1000}) // Meters.
+	}
+
+	cur, err := db.Ports().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$geoNear", Value: near}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("aggregate: %w", err)
+	}
+	defer func() { _ = cur.Close(context.WithoutCancel(ctx)) }()
+
+	var nearest []ports.NearbyPort
+	for cur.Next(ctx) {
+		var doc struct {
+			Port     port    `bson:",inline"`
+			Distance float64 `bson:"distance"` // Meters.
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		nearest = append(nearest, ports.NearbyPort{Port: doc.Port.port(), DistanceKm: doc.Distance / 1000})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+
+	return nearest, nil
+}
+
+// withinFilter matches the BSON documents of the ports located within the area,
+// leaving retired ports out, with a port identifier after the one provided.
+func withinFilter(a ports.Area, after string) bson.D {
+	filter := bson.D{{Key: "retired", Value: bson.D{{Key: "$ne", Value: true}}}}
+	if a.BBox != nil {
+		// Bounding boxes follow parallels and meridians, rather than great
+		// circles, so they are matched against coordinates as legacy pairs,
+		// longitude first, on a flat grid, using the partial index created by
+		// CreateIndexes. Only ports with a location are matched, see
+		// port.Location, and each box says so, for the index to be used.
+		boxes := bson.A{box(a.BBox.MinLon, a.BBox.MinLat, a.BBox.MaxLon, a.BBox.MaxLat)}
+		if a.BBox.MinLon > a.BBox.MaxLon { // Split at the antimeridian.
+			boxes = bson.A{
+				box(a.BBox.MinLon, a.BBox.MinLat, 180, a.BBox.MaxLat),
+				box(-180, a.BBox.MinLat, a.BBox.MaxLon, a.BBox.MaxLat),
+			}
+		}
+
+		var or bson.A
+		for _, b := range boxes {
+			or = append(or, append(hasLocation(),
+				bson.E{Key: "coords", Value: bson.D{{Key: "$geoWithin", Value: bson.D{{Key: "$box", Value: b}}}}}))
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: or})
+	} else {
+		filter = append(filter, bson.E{Key: "location", Value: bson.D{{Key: "$geoWithin", Value: bson.D{
+			{Key: "$geometry", Value: newMultiPolygon(a.Polygons)},
+		}}}})
+	}
+	if after != "" {
+		filter = append(filter, bson.E{Key: "id", Value: bson.D{{Key: "$gt", Value: after}}})
+	}
+
+	return filter
+}
+
+// box returns a legacy box, by its bottom left and upper right corners.
+func box(minLon, minLat, maxLon, maxLat float64) bson.A {
+	return bson.A{bson.A{minLon, minLat}, bson.A{maxLon, maxLat}}
+}
+
+// multiPolygon is a GeoJSON MultiPolygon, a list of polygons, each a list of
+// linear rings, each a list of positions, longitude first.
+type multiPolygon struct {
+	Type        string          `bson:"type"`
+	Coordinates [][][][]float64 `bson:"coordinates"`
+}
+
+// newMultiPolygon returns the GeoJSON MultiPolygon of the polygons provided.
+func newMultiPolygon(polygons []ports.Polygon) multiPolygon {
+	mp := multiPolygon{Type: "MultiPolygon", Coordinates: make([][][][]float64, len(polygons))}
+	for i, p := range polygons {
+		mp.Coordinates[i] = make([][][]float64, len(p))
+		for j, ring := range p {
+			mp.Coordinates[i][j] = make([][]float64, len(ring))
+			for k, pt := range ring {
+				mp.Coordinates[i][j][k] = []float64{pt.Lon, pt.Lat}
+			}
+		}
+	}
+
+	return mp
+}
+
+// errCodeBadValue is the MongoDB error code for query arguments that are not
+// valid, such as geometries.
+const errCodeBadValue = 2
+
+// invalidArea returns an Error with code ports.ErrCodeInvalid for errors of
+// MongoDB rejecting the area of a query, since polygons valid on a flat grid,
+// see ports.Polygon.Valid, may still not be valid on a sphere. Other errors are
+// returned as they are.
+func invalidArea(err error) error {
+	var se mongo.ServerError
+	if errors.As(err, &se) && se.HasErrorCode(errCodeBadValue) {
+		return &ports.Error{Code: ports.ErrCodeInvalid, Msg: ports.ErrInvalidPolygon.Msg, Cause: err}
+	}
+
+	return err
+}
+
+// ListPortsWithin will retrieve up to limit BSON documents from the Ports
+// collection located within the area, sorted by port identifier, starting
+// after the one provided, and return the corresponding information as
+// ports.Port records. Polygons are matched using the geospatial index created
+// by CreateIndexes, taking their edges as great-circle arcs, so they should
+// not cover more than a hemisphere. Polygons MongoDB rejects are reported as
+// an Error with code ports.ErrCodeInvalid.
+func (db *DB) ListPortsWithin(ctx context.Context, a ports.Area, after string, limit int) ([]ports.Port, error) {
+	cur, err := db.Ports().Find(ctx, withinFilter(a, after), options.Find().
+		SetSort(bson.D{{Key: "id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", invalidArea(err))
+	}
+	defer func() { _ = cur.Close(context.WithoutCancel(ctx)) }()
+
+	var ps []ports.Port
+	for cur.Next(ctx) {
+		var doc port
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		ps = append(ps, doc.port())
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("cursor: %w", invalidArea(err))
+	}
+
+	return ps, nil
+}
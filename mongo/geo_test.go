@@ -0,0 +1,148 @@
+package mongo_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDBNearestPorts(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	t.Log("Storing a port without a location, as stored before locations were")
+	if _, err := db.Ports().InsertOne(context.Background(), bson.D{
+		{Key: "id", Value: "NOTOS"},
+		{Key: "name", Value: "Tromso"},
+		{Key: "coords", Value: bson.A{18.96, 69.65}},
+	}); err != nil {
+		t.Fatalf("InsertOne(): %v", err)
+	}
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}},
+		{ID: "NOBGO", Name: "Bergen", Coords: []float64{5.32, 60.39}},
+		{ID: "NOHFT", Name: "Hammerfest", Coords: []float64{23.68, 70.66}, Retired: true},
+		{ID: "NOXXX", Name: "Nowhere", Coords: []float64{59.91, 100.75}},
+		{ID: "AEDXB", Name: "Dubai", Coords: []float64{55.27, 25.2}},
+	} {
+		if err := db.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	oslo := ports.Point{Lat: 59.91, Lon: 10.75}
+	ids := func(ps []ports.NearbyPort) []string {
+		var ids []string
+		for _, p := range ps {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Log("Finding ports near Oslo, expecting located current ports only, nearest first")
+	nearest, err := db.NearestPorts(context.Background(), oslo, 0, 10)
+	if err != nil {
+		t.Fatalf("NearestPorts(): %v", err)
+	}
+	if got, want := ids(nearest), []string{"NOOSL", "NOBGO", "NOTOS", "AEDXB"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("NearestPorts(): have %v, want %v", got, want)
+	}
+	if got, want := nearest[1].DistanceKm, ports.Distance(oslo, ports.Point{Lat: 60.39, Lon: 5.32}); math.Abs(got-want) > 0.001 {
+		t.Errorf("NearestPorts(): have distance %f km to Bergen, want %f km", got, want)
+	}
+
+	t.Log("Finding ports within 1000 km of Oslo, two at most")
+	nearest, err = db.NearestPorts(context.Background(), oslo, 1000, 2)
+	if err != nil {
+		t.Fatalf("NearestPorts(): %v", err)
+	}
+	if got, want := ids(nearest), []string{"NOOSL", "NOBGO"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NearestPorts(): have %v, want %v", got, want)
+	}
+}
+
+func TestDBListPortsWithin(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Coords: []float64{10.75, 59.91}},
+		{ID: "NODRM", Name: "Drammen", Coords: []float64{10.23, 59.73}},
+		{ID: "NOBGO", Name: "Bergen", Coords: []float64{5.32, 60.39}},
+		{ID: "NOHFT", Name: "Hammerfest", Coords: []float64{23.68, 70.66}, Retired: true},
+		{ID: "NOXXX", Name: "Nowhere", Coords: []float64{59.91, 100.75}},
+		{ID: "FJSUV", Name: "Suva", Coords: []float64{178.44, -18.14}},
+		{ID: "WSAPW", Name: "Apia", Coords: []float64{-171.76, -13.83}},
+	} {
+		if err := db.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	ring := func(lonLat ...float64) []ports.Point {
+		var r []ports.Point
+		for i := 0; i+1 < len(lonLat); i += 2 {
+			r = append(r, ports.Point{Lat: lonLat[i+1], Lon: lonLat[i]})
+		}
+		return append(r, r[0])
+	}
+
+	tests := []struct {
+		name  string
+		area  ports.Area
+		after string
+		want  []string
+	}{
+		{
+			name: "box around Norway",
+			area: ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}},
+			want: []string{"NOBGO", "NODRM", "NOOSL"},
+		},
+		{
+			name:  "box around Norway, after NOBGO",
+			area:  ports.Area{BBox: &ports.BBox{MinLon: 4, MinLat: 57, MaxLon: 32, MaxLat: 72}},
+			after: "NOBGO",
+			want:  []string{"NODRM", "NOOSL"},
+		},
+		{
+			name: "box across the antimeridian",
+			area: ports.Area{BBox: &ports.BBox{MinLon: 170, MinLat: -25, MaxLon: -165, MaxLat: -10}},
+			want: []string{"FJSUV", "WSAPW"},
+		},
+		{
+			name: "polygons, one of them across the antimeridian",
+			area: ports.Area{Polygons: []ports.Polygon{
+				{ring(175, -20, -170, -20, -170, -10)},
+				{ring(9, 58, 12, 58, 12, 61, 9, 61), ring(10.5, 59.8, 11, 59.8, 11, 60, 10.5, 60)},
+			}},
+			want: []string{"FJSUV", "NODRM", "WSAPW"},
+		},
+	}
+	for _, tt := range tests {
+		ps, err := db.ListPortsWithin(context.Background(), tt.area, tt.after, 10)
+		if err != nil {
+			t.Fatalf("ListPortsWithin(): %s: %v", tt.name, err)
+		}
+
+		var got []string
+		for _, p := range ps {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListPortsWithin(): %s: have %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -39,6 +39,9 @@ const (
 	collectionPorts = "ports"
 )
 
+// portIDIndex is the name of the unique port ID index, see CreateIndexes.
+const portIDIndex = "id_1"
+
 // WithServerSelectTimeout specifies how long the driver will wait to find an
 // available, suitable server to execute an operation. The default value is
 // defaultServerSelectTimeout.
@@ -117,7 +120,6 @@ func (db *DB) Close() error {
 // the routine should only create indexes that don't already exist.
 func (db *DB) CreateIndexes(ctx context.Context) ([]string, error) {
 	// Ports ID index, each port should have a unique identifier.
-	const portIDIndex = "id_1"
 	{
 		if _, err := db.Ports().Indexes().CreateOne(ctx, mongo.IndexModel{
 			Keys: bson.D{
@@ -129,6 +131,55 @@ func (db *DB) CreateIndexes(ctx context.Context) ([]string, error) {
 		}
 	}
 
+	// Ports filter indexes, one per field ports can be listed by, see
+	// ListPorts, followed by the port ID, so that pages come out in order.
+	for _, field := range []string{"country", "province", "city", "regions", "timezone"} {
+		name := field + "_1_id_1"
+		if _, err := db.Ports().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: field, Value: 1},
+				{Key: "id", Value: 1},
+			},
+			Options: options.Index().SetName(name),
+		}); err != nil {
+			return nil, fmt.Errorf("creating index %q: %w", name, err)
+		}
+	}
+
+	// Ports location index, for geospatial queries, see NearestPorts. Documents
+	// stored before locations were introduced are backfilled first.
+	const portLocationIndex = "location_2dsphere"
+	{
+		if err := db.backfillLocations(ctx); err != nil {
+			return nil, fmt.Errorf("backfilling locations: %w", err)
+		}
+		if _, err := db.Ports().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "location", Value: "2dsphere"},
+			},
+			Options: options.Index().SetName(portLocationIndex),
+		}); err != nil {
+			return nil, fmt.Errorf("creating index %q: %w", portLocationIndex, err)
+		}
+	}
+
+	// Ports coordinates index, for bounding boxes, see ListPortsWithin. Only
+	// documents with a location are indexed, since other coordinates may not be
+	// a valid pair, and the maximum is past 180, since it is not included.
+	const portCoordsIndex = "coords_2d"
+	{
+		if _, err := db.Ports().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "coords", Value: "2d"},
+			},
+			Options: options.Index().SetName(portCoordsIndex).
+				SetMin(-180).SetMax(180.000001).
+				SetPartialFilterExpression(hasLocation()),
+		}); err != nil {
+			return nil, fmt.Errorf("creating index %q: %w", portCoordsIndex, err)
+		}
+	}
+
 	// Retrieve index specifications.
 	specs, err := db.Ports().Indexes().ListSpecifications(ctx)
 	if err != nil {
@@ -51,7 +51,7 @@ func TestCreateIndexes(t *testing.T) {
 		t.Fatalf("CreateIndexes() returned error: %v", err)
 	}
 
-	if got, want := len(indexes), 2; got != want {
+	if got, want := len(indexes), 9; got != want {
 		t.Errorf("CreateIndexes(): have %d index specifications, want %d", got, want)
 	}
 }
@@ -24,14 +24,31 @@ type port struct {
 	Timezone string    `bson:"timezone"`
 	UNLocs   []string  `bson:"UNLocs"`
 	Coords   []float64 `bson:"coords"`
+	Retired  bool      `bson:"retired,omitempty"`
+	Hash     string    `bson:"hash"` // Fingerprint of the information above, see ports.Hash.
+
+	// Location holds Coords as a GeoJSON point, for the geospatial index
+	// created by CreateIndexes. It is derived from Coords, so it is not part
+	// of the fingerprint, and it is left out for ports without a valid
+	// ports.Port.Location, which the index would reject.
+	Location *point `bson:"location,omitempty"`
 }
 
-// InsertPort will insert a new BSON document in the Ports collection, based on
-// the information provided. If a document already exists with the same port.ID,
-// then the existing BSON document is replaced with a new one, even if the two
-// are exactly the same.
-func (db *DB) InsertPort(ctx context.Context, p ports.Port) error {
-	if _, err := db.Ports().ReplaceOne(ctx, bson.D{{Key: "id", Value: p.ID}}, port{
+// point is a GeoJSON Point. Note that GeoJSON positions hold the longitude
+// first and the latitude second.
+type point struct {
+	Type        string    `bson:"type"`
+	Coordinates []float64 `bson:"coordinates"`
+}
+
+// newPoint returns the GeoJSON Point of a position.
+func newPoint(pt ports.Point) *point {
+	return &point{Type: "Point", Coordinates: []float64{pt.Lon, pt.Lat}}
+}
+
+// newPort returns the BSON document representation of a ports.Port.
+func newPort(p ports.Port) port {
+	doc := port{
 		ID:       p.ID,
 		Name:     p.Name,
 		Code:     p.Code,
@@ -43,13 +60,153 @@ func (db *DB) InsertPort(ctx context.Context, p ports.Port) error {
 		Timezone: p.Timezone,
 		UNLocs:   p.UNLocs,
 		Coords:   p.Coords,
-	}, options.Replace().SetUpsert(true)); err != nil {
+		Retired:  p.Retired,
+		Hash:     ports.Hash(p),
+	}
+	if pt, ok := p.Location(); ok {
+		doc.Location = newPoint(pt)
+	}
+
+	return doc
+}
+
+// port returns the ports.Port represented by the BSON document.
+func (doc port) port() ports.Port {
+	return ports.Port{
+		ID:       doc.ID,
+		Name:     doc.Name,
+		Code:     doc.Code,
+		City:     doc.City,
+		Province: doc.Province,
+		Country:  doc.Country,
+		Alias:    doc.Alias,
+		Regions:  doc.Regions,
+		Timezone: doc.Timezone,
+		UNLocs:   doc.UNLocs,
+		Coords:   doc.Coords,
+		Retired:  doc.Retired,
+	}
+}
+
+// upsertFilter matches the BSON document of a port, unless it holds the same
+// information as the port provided. Upserts using the filter leave documents
+// holding the same information alone: the filter does not match them, and the
+// document that would be inserted instead violates the unique port ID index,
+// see CreateIndexes, so the write fails with a duplicate key error.
+func upsertFilter(doc port) bson.D {
+	return bson.D{
+		{Key: "id", Value: doc.ID},
+		{Key: "hash", Value: bson.D{{Key: "$ne", Value: doc.Hash}}},
+	}
+}
+
+// errCodeDuplicateKey is the MongoDB error code for writes breaking a unique
+// index.
+const errCodeDuplicateKey = 11000
+
+// isUnchanged reports whether the write error is a duplicate key error on the
+// port ID, the key of the unique port ID index, which is how upserts using
+// upsertFilter fail for documents holding the same information. Duplicate key
+// errors on any other unique index are real failures. The server describes the
+// key of the index in the keyPattern field of the write error.
+func isUnchanged(we mongo.WriteError) bool {
+	if we.Code != errCodeDuplicateKey {
+		return false
+	}
+
+	keyPattern, ok := we.Raw.Lookup("keyPattern").DocumentOK()
+	if !ok {
+		return false
+	}
+	keys, err := keyPattern.Elements()
+
+	return err == nil && len(keys) == 1 && keys[0].Key() == "id"
+}
+
+// InsertPort will insert a new BSON document in the Ports collection, based on
+// the information provided. If a document already exists with the same port.ID,
+// then the existing BSON document is replaced with a new one, unless the two
+// hold the same information, in which case the existing document is left alone.
+func (db *DB) InsertPort(ctx context.Context, p ports.Port) error {
+	doc := newPort(p)
+	if _, err := db.Ports().ReplaceOne(ctx, upsertFilter(doc), doc, options.Replace().SetUpsert(true)); err != nil {
+		var wex mongo.WriteException
+		if errors.As(err, &wex) && len(wex.WriteErrors) == 1 && isUnchanged(wex.WriteErrors[0]) {
+			return nil
+		}
+
 		return fmt.Errorf("insert: %w", err)
 	}
 
 	return nil
 }
 
+// InsertPorts will insert or replace multiple BSON documents in the Ports
+// collection in a single round-trip, using an unordered bulk write of upserts.
+// Since the server may apply unordered writes in any order, only the last
+// occurrence of each port ID in ps is written, and earlier occurrences share
+// its result. Documents holding the same information as the port provided are
+// left alone, as with InsertPort. Individual write failures are reported as
+// ports.Result errors.
+func (db *DB) InsertPorts(ctx context.Context, ps []ports.Port) ([]ports.Result, error) {
+	results := make([]ports.Result, len(ps))
+	last := make(map[string]int, len(ps)) // Position of the last occurrence of each port ID.
+	for i, p := range ps {
+		results[i].ID = p.ID
+		last[p.ID] = i
+	}
+
+	models := make([]mongo.WriteModel, 0, len(last))
+	index := make([]int, 0, len(last)) // Position in ps of each write model.
+	for i, p := range ps {
+		if last[p.ID] != i {
+			continue // Superseded by a later occurrence in the same batch.
+		}
+
+		doc := newPort(p)
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(upsertFilter(doc)).
+			SetReplacement(doc).
+			SetUpsert(true))
+		index = append(index, i)
+	}
+	if len(models) == 0 {
+		return results, nil
+	}
+
+	for _, i := range index {
+		results[i].Change = ports.ChangeUpdated
+	}
+
+	res, err := db.Ports().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		var bwe mongo.BulkWriteException
+		if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+			return nil, fmt.Errorf("bulk insert: %w", err)
+		}
+
+		for _, we := range bwe.WriteErrors {
+			if isUnchanged(we.WriteError) {
+				results[index[we.Index]].Change = ports.ChangeUnchanged
+				continue
+			}
+			results[index[we.Index]].Change = ports.ChangeUnknown
+			results[index[we.Index]].Err = fmt.Errorf("bulk insert: %w", we)
+		}
+	}
+	if res != nil {
+		for i := range res.UpsertedIDs {
+			results[index[i]].Change = ports.ChangeCreated
+		}
+	}
+
+	for i, p := range ps {
+		results[i].Change, results[i].Err = results[last[p.ID]].Change, results[last[p.ID]].Err
+	}
+
+	return results, nil
+}
+
 // FindPort will attempt to retrieve a single BSON document from the Ports
 // collection, based on the identifier provided, and return the corresponding
 // information as ports.Port. It returns an error if a port document with the
@@ -69,17 +226,148 @@ func (db *DB) FindPort(ctx context.Context, portID string) (*ports.Port, error)
 		return nil, fmt.Errorf("decode: %w", err)
 	}
 
-	return &ports.Port{
-		ID:       p.ID,
-		Name:     p.Name,
-		Code:     p.Code,
-		City:     p.City,
-		Province: p.Province,
-		Country:  p.Country,
-		Alias:    p.Alias,
-		Regions:  p.Regions,
-		Timezone: p.Timezone,
-		UNLocs:   p.UNLocs,
-		Coords:   p.Coords,
-	}, nil
+	found := p.port()
+	return &found, nil
+}
+
+// ScanPorts will iterate over the BSON documents of the Ports collection, and
+// call fn with the ports.Port represented by each one of them.
+func (db *DB) ScanPorts(ctx context.Context, fn func(p ports.Port) error) error {
+	cur, err := db.Ports().Find(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+	defer func() { _ = cur.Close(context.WithoutCancel(ctx)) }()
+
+	for cur.Next(ctx) {
+		var doc port
+		if err := cur.Decode(&doc); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if err := fn(doc.port()); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("cursor: %w", err)
+	}
+
+	return nil
+}
+
+// listFilter matches the BSON documents of the ports selected by the filter,
+// leaving retired ports out, with a port identifier after the one provided.
+func listFilter(f ports.Filter, after string) bson.D {
+	filter := bson.D{{Key: "retired", Value: bson.D{{Key: "$ne", Value: true}}}}
+	for _, field := range []struct {
+		key, value string
+	}{
+		{"country", f.Country},
+		{"province", f.Province},
+		{"city", f.City},
+		{"regions", f.Region}, // Matches any element of the array.
+		{"timezone", f.Timezone},
+	} {
+		if field.value != "" {
+			filter = append(filter, bson.E{Key: field.key, Value: field.value})
+		}
+	}
+	if after != "" {
+		filter = append(filter, bson.E{Key: "id", Value: bson.D{{Key: "$gt", Value: after}}})
+	}
+
+	return filter
+}
+
+// ListPorts will retrieve up to limit BSON documents from the Ports collection
+// matching the filter, sorted by port identifier, starting after the one
+// provided, and return the corresponding information as ports.Port records.
+// Queries are supported by the indexes created by CreateIndexes.
+func (db *DB) ListPorts(ctx context.Context, f ports.Filter, after string, limit int) ([]ports.Port, error) {
+	cur, err := db.Ports().Find(ctx, listFilter(f, after), options.Find().
+		SetSort(bson.D{{Key: "id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+	defer func() { _ = cur.Close(context.WithoutCancel(ctx)) }()
+
+	var ps []ports.Port
+	for cur.Next(ctx) {
+		var doc port
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		ps = append(ps, doc.port())
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+
+	return ps, nil
+}
+
+// EachPortID will iterate over the BSON documents of the Ports collection,
+// retrieving only their port identifier and retired status, and call fn for
+// each one of them.
+func (db *DB) EachPortID(ctx context.Context, fn func(portID string, retired bool) error) error {
+	cur, err := db.Ports().Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "id", Value: 1},
+		{Key: "retired", Value: 1},
+	}))
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+	defer func() { _ = cur.Close(context.WithoutCancel(ctx)) }()
+
+	for cur.Next(ctx) {
+		var doc struct {
+			ID      string `bson:"id"`
+			Retired bool   `bson:"retired"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if err := fn(doc.ID, doc.Retired); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("cursor: %w", err)
+	}
+
+	return nil
+}
+
+// RetirePorts will mark the BSON documents of the Ports collection matching the
+// identifiers provided as retired. The fingerprint of each document retired is
+// removed, so that inserting the same port again replaces the document, making
+// it current again.
+func (db *DB) RetirePorts(ctx context.Context, portIDs []string) (int, error) {
+	res, err := db.Ports().UpdateMany(ctx,
+		bson.D{
+			{Key: "id", Value: bson.D{{Key: "$in", Value: portIDs}}},
+			{Key: "retired", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "retired", Value: true}}},
+			{Key: "$unset", Value: bson.D{{Key: "hash", Value: ""}}},
+		})
+	if err != nil {
+		return 0, fmt.Errorf("retire: %w", err)
+	}
+
+	return int(res.ModifiedCount), nil
+}
+
+// DeletePorts will delete the BSON documents of the Ports collection matching
+// the identifiers provided.
+func (db *DB) DeletePorts(ctx context.Context, portIDs []string) (int, error) {
+	res, err := db.Ports().DeleteMany(ctx, bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: portIDs}}}})
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+
+	return int(res.DeletedCount), nil
 }
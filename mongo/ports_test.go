@@ -7,6 +7,9 @@ import (
 	"testing"
 
 	"github.com/christgf/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func TestDBInsertFindPort(t *testing.T) {
@@ -87,3 +90,221 @@ func TestDBInsertPortUpdateExisting(t *testing.T) {
 		t.Fatalf("FindPort(): port mismatch\nhave: %+v\nwant: %+v\n", got, want)
 	}
 }
+
+func TestDBInsertPorts(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+
+	batch := []ports.Port{
+		{ID: "MXACA", Name: "ACAPULCO", Coords: []float64{-99.87, 16.85}},
+		{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"},
+		{ID: "MXACA", Name: "Acapulco", Code: "20101", Coords: []float64{-99.87, 16.85}},
+	}
+
+	t.Logf("Inserting a batch of %d ports, expecting no errors", len(batch))
+	results, err := db.InsertPorts(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("InsertPorts(): have result error %v for %q, want nothing", r.Err, r.ID)
+		}
+	}
+
+	t.Log("FindPort for a port ID repeated in the batch, expecting the last occurrence")
+	p, err := db.FindPort(context.Background(), "MXACA")
+	if err != nil {
+		t.Fatalf("FindPort(): %v", err)
+	}
+
+	if got, want := p, &batch[2]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindPort(): port mismatch\nhave: %+v\nwant: %+v\n", got, want)
+	}
+
+	t.Log("InsertPorts for ports already stored, expecting unchanged ports to be left alone")
+	results, err = db.InsertPorts(context.Background(), []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101", Coords: []float64{-99.87, 16.85}},
+		{ID: "MXCOA", Name: "COATZACOALCOS", Code: "20102"},
+		{ID: "MXZLO", Name: "Manzanillo", Code: "20103"},
+	})
+	if err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+
+	var changes []ports.Change
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("InsertPorts(): have result error %v for %q, want nothing", r.Err, r.ID)
+		}
+		changes = append(changes, r.Change)
+	}
+	if got, want := changes, []ports.Change{ports.ChangeUnchanged, ports.ChangeUpdated, ports.ChangeCreated}; !reflect.DeepEqual(got, want) {
+		t.Errorf("InsertPorts(): have changes %v, want %v", got, want)
+	}
+
+	if err := db.InsertPort(context.Background(), batch[2]); err != nil {
+		t.Errorf("InsertPort(): have %v for an unchanged port, want nothing", err)
+	}
+}
+
+func TestDBRetirePorts(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+
+	acapulco := ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"}
+	for _, p := range []ports.Port{acapulco, {ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"}, {ID: "MXZLO", Name: "Manzanillo", Code: "20103"}} {
+		if err := db.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	t.Log("Retiring two ports, one of them missing, expecting one port retired")
+	if n, err := db.RetirePorts(context.Background(), []string{"MXACA", "MXVER"}); err != nil || n != 1 {
+		t.Fatalf("RetirePorts(): have %d, %v, want 1 port retired", n, err)
+	}
+	if n, err := db.RetirePorts(context.Background(), []string{"MXACA"}); err != nil || n != 0 {
+		t.Fatalf("RetirePorts(): have %d, %v, want no ports retired again", n, err)
+	}
+
+	t.Log("Deleting a port, expecting one port deleted")
+	if n, err := db.DeletePorts(context.Background(), []string{"MXZLO", "MXVER"}); err != nil || n != 1 {
+		t.Fatalf("DeletePorts(): have %d, %v, want 1 port deleted", n, err)
+	}
+
+	got := make(map[string]bool)
+	if err := db.EachPortID(context.Background(), func(portID string, retired bool) error {
+		got[portID] = retired
+		return nil
+	}); err != nil {
+		t.Fatalf("EachPortID(): %v", err)
+	}
+	if want := map[string]bool{"MXACA": true, "MXCOA": false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EachPortID(): have %v, want %v", got, want)
+	}
+
+	t.Log("Inserting a retired port again, expecting it to be current again")
+	if err := db.InsertPort(context.Background(), acapulco); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+	p, err := db.FindPort(context.Background(), acapulco.ID)
+	if err != nil {
+		t.Fatalf("FindPort(): %v", err)
+	}
+	if p.Retired {
+		t.Errorf("FindPort(): have retired port %q, want current", p.ID)
+	}
+}
+
+func TestDBScanPorts(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	var want ports.Checksum
+	for _, p := range []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101", UNLocs: []string{"MXACA"}, Coords: []float64{-99.87, 16.85}},
+		{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102", Alias: []string{}},
+		{ID: "MXZLO", Name: "Manzanillo", Code: "20103", Retired: true},
+	} {
+		want.Add(p)
+		if err := db.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	t.Log("Scanning ports, expecting the same ports as inserted")
+	var got ports.Checksum
+	if err := db.ScanPorts(context.Background(), func(p ports.Port) error {
+		got.Add(p)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanPorts(): %v", err)
+	}
+	if got != want {
+		t.Errorf("ScanPorts(): have %d ports with checksum %s, want %d ports with checksum %s", got.Ports, got, want.Ports, want)
+	}
+}
+
+func TestDBListPorts(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	for _, p := range []ports.Port{
+		{ID: "NOOSL", Name: "Oslo", Country: "Norway", Regions: []string{"Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "NOBGO", Name: "Bergen", Country: "Norway", Regions: []string{"Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "NOHFT", Name: "Hammerfest", Country: "Norway", Timezone: "Europe/Oslo", Retired: true},
+		{ID: "NOTOS", Name: "Tromso", Country: "Norway", Regions: []string{"Arctic", "Europe"}, Timezone: "Europe/Oslo"},
+		{ID: "AEDXB", Name: "Dubai", Country: "United Arab Emirates", Timezone: "Asia/Dubai"},
+	} {
+		if err := db.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+
+	ids := func(ps []ports.Port) []string {
+		var ids []string
+		for _, p := range ps {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Log("Listing ports in Norway, expecting current ports only, sorted by ID")
+	ps, err := db.ListPorts(context.Background(), ports.Filter{Country: "Norway"}, "", 10)
+	if err != nil {
+		t.Fatalf("ListPorts(): %v", err)
+	}
+	if got, want := ids(ps), []string{"NOBGO", "NOOSL", "NOTOS"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPorts(): have %v, want %v", got, want)
+	}
+
+	t.Log("Listing ports in Europe after NOBGO, one at most")
+	ps, err = db.ListPorts(context.Background(), ports.Filter{Region: "Europe", Timezone: "Europe/Oslo"}, "NOBGO", 1)
+	if err != nil {
+		t.Fatalf("ListPorts(): %v", err)
+	}
+	if got, want := ids(ps), []string{"NOOSL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPorts(): have %v, want %v", got, want)
+	}
+}
+
+func TestDBInsertPortsDuplicateKey(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+	if _, err := db.Ports().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetName("code_1").SetUnique(true),
+	}); err != nil {
+		t.Fatalf("CreateOne(): %v", err)
+	}
+
+	if err := db.InsertPort(context.Background(), ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101"}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+
+	t.Log("Inserting a port violating another unique index, expecting an error rather than an unchanged port")
+	dup := ports.Port{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20101"}
+	if err := db.InsertPort(context.Background(), dup); err == nil {
+		t.Errorf("InsertPort(): have nothing, want duplicate key error")
+	}
+
+	results, err := db.InsertPorts(context.Background(), []ports.Port{dup})
+	if err != nil {
+		t.Fatalf("InsertPorts(): %v", err)
+	}
+	if results[0].Err == nil || results[0].Change == ports.ChangeUnchanged {
+		t.Errorf("InsertPorts(): have %v, %v, want duplicate key error", results[0].Change, results[0].Err)
+	}
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christgf/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CountPorts will count the BSON documents of the Ports collection.
+func (db *DB) CountPorts(ctx context.Context) (int, error) {
+	n, err := db.Ports().CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// Stage will prepare an empty staging collection next to the Ports collection,
+// named after it with a "_staging_" suffix, a UTC timestamp and a unique
+// ObjectID, and create the indexes of the Ports collection there, see
+// CreateIndexes. It implements ports.Stager.
+func (db *DB) Stage(ctx context.Context) (ports.Staged, error) {
+	live := db.Ports()
+	// The timestamp keeps names sortable, the ObjectID keeps stagings prepared
+	// within the same second, by one process or several, apart.
+	suffix := time.Now().UTC().Format("20060102150405") + "_" + primitive.NewObjectID().Hex()
+
+	staged := *db
+	stagingName := live.Name() + "_staging_" + suffix
+	staged.Ports = func() *mongo.Collection {
+		return db.Collection(stagingName)
+	}
+
+	s := &Staging{DB: &staged, live: live, previous: live.Name() + "_previous_" + suffix}
+	if _, err := s.CreateIndexes(ctx); err != nil {
+		_ = s.Abort(context.WithoutCancel(ctx))
+		return nil, fmt.Errorf("creating staging indexes: %w", err)
+	}
+
+	return s, nil
+}
+
+// Staging is attached to a staging collection, prepared by DB.Stage. It is a
+// DB whose Ports collection is the staging collection, and it implements
+// ports.Staged. It shares the connection pool of the DB it was prepared by, so
+// it should not be closed.
+type Staging struct {
+	*DB
+	live     *mongo.Collection // The collection replaced on commit.
+	previous string            // The collection the live one is renamed to.
+}
+
+// Commit will rename the live Ports collection to a collection named after it
+// with a "_previous_" suffix and the suffix of the staging collection, and then
+// rename the staging collection to the live one. It returns the name of the
+// collection holding the previous documents, or an empty string if the live
+// collection was empty, in which case it is replaced by the staging collection
+// directly.
+//
+// Renaming keeps the documents and indexes of a collection without rewriting
+// them, so the previous collection is indexed like the live one, but two
+// renames are not atomic: readers of the live collection find no documents for
+// the moment in between. Should the second rename fail, the first one is
+// reverted.
+//
+// To roll back, rename the previous collection to the live one, dropping the
+// target, e.g. with mongosh:
+//
+//	db.ports_previous_<suffix>.renameCollection("ports", true)
+func (s *Staging) Commit(ctx context.Context) (string, error) {
+	var previous string
+	if n, err := s.live.EstimatedDocumentCount(ctx); err != nil {
+		return "", fmt.Errorf("count: %w", err)
+	} else if n > 0 {
+		if err := s.rename(ctx, s.live.Name(), s.previous, false); err != nil {
+			return "", fmt.Errorf("renaming %s: %w", s.live.Name(), err)
+		}
+		previous = s.previous
+	}
+
+	if err := s.rename(ctx, s.Ports().Name(), s.live.Name(), true); err != nil {
+		if previous != "" {
+			if rerr := s.rename(context.WithoutCancel(ctx), previous, s.live.Name(), false); rerr != nil {
+				return previous, fmt.Errorf("renaming %s: %w (restoring %s: %v)", s.Ports().Name(), err, previous, rerr)
+			}
+		}
+		return "", fmt.Errorf("renaming %s: %w", s.Ports().Name(), err)
+	}
+
+	return previous, nil
+}
+
+// rename will rename collection from to collection to, in the database of the
+// live collection, dropping any collection named to first if dropTarget is set.
+func (s *Staging) rename(ctx context.Context, from, to string, dropTarget bool) error {
+	dbName := s.live.Database().Name()
+	return s.client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: dbName + "." + from},
+		{Key: "to", Value: dbName + "." + to},
+		{Key: "dropTarget", Value: dropTarget},
+	}).Err()
+}
+
+// Abort will drop the staging collection.
+func (s *Staging) Abort(ctx context.Context) error {
+	if err := s.Ports().Drop(ctx); err != nil {
+		return fmt.Errorf("drop: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christgf/ports"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDBStage(t *testing.T) {
+	db, teardown := setup(t)
+	t.Cleanup(teardown)
+
+	if _, err := db.CreateIndexes(context.Background()); err != nil {
+		t.Fatalf("CreateIndexes(): %v", err)
+	}
+	if err := db.InsertPort(context.Background(), ports.Port{ID: "MXACA", Name: "Acapulco"}); err != nil {
+		t.Fatalf("InsertPort(): %v", err)
+	}
+
+	staged, err := db.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("Stage(): %v", err)
+	}
+
+	t.Log("Inserting ports into staging, expecting them to be invisible until committed")
+	for _, p := range []ports.Port{{ID: "MXCOA", Name: "Coatzacoalcos"}, {ID: "MXZLO", Name: "Manzanillo"}} {
+		if err := staged.InsertPort(context.Background(), p); err != nil {
+			t.Fatalf("InsertPort(): %v", err)
+		}
+	}
+	if _, err := db.FindPort(context.Background(), "MXCOA"); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+		t.Fatalf("FindPort(): have %v, want not found error before commit", err)
+	}
+	if n, err := staged.CountPorts(context.Background()); err != nil || n != 2 {
+		t.Fatalf("CountPorts(): have %d, %v, want 2 staged ports", n, err)
+	}
+
+	t.Log("Committing staging, expecting the staged ports to replace the ports stored")
+	previous, err := staged.Commit(context.Background())
+	if err != nil {
+		t.Fatalf("Commit(): %v", err)
+	}
+	if previous == "" {
+		t.Fatal("Commit(): have no previous collection, want one for rollback")
+	}
+	t.Cleanup(func() {
+		if err := db.Collection(previous).Drop(context.Background()); err != nil {
+			t.Errorf("Drop(): %v", err)
+		}
+	})
+
+	if n, err := db.CountPorts(context.Background()); err != nil || n != 2 {
+		t.Fatalf("CountPorts(): have %d, %v, want 2 ports", n, err)
+	}
+	if _, err := db.FindPort(context.Background(), "MXACA"); !errors.Is(err, &ports.Error{Code: ports.ErrCodeNotFound}) {
+		t.Errorf("FindPort(): have %v, want not found error for a port replaced", err)
+	}
+	if n, err := db.Collection(previous).CountDocuments(context.Background(), map[string]any{}); err != nil || n != 1 {
+		t.Errorf("CountDocuments(): have %d, %v, want 1 previous port", n, err)
+	}
+
+	t.Log("Expecting the previous collection to keep the indexes of the live one, for rollback")
+	countIndexes := func(name string) int {
+		t.Helper()
+		cur, err := db.Collection(name).Indexes().List(context.Background())
+		if err != nil {
+			t.Fatalf("List(): %v", err)
+		}
+		var idx []bson.M
+		if err := cur.All(context.Background(), &idx); err != nil {
+			t.Fatalf("All(): %v", err)
+		}
+		return len(idx)
+	}
+	if have, want := countIndexes(previous), countIndexes(db.Ports().Name()); have != want {
+		t.Errorf("Indexes(): have %d indexes on %s, want %d", have, previous, want)
+	}
+}
@@ -19,33 +19,58 @@ type Port struct {
 	Timezone string
 	UNLocs   []string
 	Coords   []float64
+	Retired  bool // Set once the port is no longer in use, see Retirer.
 }
 
-// Errors for unexpected or unsupported values for Port fields.
-var (
-	ErrInvalidPortID   = errors.New("port ID should not be empty")
-	ErrInvalidPortName = errors.New("port name should not be empty")
-	ErrInvalidPortCode = errors.New("port code should not be empty")
+// Inserter can insert Port records in storage.
+type Inserter interface {
+	InsertPort(ctx context.Context, p Port) error
+}
+
+// Result is the outcome of storing a single Port as part of a batch.
+type Result struct {
+	ID       string        // The identifier of the Port stored.
+	Change   Change        // The effect of storing the Port, on success.
+	Err      error         // The reason the Port could not be stored, or nil on success.
+	Warnings []*FieldError // Problems found with rules of SeverityWarning, on success.
+}
+
+// Change describes the effect of storing a Port on the record held in storage.
+type Change int
+
+// Changes reported by storage systems through Result.
+const (
+	ChangeUnknown   Change = iota // The storage system does not tell.
+	ChangeCreated                 // There was no record, a new one was created.
+	ChangeUpdated                 // The record held different information, and was replaced.
+	ChangeUnchanged               // The record held the same information, and was left alone.
 )
 
-// Validate examines Port fields and returns an appropriate error if any of the
-// fields hold unexpected or unsupported values.
-func Validate(p Port) error {
-	switch {
-	case p.ID == "":
-		return ErrInvalidPortID
-	case p.Name == "":
-		return ErrInvalidPortName
-	case p.Code == "":
-		return ErrInvalidPortCode
+// String returns a human-readable name for the Change.
+func (c Change) String() string {
+	switch c {
+	case ChangeCreated:
+		return "created"
+	case ChangeUpdated:
+		return "updated"
+	case ChangeUnchanged:
+		return "unchanged"
 	default:
-		return nil
+		return "unknown"
 	}
 }
 
-// Inserter can insert Port records in storage.
-type Inserter interface {
-	InsertPort(ctx context.Context, p Port) error
+// BatchInserter can insert multiple Port records in storage at once.
+//
+// Implementations are expected to return one Result per Port provided, in the
+// same order. Failures affecting individual records are reported through
+// Result.Err, while a non-nil error means that the batch as a whole has failed.
+// When a batch holds the same Port ID more than once, the last occurrence is
+// the one that ends up in storage. Implementations should leave records holding
+// the same information alone, see Hash, and report the outcome of each write
+// through Result.Change.
+type BatchInserter interface {
+	InsertPorts(ctx context.Context, ps []Port) ([]Result, error)
 }
 
 // Finder can retrieve Port records from storage.
@@ -58,6 +83,54 @@ type Finder interface {
 	FindPort(ctx context.Context, portID string) (*Port, error)
 }
 
+// Retirer can retire Port records that are no longer in use, or delete them
+// from storage altogether.
+//
+// Retired records are kept in storage, with Port.Retired set, until they are
+// inserted again, which makes them current again.
+type Retirer interface {
+	// EachPortID calls fn with the identifier of each Port record in storage,
+	// and whether the record is retired, in no particular order. It stops early
+	// and returns the error returned by fn, if any.
+	EachPortID(ctx context.Context, fn func(portID string, retired bool) error) error
+	// RetirePorts retires the Port records with the identifiers provided, and
+	// returns the number of records retired. Records already retired, or not
+	// found, are ignored.
+	RetirePorts(ctx context.Context, portIDs []string) (int, error)
+	// DeletePorts deletes the Port records with the identifiers provided, and
+	// returns the number of records deleted. Records not found are ignored.
+	DeletePorts(ctx context.Context, portIDs []string) (int, error)
+}
+
+// Counter can count the Port records in storage.
+type Counter interface {
+	CountPorts(ctx context.Context) (int, error)
+}
+
+// Stager can load a complete dataset of Port records into staging storage, and
+// then swap it in place of the records in storage at once, so that readers
+// never see a partially loaded dataset.
+type Stager interface {
+	// Stage prepares empty staging storage for a new dataset. The records
+	// inserted are not visible to readers until the staging storage is
+	// committed.
+	Stage(ctx context.Context) (Staged, error)
+}
+
+// Staged is staging storage for a dataset of Port records, see Stager. Either
+// Commit or Abort should be called once the dataset is loaded, or has failed to
+// load.
+type Staged interface {
+	InsertFinder
+	Counter
+	// Commit swaps the staged records in place of the records in storage,
+	// atomically. It returns the name of the storage location where the
+	// previous records are kept for rollback, if they are kept.
+	Commit(ctx context.Context) (string, error)
+	// Abort discards the staged records.
+	Abort(ctx context.Context) error
+}
+
 // InsertFinder groups Inserter and Finder capabilities for Port records.
 type InsertFinder interface {
 	Inserter
@@ -67,24 +140,96 @@ type InsertFinder interface {
 // Service manages Port instances and records.
 type Service struct {
 	Ports InsertFinder // Port record storage.
+	Rules *RuleSet     // Validation rules for Port records, the default rules if nil.
 }
 
-// StorePort records port information in storage. It returns an error if the
-// information provided is unexpected or invalid, if the underlying storage
-// system fails, or if the context is cancelled before the operation is
-// completed. It returns nothing if the operation is successful.
-func (s *Service) StorePort(ctx context.Context, p Port) error {
-	if err := Validate(p); err != nil {
-		return &Error{Code: ErrCodeInvalid, Msg: err.Error(), Cause: err}
+// StorePort records port information in storage, once validated against
+// Service.Rules. It returns an error if the information provided is unexpected
+// or invalid, if the underlying storage system fails, or if the context is
+// cancelled before the operation is completed. If the operation is successful,
+// it returns the problems found with rules of SeverityWarning, if any.
+func (s *Service) StorePort(ctx context.Context, p Port) ([]*FieldError, error) {
+	warnings, err := s.Rules.Validate(p)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInvalid, Msg: err.Error(), Cause: err}
 	}
 
 	if err := s.Ports.InsertPort(ctx, p); err != nil {
-		return &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: err}
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: err}
+	}
+
+	return warnings, nil
+}
+
+// StorePorts records information for multiple ports in storage, reporting the
+// outcome for each of them as a Result, in the same order as the ports
+// provided. Ports holding unexpected or invalid information, as validated
+// against Service.Rules, are not stored, and their Result.Err is an Error with
+// code ErrCodeInvalid, while problems found with rules of SeverityWarning are
+// reported as Result.Warnings. The remaining ports are stored in a single batch
+// if the underlying storage system is a BatchInserter, or one by one otherwise,
+// in which case Result.Change is ChangeUnknown. It returns an error if the
+// batch as a whole could not be stored, or if the context is cancelled before
+// the operation is completed.
+func (s *Service) StorePorts(ctx context.Context, ps []Port) ([]Result, error) {
+	results := make([]Result, len(ps))
+	valid := make([]Port, 0, len(ps))
+	index := make([]int, 0, len(ps)) // Position of each valid port in ps.
+	for i, p := range ps {
+		results[i].ID = p.ID
+		warnings, err := s.Rules.Validate(p)
+		if err != nil {
+			results[i].Err = &Error{Code: ErrCodeInvalid, Msg: err.Error(), Cause: err}
+			continue
+		}
+		results[i].Warnings = warnings
+
+		valid = append(valid, p)
+		index = append(index, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	batch, ok := s.Ports.(BatchInserter)
+	if !ok {
+		for j, p := range valid {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := s.Ports.InsertPort(ctx, p); err != nil {
+				results[index[j]].Err = &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: err}
+				results[index[j]].Warnings = nil
+			}
+		}
+
+		return results, nil
+	}
+
+	res, err := batch.InsertPorts(ctx, valid)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: err}
+	}
+	if len(res) != len(valid) {
+		return nil, &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: errUnexpectedResults}
+	}
+	for j, r := range res {
+		if r.Err != nil {
+			results[index[j]].Err = &Error{Code: ErrCodeInternal, Msg: "could not insert", Cause: r.Err}
+			results[index[j]].Warnings = nil
+			continue
+		}
+		results[index[j]].Change = r.Change
 	}
 
-	return nil
+	return results, nil
 }
 
+// errUnexpectedResults is the cause of the error returned by StorePorts when a
+// BatchInserter does not return one Result per Port.
+var errUnexpectedResults = errors.New("unexpected number of batch results")
+
 // GetPortByID retrieves port information from storage, based on the port
 // identifier provided. It returns an appropriate error if the underlying storage
 // system fails, if a record matching the identifier is not found, or if the
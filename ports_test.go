@@ -10,41 +10,10 @@ import (
 	"github.com/christgf/ports/mock"
 )
 
-func TestValidate(t *testing.T) {
-	tests := []struct {
-		port ports.Port
-		err  error
-	}{
-		{
-			port: ports.Port{},
-			err:  ports.ErrInvalidPortID,
-		},
-		{
-			port: ports.Port{ID: "AEAJM"},
-			err:  ports.ErrInvalidPortName,
-		},
-		{
-			port: ports.Port{ID: "AEAJM", Name: "Ajman"},
-			err:  ports.ErrInvalidPortCode,
-		},
-		{
-			port: ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000"},
-			err:  nil,
-		},
-	}
-
-	for _, tt := range tests {
-		gotErr := ports.Validate(tt.port)
-		if wantErr := tt.err; !errors.Is(gotErr, wantErr) {
-			t.Errorf("Validate(%+v): have %v, want %v", tt.port, gotErr, wantErr)
-		}
-	}
-}
-
 func TestServiceStorePortValidateError(t *testing.T) {
 	s := &ports.Service{}
 
-	err := s.StorePort(context.TODO(), ports.Port{})
+	_, err := s.StorePort(context.TODO(), ports.Port{})
 	if err == nil {
 		t.Fatal("StorePort(): expected validation error, got nothing")
 	}
@@ -70,7 +39,7 @@ func TestServiceStorePortInsertError(t *testing.T) {
 		},
 	}
 
-	if err := s.StorePort(context.TODO(), port); !errors.Is(err, wantErr) {
+	if _, err := s.StorePort(context.TODO(), port); !errors.Is(err, wantErr) {
 		t.Errorf("StorePort(): have %v, want %v", err, wantErr)
 	}
 }
@@ -100,9 +69,42 @@ func TestServiceStorePortOK(t *testing.T) {
 		},
 	}
 
-	if err := s.StorePort(context.TODO(), port); err != nil {
+	warnings, err := s.StorePort(context.TODO(), port)
+	if err != nil {
 		t.Errorf("StorePort(): %v", err)
 	}
+	if len(warnings) > 0 {
+		t.Errorf("StorePort(): have warnings %v, want none", warnings)
+	}
+}
+
+func TestServiceStorePortWarnings(t *testing.T) {
+	rules, err := ports.NewRuleSet(ports.Rule{Field: "country", Check: ports.CheckEnum, Values: []string{"Greece"}, Severity: ports.SeverityWarning})
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+
+	var stored bool
+	s := &ports.Service{
+		Ports: &mock.InsertFinder{
+			InsertPortFn: func(_ context.Context, _ ports.Port) error {
+				stored = true
+				return nil
+			},
+		},
+		Rules: rules,
+	}
+
+	warnings, err := s.StorePort(context.TODO(), ports.Port{ID: "MXACA", Name: "Acapulco", Code: "20101", Country: "Mexico"})
+	if err != nil {
+		t.Fatalf("StorePort(): %v", err)
+	}
+	if !stored {
+		t.Error("StorePort(): port not stored, want it stored despite warnings")
+	}
+	if len(warnings) != 1 || warnings[0].Field != "country" {
+		t.Errorf("StorePort(): have warnings %v, want one for country", warnings)
+	}
 }
 
 func TestServiceGetPortByIDValidateError(t *testing.T) {
@@ -183,3 +185,86 @@ func TestServiceGetPortByID(t *testing.T) {
 		t.Fatalf("GetPortByID(): port mismatch\nhave: %+v\nwant: %+v\n", got, want)
 	}
 }
+
+func TestServiceStorePorts(t *testing.T) {
+	batch := []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101"},
+		{ID: "MXATM", Name: "Altamira"},
+		{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"},
+	}
+	insertErr := errors.New("duplicate key")
+
+	db := &mock.BatchInsertFinder{
+		InsertPortsFn: func(_ context.Context, ps []ports.Port) ([]ports.Result, error) {
+			if got, want := len(ps), 2; got != want {
+				t.Fatalf("InsertPorts(): have %d ports, want %d", got, want)
+			}
+
+			return []ports.Result{{ID: ps[0].ID, Change: ports.ChangeUnchanged}, {ID: ps[1].ID, Err: insertErr}}, nil
+		},
+	}
+	s := &ports.Service{Ports: db}
+
+	results, err := s.StorePorts(context.TODO(), batch)
+	if err != nil {
+		t.Fatalf("StorePorts(): %v", err)
+	}
+
+	if got, want := len(results), len(batch); got != want {
+		t.Fatalf("StorePorts(): have %d results, want %d", got, want)
+	}
+	if err := results[0].Err; err != nil {
+		t.Errorf("StorePorts(): have result error %v for %q, want nothing", err, results[0].ID)
+	}
+	if got, want := results[0].Change, ports.ChangeUnchanged; got != want {
+		t.Errorf("StorePorts(): have change %v for %q, want %v", got, results[0].ID, want)
+	}
+	if err := results[1].Err; !errors.Is(err, ports.ErrInvalidPortCode) {
+		t.Errorf("StorePorts(): have result error %v for %q, want port code validation error", err, results[1].ID)
+	}
+	if err := results[2].Err; !errors.Is(err, insertErr) {
+		t.Errorf("StorePorts(): have result error %v for %q, want %v", err, results[2].ID, insertErr)
+	}
+	if got, want := db.InsertPortCalls, 0; got != want {
+		t.Errorf("StorePorts(): have %d InsertPort calls, want %d", got, want)
+	}
+}
+
+func TestServiceStorePortsFallback(t *testing.T) {
+	db := &mock.InsertFinder{}
+	s := &ports.Service{Ports: db}
+
+	results, err := s.StorePorts(context.TODO(), []ports.Port{
+		{ID: "MXACA", Name: "Acapulco", Code: "20101"},
+		{ID: "MXCOA", Name: "Coatzacoalcos", Code: "20102"},
+	})
+	if err != nil {
+		t.Fatalf("StorePorts(): %v", err)
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("StorePorts(): have result error %v for %q, want nothing", r.Err, r.ID)
+		}
+	}
+	if got, want := db.InsertPortCalls, 2; got != want {
+		t.Errorf("StorePorts(): have %d InsertPort calls, want %d", got, want)
+	}
+}
+
+func TestServiceStorePortsBatchError(t *testing.T) {
+	wantErr := errors.New("something went wrong")
+
+	s := &ports.Service{
+		Ports: &mock.BatchInsertFinder{
+			InsertPortsFn: func(context.Context, []ports.Port) ([]ports.Result, error) {
+				return nil, wantErr
+			},
+		},
+	}
+
+	_, err := s.StorePorts(context.TODO(), []ports.Port{{ID: "MXACA", Name: "Acapulco", Code: "20101"}})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("StorePorts(): have %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,448 @@
+package ports
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Severity tells what becomes of a Port record breaking a Rule.
+type Severity string
+
+// Severities supported by Rule.Severity.
+const (
+	SeverityError   Severity = "error"   // The record is rejected.
+	SeverityWarning Severity = "warning" // The record is stored, but flagged.
+	SeverityOff     Severity = "off"     // The rule is not checked, to turn a default rule off.
+)
+
+// Checks supported by Rule.Check. Apart from CheckRequired, checks only apply
+// to fields that are set.
+const (
+	CheckRequired   = "required"    // The field is set.
+	CheckRegex      = "regex"       // Every value of the field matches Rule.Pattern, as a whole.
+	CheckRange      = "range"       // Every value of the field is between Rule.Min and Rule.Max.
+	CheckLength     = "length"      // The number of values of the field is between Rule.Min and Rule.Max.
+	CheckEnum       = "enum"        // Every value of the field is one of Rule.Values.
+	CheckTimezone   = "timezone"    // Every value of the field is a known time zone, e.g. Europe/Athens.
+	CheckCrossField = "cross-field" // Every value of the field is one of the values of the Rule.Other field, if set.
+)
+
+// Rule is a validation rule for a Port field, declared in a rule set file, see
+// ReadRuleSet. Fields are named as in the HTTP API, e.g. "unlocs", and the
+// longitude and latitude are named "coords[0]" and "coords[1]".
+type Rule struct {
+	Field    string   `json:"field"`
+	Check    string   `json:"check"`
+	Pattern  string   `json:"pattern,omitempty"`  // Regular expression, for CheckRegex.
+	Min      *float64 `json:"min,omitempty"`      // Minimum, for CheckRange and CheckLength.
+	Max      *float64 `json:"max,omitempty"`      // Maximum, for CheckRange and CheckLength.
+	Values   []string `json:"values,omitempty"`   // Values allowed, for CheckEnum.
+	Other    string   `json:"other,omitempty"`    // Field holding the values allowed, for CheckCrossField.
+	Severity Severity `json:"severity,omitempty"` // SeverityError if empty.
+	Message  string   `json:"message,omitempty"`  // Describes the problem, generated if empty.
+
+	err   error // The problem reported by a default rule, matched by errors.Is.
+	tuned bool  // The parameters of a default rule were overridden, so err no longer describes it.
+}
+
+// override returns the default rule with the fields set by r, the rule
+// overriding it, so that an override only declares what it changes, e.g. its
+// severity, and problems found still match the error of the default rule.
+func (d Rule) override(r Rule) Rule {
+	if r.Severity != "" {
+		d.Severity = r.Severity
+	}
+	if r.Message != "" {
+		d.Message = r.Message
+	}
+	if r.Pattern != "" {
+		d.Pattern, d.tuned = r.Pattern, true
+	}
+	if r.Min != nil {
+		d.Min, d.tuned = r.Min, true
+	}
+	if r.Max != nil {
+		d.Max, d.tuned = r.Max, true
+	}
+	if r.Values != nil {
+		d.Values, d.tuned = r.Values, true
+	}
+	if r.Other != "" {
+		d.Other, d.tuned = r.Other, true
+	}
+
+	return d
+}
+
+// ruleError is the problem reported by a default rule that was overridden with
+// a different message or parameters. It matches the error of the default rule
+// with errors.Is.
+type ruleError struct {
+	msg string
+	err error
+}
+
+// Error implements the built-in error interface.
+func (e *ruleError) Error() string {
+	return e.msg
+}
+
+// Unwrap the error of the default rule when using the %w verb.
+func (e *ruleError) Unwrap() error {
+	return e.err
+}
+
+// portField describes a Port field that rules can check, either text or
+// numbers, holding a single value or a list of values.
+type portField struct {
+	list    bool
+	text    func(p Port) []string
+	numbers func(p Port) []float64
+}
+
+// text returns a single text value, or none if it is empty.
+func text(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return []string{s}
+}
+
+// coord returns the coordinate at index i, if set.
+func coord(p Port, i int) []float64 {
+	if i >= len(p.Coords) {
+		return nil
+	}
+
+	return p.Coords[i : i+1]
+}
+
+// portFields are the Port fields that rules can check, by name.
+var portFields = map[string]portField{
+	"id":        {text: func(p Port) []string { return text(p.ID) }},
+	"name":      {text: func(p Port) []string { return text(p.Name) }},
+	"code":      {text: func(p Port) []string { return text(p.Code) }},
+	"city":      {text: func(p Port) []string { return text(p.City) }},
+	"province":  {text: func(p Port) []string { return text(p.Province) }},
+	"country":   {text: func(p Port) []string { return text(p.Country) }},
+	"timezone":  {text: func(p Port) []string { return text(p.Timezone) }},
+	"alias":     {list: true, text: func(p Port) []string { return p.Alias }},
+	"regions":   {list: true, text: func(p Port) []string { return p.Regions }},
+	"unlocs":    {list: true, text: func(p Port) []string { return p.UNLocs }},
+	"coords":    {list: true, numbers: func(p Port) []float64 { return p.Coords }},
+	"coords[0]": {numbers: func(p Port) []float64 { return coord(p, 0) }},
+	"coords[1]": {numbers: func(p Port) []float64 { return coord(p, 1) }},
+}
+
+// fieldNames returns the names of the fields that rules can check, for
+// messages.
+func fieldNames() string {
+	names := make([]string, 0, len(portFields))
+	for name := range portFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ", ")
+}
+
+// bound returns a pointer to v, for Rule.Min and Rule.Max.
+func bound(v float64) *float64 {
+	return &v
+}
+
+// defaultRules are the rules checked by Validate, in order.
+var defaultRules = []Rule{
+	{Field: "id", Check: CheckRequired, err: ErrInvalidPortID},
+	{Field: "name", Check: CheckRequired, err: ErrInvalidPortName},
+	{Field: "code", Check: CheckRequired, err: ErrInvalidPortCode},
+	{Field: "coords", Check: CheckLength, Min: bound(2), Max: bound(2), err: ErrInvalidPortCoords},
+	{Field: "coords[0]", Check: CheckRange, Min: bound(-180), Max: bound(180), err: ErrInvalidPortLongitude},
+	{Field: "coords[1]", Check: CheckRange, Min: bound(-90), Max: bound(90), err: ErrInvalidPortLatitude},
+	{Field: "timezone", Check: CheckTimezone, err: ErrInvalidPortTimezone},
+	{Field: "unlocs", Check: CheckRegex, Pattern: "[A-Z]{2}[A-Z2-9]{3}", err: ErrInvalidPortUNLoc},
+	{Field: "id", Check: CheckCrossField, Other: "unlocs", err: ErrInvalidPortIDUNLoc},
+}
+
+// defaultRuleSet is the RuleSet of the default rules, see NewRuleSet.
+var defaultRuleSet = func() *RuleSet {
+	rs, err := NewRuleSet()
+	if err != nil {
+		panic(err)
+	}
+
+	return rs
+}()
+
+// rule is a Rule ready to be checked.
+type rule struct {
+	Rule
+	field portField
+	other portField
+	re    *regexp.Regexp
+}
+
+// RuleSet is a set of validation rules for Port records, shared by everything
+// that validates records, so that they are held to the same quality bar. A nil
+// RuleSet holds the default rules. It is safe for concurrent use by multiple
+// goroutines.
+type RuleSet struct {
+	rules []rule
+}
+
+// NewRuleSet creates a RuleSet holding the default rules checked by Validate,
+// along with the rules provided. A rule for the same field and check as a
+// default rule overrides the fields it sets, so that its severity can be
+// changed, or so that it can be turned off, without declaring its parameters
+// again. Problems found by a default rule still match its error with errors.Is,
+// e.g. ErrInvalidPortCoords. It returns an error if one of the rules provided is
+// not valid.
+func NewRuleSet(rules ...Rule) (*RuleSet, error) {
+	all := slices.Clone(defaultRules)
+	for _, r := range rules {
+		i := slices.IndexFunc(all, func(d Rule) bool { return d.Field == r.Field && d.Check == r.Check })
+		if i < 0 {
+			all = append(all, r)
+			continue
+		}
+		all[i] = all[i].override(r)
+	}
+
+	rs := &RuleSet{}
+	for _, r := range all {
+		if r.Severity == "" {
+			r.Severity = SeverityError
+		}
+		if r.Severity == SeverityOff {
+			continue
+		}
+
+		compiled, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s %s: %w", r.Field, r.Check, err)
+		}
+		rs.rules = append(rs.rules, compiled)
+	}
+
+	return rs, nil
+}
+
+// compileRule checks that the rule is valid, and readies it to be checked.
+func compileRule(r Rule) (rule, error) {
+	if r.Severity != SeverityError && r.Severity != SeverityWarning {
+		return rule{}, fmt.Errorf("unsupported severity %q, use %s, %s or %s", r.Severity, SeverityError, SeverityWarning, SeverityOff)
+	}
+
+	c := rule{Rule: r}
+	var ok bool
+	if c.field, ok = portFields[r.Field]; !ok {
+		return rule{}, fmt.Errorf("unsupported field %q, use one of %s", r.Field, fieldNames())
+	}
+
+	var msg string
+	switch r.Check {
+	case CheckRequired:
+		msg = fmt.Sprintf("port %s should not be empty", r.Field)
+	case CheckRegex:
+		if c.field.text == nil || r.Pattern == "" {
+			return rule{}, errors.New("regex checks a text field against a pattern")
+		}
+		re, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return rule{}, err
+		}
+		c.re = re
+		msg = fmt.Sprintf("port %s should match %s", r.Field, r.Pattern)
+	case CheckRange:
+		if c.field.numbers == nil || (r.Min == nil && r.Max == nil) {
+			return rule{}, errors.New("range checks coordinates against a minimum or maximum")
+		}
+		msg = fmt.Sprintf("port %s should be %s", r.Field, formatBounds(r.Min, r.Max))
+	case CheckLength:
+		if !c.field.list || (r.Min == nil && r.Max == nil) {
+			return rule{}, errors.New("length checks a list field against a minimum or maximum")
+		}
+		msg = fmt.Sprintf("port %s should have %s values", r.Field, formatBounds(r.Min, r.Max))
+	case CheckEnum:
+		if c.field.text == nil || len(r.Values) == 0 {
+			return rule{}, errors.New("enum checks a text field against a list of values")
+		}
+		msg = fmt.Sprintf("port %s should be one of %s", r.Field, strings.Join(r.Values, ", "))
+	case CheckTimezone:
+		if c.field.text == nil {
+			return rule{}, errors.New("timezone checks a text field")
+		}
+		msg = fmt.Sprintf("port %s should be a known time zone, e.g. Europe/Athens", r.Field)
+	case CheckCrossField:
+		if c.other, ok = portFields[r.Other]; !ok || c.field.text == nil || c.other.text == nil {
+			return rule{}, errors.New("cross-field checks a text field against the values of another text field")
+		}
+		msg = fmt.Sprintf("port %s should be one of its %s", r.Field, r.Other)
+	default:
+		return rule{}, fmt.Errorf("unsupported check %q, use %s, %s, %s, %s, %s, %s or %s", r.Check,
+			CheckRequired, CheckRegex, CheckRange, CheckLength, CheckEnum, CheckTimezone, CheckCrossField)
+	}
+
+	if r.Message != "" {
+		msg = r.Message
+	}
+	switch {
+	case r.err == nil:
+		c.err = errors.New(msg)
+	case r.Message != "" || r.tuned:
+		c.err = &ruleError{msg: msg, err: r.err}
+	}
+
+	return c, nil
+}
+
+// formatBounds describes a minimum and a maximum, either of which may be nil.
+func formatBounds(lo, hi *float64) string {
+	switch {
+	case lo == nil:
+		return fmt.Sprintf("at most %g", *hi)
+	case hi == nil:
+		return fmt.Sprintf("at least %g", *lo)
+	default:
+		return fmt.Sprintf("between %g and %g", *lo, *hi)
+	}
+}
+
+// within reports whether v is between the bounds of the rule.
+func (r rule) within(v float64) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+// check returns the problems found with the Port field checked by the rule.
+func (r rule) check(p Port) []*FieldError {
+	var (
+		errs  []*FieldError
+		texts []string
+		nums  []float64
+	)
+	if r.field.text != nil {
+		texts = r.field.text(p)
+	} else {
+		nums = r.field.numbers(p)
+	}
+	add := func(i int) {
+		path := r.Field
+		if r.field.list && i >= 0 {
+			path = fmt.Sprintf("%s[%d]", r.Field, i)
+		}
+		errs = append(errs, &FieldError{Field: path, Err: r.err})
+	}
+
+	switch r.Check {
+	case CheckRequired:
+		if len(texts) == 0 && len(nums) == 0 {
+			add(-1)
+		}
+	case CheckRegex:
+		for i, s := range texts {
+			if !r.re.MatchString(s) {
+				add(i)
+			}
+		}
+	case CheckRange:
+		for i, v := range nums {
+			if !r.within(v) {
+				add(i)
+			}
+		}
+	case CheckLength:
+		if n := len(texts) + len(nums); n > 0 && !r.within(float64(n)) {
+			add(-1)
+		}
+	case CheckEnum:
+		for i, s := range texts {
+			if !slices.Contains(r.Values, s) {
+				add(i)
+			}
+		}
+	case CheckTimezone:
+		for i, s := range texts {
+			if !knownTimezone(s) {
+				add(i)
+			}
+		}
+	case CheckCrossField:
+		allowed := r.other.text(p)
+		if len(allowed) == 0 {
+			break
+		}
+		for i, s := range texts {
+			if !slices.Contains(allowed, s) {
+				add(i)
+			}
+		}
+	}
+
+	return errs
+}
+
+// Validate checks the Port record against every rule of the set, in order. It
+// returns the problems found with rules of SeverityWarning, and a
+// ValidationError listing the problems found with rules of SeverityError, if
+// any.
+func (rs *RuleSet) Validate(p Port) (warnings []*FieldError, err error) {
+	if rs == nil {
+		rs = defaultRuleSet
+	}
+
+	var errs []*FieldError
+	for _, r := range rs.rules {
+		found := r.check(p)
+		if r.Severity == SeverityWarning {
+			warnings = append(warnings, found...)
+			continue
+		}
+		errs = append(errs, found...)
+	}
+
+	if len(errs) > 0 {
+		return warnings, &ValidationError{Fields: errs}
+	}
+
+	return warnings, nil
+}
+
+// ReadRuleSet reads rules declared in JSON format from r, and returns the
+// RuleSet holding them along with the default rules, see NewRuleSet. Rules are
+// declared as a "rules" array, and other fields are ignored, so that rules can
+// share a configuration file with other settings. Unknown fields within a rule
+// are not, so that a misspelled field is reported rather than ignored. E.g.
+//
+//	{
+//	  "rules": [
+//	    {"field": "coords", "check": "required", "severity": "warning"},
+//	    {"field": "country", "check": "enum", "values": ["Greece", "Cyprus"]},
+//	    {"field": "id", "check": "cross-field", "other": "unlocs", "severity": "off"}
+//	  ]
+//	}
+func ReadRuleSet(r io.Reader) (*RuleSet, error) {
+	var file struct {
+		Rules []json.RawMessage `json:"rules"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("decoding rules: %w", err)
+	}
+
+	rules := make([]Rule, len(file.Rules))
+	for i, raw := range file.Rules {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rules[i]); err != nil {
+			return nil, fmt.Errorf("decoding rule %d: %w", i+1, err)
+		}
+	}
+
+	return NewRuleSet(rules...)
+}
@@ -0,0 +1,234 @@
+package ports_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestRuleSetValidate(t *testing.T) {
+	bound := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		rule    ports.Rule
+		port    ports.Port
+		problem string // Field with a problem, if any.
+	}{
+		{
+			name:    "required",
+			rule:    ports.Rule{Field: "country", Check: ports.CheckRequired},
+			port:    ports.Port{City: "Ajman"},
+			problem: "country",
+		},
+		{
+			name: "regex matches",
+			rule: ports.Rule{Field: "code", Check: ports.CheckRegex, Pattern: `\d{5}`},
+			port: ports.Port{Code: "52000"},
+		},
+		{
+			name:    "regex matches in part",
+			rule:    ports.Rule{Field: "code", Check: ports.CheckRegex, Pattern: `\d{5}`},
+			port:    ports.Port{Code: "520001"},
+			problem: "code",
+		},
+		{
+			name:    "regex on a list",
+			rule:    ports.Rule{Field: "regions", Check: ports.CheckRegex, Pattern: `[A-Z ]+`},
+			port:    ports.Port{Regions: []string{"MIDDLE EAST", "Gulf"}},
+			problem: "regions[1]",
+		},
+		{
+			name:    "range",
+			rule:    ports.Rule{Field: "coords[1]", Check: ports.CheckRange, Min: bound(0)},
+			port:    ports.Port{Coords: []float64{151.2, -33.9}},
+			problem: "coords[1]",
+		},
+		{
+			name:    "length",
+			rule:    ports.Rule{Field: "alias", Check: ports.CheckLength, Max: bound(1)},
+			port:    ports.Port{Alias: []string{"Dubai", "Jebel Ali"}},
+			problem: "alias",
+		},
+		{
+			name: "enum",
+			rule: ports.Rule{Field: "country", Check: ports.CheckEnum, Values: []string{"Greece", "Cyprus"}},
+			port: ports.Port{Country: "Greece"},
+		},
+		{
+			name:    "enum not one of the values",
+			rule:    ports.Rule{Field: "country", Check: ports.CheckEnum, Values: []string{"Greece", "Cyprus"}},
+			port:    ports.Port{Country: "Malta"},
+			problem: "country",
+		},
+		{
+			name: "enum of an empty field",
+			rule: ports.Rule{Field: "country", Check: ports.CheckEnum, Values: []string{"Greece", "Cyprus"}},
+			port: ports.Port{},
+		},
+		{
+			name:    "cross-field",
+			rule:    ports.Rule{Field: "city", Check: ports.CheckCrossField, Other: "alias"},
+			port:    ports.Port{City: "Dubai", Alias: []string{"Jebel Ali"}},
+			problem: "city",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := ports.NewRuleSet(tt.rule)
+			if err != nil {
+				t.Fatalf("NewRuleSet(): %v", err)
+			}
+
+			// Fill in the fields required by the default rules.
+			p := tt.port
+			p.ID, p.Name = "AEAJM", "Ajman"
+			if p.Code == "" {
+				p.Code = "52000"
+			}
+
+			_, err = rs.Validate(p)
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("Validate(%+v): have %v, want no error", p, err)
+				}
+				return
+			}
+
+			var validationErr *ports.ValidationError
+			if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 {
+				t.Fatalf("Validate(%+v): have %v, want a single problem with %s", p, err, tt.problem)
+			}
+			if got, want := validationErr.Fields[0].Field, tt.problem; got != want {
+				t.Errorf("Validate(%+v): have problem with %s, want %s", p, got, want)
+			}
+		})
+	}
+}
+
+func TestRuleSetSeverity(t *testing.T) {
+	rs, err := ports.NewRuleSet(
+		ports.Rule{Field: "code", Check: ports.CheckRequired, Severity: ports.SeverityOff},
+		ports.Rule{Field: "coords", Check: ports.CheckRequired, Severity: ports.SeverityWarning, Message: "routing needs coordinates"},
+	)
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+
+	t.Log("The port has neither a code nor coordinates, which should only be flagged")
+
+	warnings, err := rs.Validate(ports.Port{ID: "AEAJM", Name: "Ajman"})
+	if err != nil {
+		t.Fatalf("Validate(): have %v, want no error", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("Validate(): have warnings %v, want one for coords", warnings)
+	}
+	if got, want := warnings[0].Error(), "routing needs coordinates"; got != want {
+		t.Errorf("Validate(): have warning %q, want %q", got, want)
+	}
+
+	t.Log("Errors should still be reported along with warnings")
+
+	warnings, err = rs.Validate(ports.Port{ID: "AEAJM"})
+	if got, want := err, ports.ErrInvalidPortName; !errors.Is(got, want) {
+		t.Errorf("Validate(): have %v, want %v", got, want)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Validate(): have warnings %v, want one for coords", warnings)
+	}
+}
+
+func TestNewRuleSetOverride(t *testing.T) {
+	bound := func(v float64) *float64 { return &v }
+	rs, err := ports.NewRuleSet(
+		ports.Rule{Field: "coords", Check: ports.CheckLength, Severity: ports.SeverityWarning},
+		ports.Rule{Field: "coords[0]", Check: ports.CheckRange, Min: bound(-10)},
+		ports.Rule{Field: "timezone", Check: ports.CheckTimezone, Message: "unknown time zone"},
+	)
+	if err != nil {
+		t.Fatalf("NewRuleSet(): %v", err)
+	}
+
+	t.Log("Overrides keep the parameters they do not set, and the errors of the default rules")
+
+	p := ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000", Coords: []float64{55.5}}
+	warnings, err := rs.Validate(p)
+	if err != nil {
+		t.Fatalf("Validate(%+v): have %v, want no error", p, err)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ports.ErrInvalidPortCoords) {
+		t.Fatalf("Validate(%+v): have warnings %v, want %v", p, warnings, ports.ErrInvalidPortCoords)
+	}
+
+	p = ports.Port{ID: "AEAJM", Name: "Ajman", Code: "52000", Coords: []float64{-20, 25.4}, Timezone: "Asia/Ajman"}
+	_, err = rs.Validate(p)
+	var validationErr *ports.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 {
+		t.Fatalf("Validate(%+v): have %v, want problems with coords[0] and timezone", p, err)
+	}
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{err: ports.ErrInvalidPortLongitude, want: "port coords[0] should be between -10 and 180"},
+		{err: ports.ErrInvalidPortTimezone, want: "unknown time zone"},
+	} {
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%+v): have %v, want %v", p, err, tt.err)
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v): have %q, want it to contain %q", p, err, tt.want)
+		}
+	}
+}
+
+func TestNewRuleSetInvalid(t *testing.T) {
+	for _, rule := range []ports.Rule{
+		{Field: "harbour", Check: ports.CheckRequired},
+		{Field: "name", Check: "shorter-than"},
+		{Field: "name", Check: ports.CheckRequired, Severity: "fatal"},
+		{Field: "name", Check: ports.CheckRegex},
+		{Field: "name", Check: ports.CheckRegex, Pattern: "[A-Z"},
+		{Field: "name", Check: ports.CheckRange},
+		{Field: "coords", Check: ports.CheckRange},
+		{Field: "name", Check: ports.CheckLength},
+		{Field: "country", Check: ports.CheckEnum},
+		{Field: "city", Check: ports.CheckCrossField, Other: "coords"},
+	} {
+		if _, err := ports.NewRuleSet(rule); err == nil {
+			t.Errorf("NewRuleSet(%+v): have no error, want one", rule)
+		}
+	}
+}
+
+func TestReadRuleSet(t *testing.T) {
+	rs, err := ports.ReadRuleSet(strings.NewReader(`{
+	  "transforms": ["trim"],
+	  "rules": [
+	    {"field": "coords", "check": "required", "severity": "warning"},
+	    {"field": "country", "check": "enum", "values": ["Greece", "Cyprus"]}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadRuleSet(): %v", err)
+	}
+
+	warnings, err := rs.Validate(ports.Port{ID: "GRPIR", Name: "Piraeus", Code: "47701", Country: "Italy"})
+	if err == nil || !strings.Contains(err.Error(), "country") {
+		t.Errorf("Validate(): have %v, want error for country", err)
+	}
+	if len(warnings) != 1 || warnings[0].Field != "coords" {
+		t.Errorf("Validate(): have warnings %v, want one for coords", warnings)
+	}
+
+	if _, err := ports.ReadRuleSet(strings.NewReader(`{"rules": [{"field": "coords", "check": "required", "severity": "loud"}]}`)); err == nil {
+		t.Error("ReadRuleSet(): have no error for unsupported severity, want one")
+	}
+	if _, err := ports.ReadRuleSet(strings.NewReader(`{"rules": [{"field": "coords", "check": "required", "severty": "warning"}]}`)); err == nil {
+		t.Error("ReadRuleSet(): have no error for unknown rule field, want one")
+	}
+}
@@ -0,0 +1,104 @@
+package ports
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Time zones are resolved without the system database, missing from our images.
+)
+
+// Errors for unexpected or unsupported values for Port fields.
+var (
+	ErrInvalidPortID        = errors.New("port ID should not be empty")
+	ErrInvalidPortName      = errors.New("port name should not be empty")
+	ErrInvalidPortCode      = errors.New("port code should not be empty")
+	ErrInvalidPortCoords    = errors.New("port coordinates should be a longitude and a latitude")
+	ErrInvalidPortLongitude = errors.New("port longitude should be between -180 and 180")
+	ErrInvalidPortLatitude  = errors.New("port latitude should be between -90 and 90")
+	ErrInvalidPortTimezone  = errors.New("port timezone should be a known time zone, e.g. Europe/Athens")
+	ErrInvalidPortUNLoc     = errors.New("port UN/LOCODE should be 2 letters followed by 3 letters or digits 2-9")
+	ErrInvalidPortIDUNLoc   = errors.New("port ID should be one of its UN/LOCODEs")
+)
+
+// FieldError is a problem with the value of a single Port field.
+type FieldError struct {
+	Field string // Path of the field, as in the HTTP API, e.g. "unlocs[1]".
+	Err   error  // The problem, one of the ErrInvalidPort errors.
+}
+
+// Error implements the built-in error interface.
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap the underlying error when using the %w verb.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is the error returned by Validate, holding every problem
+// found with the values of Port fields, in field order. Use errors.Is to check
+// for a specific problem, or errors.As to list them all.
+//
+//	if errors.Is(err, ErrInvalidPortTimezone) {
+//		// Do something if the timezone is not known.
+//	}
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+// Error implements the built-in error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, fe := range e.Fields {
+		msgs[i] = fe.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap the problems found, so that errors.Is and errors.As examine each of
+// them.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, fe := range e.Fields {
+		errs[i] = fe
+	}
+
+	return errs
+}
+
+// Validate examines Port fields and returns a ValidationError listing every
+// field holding unexpected or unsupported values, if any, as checked by the
+// default rules, see NewRuleSet. ID, Name and Code are required. Other fields
+// are optional, but should be valid if set:
+//
+//   - Coords should be a longitude and a latitude, in this order.
+//   - Timezone should be a time zone name known to the IANA Time Zone database.
+//   - UNLocs should be UN/LOCODEs, and the ID should be one of them.
+func Validate(p Port) error {
+	_, err := defaultRuleSet.Validate(p)
+	return err
+}
+
+// timezones caches the time zone names known to knownTimezone, since loading a
+// time zone reads the database each time. Unknown names are not cached, so that
+// invalid input cannot grow the cache without bound; they are looked up again.
+var timezones sync.Map // map[string]struct{}
+
+// knownTimezone reports whether name is a time zone name known to the IANA Time
+// Zone database, as loaded by time.LoadLocation. Names standing for the local
+// time zone of the host are not, since they are not the time zone of a port.
+func knownTimezone(name string) bool {
+	if _, ok := timezones.Load(name); ok {
+		return true
+	}
+
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return false
+	}
+	timezones.Store(name, struct{}{})
+
+	return true
+}
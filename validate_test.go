@@ -0,0 +1,96 @@
+package ports_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/christgf/ports"
+)
+
+func TestValidate(t *testing.T) {
+	valid := ports.Port{
+		ID:       "AEAJM",
+		Name:     "Ajman",
+		Code:     "52000",
+		Timezone: "Asia/Dubai",
+		UNLocs:   []string{"AEAJM"},
+		Coords:   []float64{55.5136433, 25.4052165},
+	}
+
+	tests := []struct {
+		name   string
+		change func(p *ports.Port)
+		err    error
+	}{
+		{name: "valid", change: func(p *ports.Port) {}},
+		{name: "optional fields empty", change: func(p *ports.Port) { p.Timezone, p.UNLocs, p.Coords = "", nil, nil }},
+		{name: "empty ID", change: func(p *ports.Port) { p.ID, p.UNLocs = "", nil }, err: ports.ErrInvalidPortID},
+		{name: "empty name", change: func(p *ports.Port) { p.Name = "" }, err: ports.ErrInvalidPortName},
+		{name: "empty code", change: func(p *ports.Port) { p.Code = "" }, err: ports.ErrInvalidPortCode},
+		{name: "one coordinate", change: func(p *ports.Port) { p.Coords = []float64{55.5} }, err: ports.ErrInvalidPortCoords},
+		{name: "longitude out of range", change: func(p *ports.Port) { p.Coords = []float64{255.5, 25.4} }, err: ports.ErrInvalidPortLongitude},
+		{name: "latitude out of range", change: func(p *ports.Port) { p.Coords = []float64{55.5, -95.4} }, err: ports.ErrInvalidPortLatitude},
+		{name: "unknown timezone", change: func(p *ports.Port) { p.Timezone = "America/Argentina" }, err: ports.ErrInvalidPortTimezone},
+		{name: "local timezone", change: func(p *ports.Port) { p.Timezone = "Local" }, err: ports.ErrInvalidPortTimezone},
+		{name: "UN/LOCODE too short", change: func(p *ports.Port) { p.UNLocs = append(p.UNLocs, "AEAJ") }, err: ports.ErrInvalidPortUNLoc},
+		{name: "UN/LOCODE lower case", change: func(p *ports.Port) { p.UNLocs = append(p.UNLocs, "aeauh") }, err: ports.ErrInvalidPortUNLoc},
+		{name: "UN/LOCODE with digits", change: func(p *ports.Port) { p.UNLocs = append(p.UNLocs, "GBLS2") }},
+		{name: "UN/LOCODE with digit 1", change: func(p *ports.Port) { p.UNLocs = append(p.UNLocs, "GBLS1") }, err: ports.ErrInvalidPortUNLoc},
+		{name: "ID not a UN/LOCODE", change: func(p *ports.Port) { p.UNLocs = []string{"AEAUH"} }, err: ports.ErrInvalidPortIDUNLoc},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			p.UNLocs = append([]string(nil), valid.UNLocs...)
+			tt.change(&p)
+
+			gotErr := ports.Validate(p)
+			if tt.err == nil {
+				if gotErr != nil {
+					t.Errorf("Validate(%+v): have %v, want no error", p, gotErr)
+				}
+				return
+			}
+			if !errors.Is(gotErr, tt.err) {
+				t.Errorf("Validate(%+v): have %v, want %v", p, gotErr, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateAllFields(t *testing.T) {
+	err := ports.Validate(ports.Port{
+		ID:       "AEAJM",
+		Timezone: "Asia/Nowhere",
+		UNLocs:   []string{"AEAUH", "ae-xyz"},
+		Coords:   []float64{181, 91},
+	})
+
+	var validationErr *ports.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate(): have %v, want a validation error", err)
+	}
+
+	type problem struct {
+		Field string
+		Err   error
+	}
+	var got []problem
+	for _, fe := range validationErr.Fields {
+		got = append(got, problem{fe.Field, fe.Err})
+	}
+	want := []problem{
+		{"name", ports.ErrInvalidPortName},
+		{"code", ports.ErrInvalidPortCode},
+		{"coords[0]", ports.ErrInvalidPortLongitude},
+		{"coords[1]", ports.ErrInvalidPortLatitude},
+		{"timezone", ports.ErrInvalidPortTimezone},
+		{"unlocs[1]", ports.ErrInvalidPortUNLoc},
+		{"id", ports.ErrInvalidPortIDUNLoc},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate(): have problems %v, want %v", got, want)
+	}
+}